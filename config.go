@@ -7,12 +7,15 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 )
 
 type Config struct {
 	Action             string
 	ConfigFile         string
+	Profile            string
 	Driver             string
 	LogLevel           string
 	Debug              string
@@ -28,17 +31,30 @@ type Config struct {
 	DockerOptions                      string
 	DockerComposeFile                  string
 	DockerComposeOptions               string
+	DockerComposeService               string
+	DockerComposeCommand               string
 	PreserveEnvironmentToAllContainers string
 	ExitBehavior                       string
+	HealthTimeout                      string
+	MaxRestarts                        string
+	FailOnSidecarFailure               string
 	Test                               string
 	PrintLogs                          string
 	PrintLogsTarget                    string
+	LogsDir                            string
+	LogsArchive                        string
+	ReportJSON                         string
+	ReportJUnit                        string
+	PullPolicy                         string
+	DockerBuildContext                 string
+	Dockerfile                         string
 }
 
 func (c Config) String() string {
 	str := ""
 	str += fmt.Sprintf("{ Action: %s }", c.Action)
 	str += fmt.Sprintf("{ ConfigFile: %s }", c.ConfigFile)
+	str += fmt.Sprintf("{ Profile: %s }", c.Profile)
 	str += fmt.Sprintf("{ Driver: %s }", c.Driver)
 	str += fmt.Sprintf("{ LogLevel: %s }", c.LogLevel)
 	str += fmt.Sprintf("{ Debug: %s }", c.Debug)
@@ -53,15 +69,29 @@ func (c Config) String() string {
 	str += fmt.Sprintf("{ DockerOptions: %s }", c.DockerOptions)
 	str += fmt.Sprintf("{ DockerComposeFile: %s }", c.DockerComposeFile)
 	str += fmt.Sprintf("{ DockerComposeOptions: %s }", c.DockerComposeOptions)
+	str += fmt.Sprintf("{ DockerComposeService: %s }", c.DockerComposeService)
+	str += fmt.Sprintf("{ DockerComposeCommand: %s }", c.DockerComposeCommand)
 	str += fmt.Sprintf("{ PreserveEnvironmentToAllContainers: %s }", c.PreserveEnvironmentToAllContainers)
 	str += fmt.Sprintf("{ ExitBehavior: %s }", c.ExitBehavior)
+	str += fmt.Sprintf("{ HealthTimeout: %s }", c.HealthTimeout)
+	str += fmt.Sprintf("{ MaxRestarts: %s }", c.MaxRestarts)
+	str += fmt.Sprintf("{ FailOnSidecarFailure: %s }", c.FailOnSidecarFailure)
 	str += fmt.Sprintf("{ Test: %s }", c.Test)
 	str += fmt.Sprintf("{ PrintLogs: %s }", c.PrintLogs)
 	str += fmt.Sprintf("{ PrintLogsTarget: %s }", c.PrintLogsTarget)
+	str += fmt.Sprintf("{ LogsDir: %s }", c.LogsDir)
+	str += fmt.Sprintf("{ LogsArchive: %s }", c.LogsArchive)
+	str += fmt.Sprintf("{ ReportJSON: %s }", c.ReportJSON)
+	str += fmt.Sprintf("{ ReportJUnit: %s }", c.ReportJUnit)
+	str += fmt.Sprintf("{ PullPolicy: %s }", c.PullPolicy)
+	str += fmt.Sprintf("{ DockerBuildContext: %s }", c.DockerBuildContext)
+	str += fmt.Sprintf("{ Dockerfile: %s }", c.Dockerfile)
 	return str
 }
 
-func getCLIConfig() Config {
+// Returns the config set by the CLI flags and whether the config file is read in the strict mode.
+// The strict mode is set only by the CLI flag, because it applies to reading the config file itself.
+func getCLIConfig() (Config, bool) {
 	// let's use use a custom flagSet, so that we don't mutate global state
 	flagSet := flag.NewFlagSet("flagSet", flag.PanicOnError)
 
@@ -76,7 +106,7 @@ func getCLIConfig() Config {
 	flagSet.BoolVar(&version, "v", false, usageVersion+" (shorthand)")
 
 	var action string
-	const usageAction = "Action: run, pull. Default: run"
+	const usageAction = "Action: run, pull, clean. Default: run"
 	flagSet.StringVar(&action, "action", "", usageAction)
 	flagSet.StringVar(&action, "a", "", usageAction+" (shorthand)")
 
@@ -85,8 +115,16 @@ func getCLIConfig() Config {
 	flagSet.StringVar(&config, "config", "", usageConfig)
 	flagSet.StringVar(&config, "c", "", usageConfig+" (shorthand)")
 
+	var profile string
+	const usageProfile = "Profile: a section of the config file, e.g. [e2e], which keys override the keys outside of any section. Default: not set, no profile"
+	flagSet.StringVar(&profile, "profile", "", usageProfile)
+
+	var strict bool
+	const usageStrict = "Fail, instead of printing a warning, on a suspicious line of the config file, e.g. with an unknown key"
+	flagSet.BoolVar(&strict, "strict", false, usageStrict)
+
 	var driver string
-	const usageDriver = "Driver: docker or docker-compose (dc for short). Default: docker"
+	const usageDriver = "Driver: docker, docker-compose (dc for short), podman or docker-api. Default: docker"
 	flagSet.StringVar(&driver, "driver", "", usageDriver)
 	flagSet.StringVar(&driver, "d", "", usageDriver+" (shorthand)")
 
@@ -94,6 +132,14 @@ func getCLIConfig() Config {
 	const usageImage = "Docker image name and tag, e.g. alpine:3.21"
 	flagSet.StringVar(&image, "image", "", usageImage)
 
+	var dockerBuildContext string
+	const usageDockerBuildContext = "Directory to build the image from, instead of setting the image. The image is tagged with a hash of the directory contents and built only if that tag does not exist. Default: not set"
+	flagSet.StringVar(&dockerBuildContext, "docker-build-context", "", usageDockerBuildContext)
+
+	var dockerfile string
+	const usageDockerfile = "Dockerfile to build the image from. Default: Dockerfile in the docker build context"
+	flagSet.StringVar(&dockerfile, "dockerfile", "", usageDockerfile)
+
 	var logLevel string
 	const usageLogLevel = "Set log level to: silent, error, info, debug. Default: info"
 	flagSet.StringVar(&logLevel, "log-level", "", usageLogLevel)
@@ -147,18 +193,59 @@ func getCLIConfig() Config {
 	flagSet.StringVar(&dockerComposeFile, "docker-compose-file", "", usageDCFile)
 	flagSet.StringVar(&dockerComposeFile, "dcf", "", usageDCFile+" (shorthand)")
 
+	var dockerComposeService string
+	const usageDCService = "Docker-compose service in which the command is run. Default: default. Only for driver: docker-compose"
+	flagSet.StringVar(&dockerComposeService, "docker-compose-service", "", usageDCService)
+	flagSet.StringVar(&dockerComposeService, "dcs", "", usageDCService+" (shorthand)")
+
+	var dockerComposeCommand string
+	const usageDCCommand = "Command to run docker-compose with, e.g. \"docker compose\". Default: docker-compose if installed, else docker compose. Only for driver: docker-compose"
+	flagSet.StringVar(&dockerComposeCommand, "docker-compose-command", "", usageDCCommand)
+
 	var exitBehavior string
-	const usageExitBehavior = "How to react when a container (not the default one) exits. Possible values: ignore, abort (default), restart. Only for driver: docker-compose"
+	const usageExitBehavior = "How to react when a container (not the default one) exits. Possible values: ignore, abort (default), restart. It can be set per service, e.g. \"db=abort,mock=restart\", then a value without a service name applies to the other services. Only for driver: docker-compose"
 	flagSet.StringVar(&exitBehavior, "exit-behavior", "", usageExitBehavior)
 
+	var healthTimeout string
+	const usageHealthTimeout = "How many seconds to wait for the containers (not the default one) with a healthcheck to be healthy, before the default container is run. 0 means not waiting. Default: 0. Only for driver: docker-compose"
+	flagSet.StringVar(&healthTimeout, "health-timeout", "", usageHealthTimeout)
+
+	var maxRestarts string
+	const usageMaxRestarts = "How many times a container can be restarted, when its exit behavior is restart. Then, the exit behavior is abort. 0 means no limit. Default: 5. Only for driver: docker-compose"
+	flagSet.StringVar(&maxRestarts, "max-restarts", "", usageMaxRestarts)
+
+	var failOnSidecarFailure string
+	const usageFailOnSidecarFailure = "Set to true to fail (with exit status 4) when the default container succeeded, but another container failed. Set to a list of services, split by commas, to consider only their containers. Default: false. Only for driver: docker-compose"
+	flagSet.StringVar(&failOnSidecarFailure, "fail-on-sidecar-failure", "", usageFailOnSidecarFailure)
+
 	var printLogs string
-	const usagePrintLogs = "Decide when to print the logs of non-default containers. Possible values: always, failure (default), never. Only for driver: docker-compose"
+	const usagePrintLogs = "Decide when to print the logs of non-default containers. Possible values: always, failure (default), never, stream (print them live, prefixed with the service name). Only for driver: docker-compose"
 	flagSet.StringVar(&printLogs, "print-logs", "", usagePrintLogs)
 
 	var printLogsTarget string
 	const usagePrintLogsTarget = "Decide where to print the logs of non-default containers. Possible values: console (default, stderr), file. Only for driver: docker-compose"
 	flagSet.StringVar(&printLogsTarget, "print-logs-target", "", usagePrintLogsTarget)
 
+	var logsDir string
+	const usageLogsDir = "Directory to save the logs bundle to after the run: the logs of all the containers with timestamps, their docker inspect output and the generated docker-compose file. Default: not set, no bundle. Only for driver: docker-compose"
+	flagSet.StringVar(&logsDir, "logs-dir", "", usageLogsDir)
+
+	var logsArchive string
+	const usageLogsArchive = "Set to true to also pack the logs bundle into a tar.gz file in the logs directory. Default: false. Only for driver: docker-compose"
+	flagSet.StringVar(&logsArchive, "logs-archive", "", usageLogsArchive)
+
+	var reportJSON string
+	const usageReportJSON = "File to save the run report to, as JSON: the run ID, the image and its digest, the command, the start and end time, the containers exit codes, the caught signals and the exit status. Default: not set, no report"
+	flagSet.StringVar(&reportJSON, "report-json", "", usageReportJSON)
+
+	var reportJUnit string
+	const usageReportJUnit = "File to save the run report to, as JUnit XML. Each container is a test case, which fails if the container failed. Default: not set, no report"
+	flagSet.StringVar(&reportJUnit, "report-junit", "", usageReportJUnit)
+
+	var pullPolicy string
+	const usagePullPolicy = "Decide when to pull the images before a run. Possible values: always, missing (default, pull only the images not present locally), never (fail if an image is not present locally)"
+	flagSet.StringVar(&pullPolicy, "pull-policy", "", usagePullPolicy)
+
 	var test string
 	const usageTest = "Set this to true when integration testing. This turns writing env files to a test directory"
 	flagSet.StringVar(&test, "test", "", usageTest)
@@ -182,9 +269,15 @@ func getCLIConfig() Config {
 	workDirInnerAbs := getAbsPathOrPanic(workDirInner)
 	workDirOuterAbs := getAbsPathOrPanic(workDirOuter)
 	identityDirOuterAbs := getAbsPathOrPanic(identityDirOuter)
+	logsDirAbs := getAbsPathOrPanic(logsDir)
+	reportJSONAbs := getAbsPathOrPanic(reportJSON)
+	reportJUnitAbs := getAbsPathOrPanic(reportJUnit)
+	dockerBuildContextAbs := getAbsPathOrPanic(dockerBuildContext)
+	dockerfileAbs := getAbsPathOrPanic(dockerfile)
 	return Config{
 		Action:                             action,
 		ConfigFile:                         config,
+		Profile:                            profile,
 		Driver:                             driver,
 		LogLevel:                           logLevel,
 		Debug:                              debug,
@@ -199,11 +292,23 @@ func getCLIConfig() Config {
 		DockerOptions:                      dockerOptions,
 		PreserveEnvironmentToAllContainers: preserveEnvToAllContainers,
 		DockerComposeFile:                  dockerComposeFile,
+		DockerComposeService:               dockerComposeService,
+		DockerComposeCommand:               dockerComposeCommand,
 		ExitBehavior:                       exitBehavior,
+		HealthTimeout:                      healthTimeout,
+		MaxRestarts:                        maxRestarts,
+		FailOnSidecarFailure:               failOnSidecarFailure,
 		Test:                               test,
 		PrintLogs:                          printLogs,
 		PrintLogsTarget:                    printLogsTarget,
-	}
+		LogsDir:                            logsDirAbs,
+		LogsArchive:                        logsArchive,
+		ReportJSON:                         reportJSONAbs,
+		ReportJUnit:                        reportJUnitAbs,
+		PullPolicy:                         pullPolicy,
+		DockerBuildContext:                 dockerBuildContextAbs,
+		Dockerfile:                         dockerfileAbs,
+	}, strict
 }
 
 func getAbsPathOrPanic(path string) string {
@@ -250,6 +355,7 @@ func MapToConfig(configMap map[string]string) Config {
 	config := Config{}
 	config.Action = configMap["action"]
 	config.ConfigFile = configMap["config"]
+	config.Profile = configMap["profile"]
 	config.Driver = configMap["driver"]
 	config.LogLevel = configMap["logLevel"]
 	config.Debug = configMap["debug"]
@@ -264,17 +370,30 @@ func MapToConfig(configMap map[string]string) Config {
 	config.DockerOptions = configMap["dockerOptions"]
 	config.DockerComposeFile = configMap["dockerComposeFile"]
 	config.DockerComposeOptions = configMap["dockerComposeOptions"]
+	config.DockerComposeService = configMap["dockerComposeService"]
+	config.DockerComposeCommand = configMap["dockerComposeCommand"]
 	config.PreserveEnvironmentToAllContainers = configMap["preserveEnvironmentToAllContainers"]
 	config.ExitBehavior = configMap["exitBehavior"]
+	config.HealthTimeout = configMap["healthTimeout"]
+	config.MaxRestarts = configMap["maxRestarts"]
+	config.FailOnSidecarFailure = configMap["failOnSidecarFailure"]
 	config.Test = configMap["test"]
 	config.PrintLogs = configMap["printLogs"]
 	config.PrintLogsTarget = configMap["printLogsTarget"]
+	config.LogsDir = configMap["logsDir"]
+	config.LogsArchive = configMap["logsArchive"]
+	config.ReportJSON = configMap["reportJSON"]
+	config.ReportJUnit = configMap["reportJUnit"]
+	config.PullPolicy = configMap["pullPolicy"]
+	config.DockerBuildContext = configMap["dockerBuildContext"]
+	config.Dockerfile = configMap["dockerfile"]
 	return config
 }
 func ConfigToMap(config Config) map[string]string {
 	configMap := make(map[string]string, 0)
 	configMap["action"] = config.Action
 	configMap["config"] = config.ConfigFile
+	configMap["profile"] = config.Profile
 	configMap["driver"] = config.Driver
 	configMap["debug"] = config.Debug
 	configMap["logLevel"] = config.LogLevel
@@ -289,95 +408,251 @@ func ConfigToMap(config Config) map[string]string {
 	configMap["dockerOptions"] = config.DockerOptions
 	configMap["dockerComposeFile"] = config.DockerComposeFile
 	configMap["dockerComposeOptions"] = config.DockerComposeOptions
+	configMap["dockerComposeService"] = config.DockerComposeService
+	configMap["dockerComposeCommand"] = config.DockerComposeCommand
 	configMap["preserveEnvironmentToAllContainers"] = config.PreserveEnvironmentToAllContainers
 	configMap["exitBehavior"] = config.ExitBehavior
+	configMap["healthTimeout"] = config.HealthTimeout
+	configMap["maxRestarts"] = config.MaxRestarts
+	configMap["failOnSidecarFailure"] = config.FailOnSidecarFailure
 	configMap["test"] = config.Test
 	configMap["printLogs"] = config.PrintLogs
 	configMap["printLogsTarget"] = config.PrintLogsTarget
+	configMap["logsDir"] = config.LogsDir
+	configMap["logsArchive"] = config.LogsArchive
+	configMap["reportJSON"] = config.ReportJSON
+	configMap["reportJUnit"] = config.ReportJUnit
+	configMap["pullPolicy"] = config.PullPolicy
+	configMap["dockerBuildContext"] = config.DockerBuildContext
+	configMap["dockerfile"] = config.Dockerfile
 	return configMap
 }
 
-func ensureNoOuterQuotes(input string) string {
-	if strings.HasPrefix(input, "\"") && strings.HasSuffix(input, "\"") {
-		output := strings.TrimPrefix(input, "\"")
-		output = strings.TrimSuffix(output, "\"")
-		return output
+// The names of the profiles, e.g. e2e-alpine
+var configProfileNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+// FileConfig is the config read from the config files: the keys outside of any section (the base) and the keys
+// in the sections of the selected profile, e.g. [e2e].
+type FileConfig struct {
+	// The selected profile, may be empty
+	Profile string
+	// Whether the warnings, e.g. about the unknown keys, are errors
+	Strict        bool
+	BaseConfig    Config
+	ProfileConfig Config
+	// All the profiles found in the config files
+	Profiles []string
+}
+
+// getFileConfig returns the config read from the config file: the base config and the config of the profile.
+// It returns an empty config if config file does not exist.
+// It returns an error if an included config file does not exist or if the includes form a cycle, and if
+// the profile is set, but there is no such section in the config files.
+// In the strict mode, the warnings about the config file lines, e.g. about the unknown keys, are errors.
+func getFileConfig(logger *Logger, pathToFile string, profile string, strict bool) (Config, Config, error) {
+	fileConfig := &FileConfig{Profile: profile, Strict: strict, Profiles: make([]string, 0)}
+	if _, err := os.Stat(pathToFile); err != nil {
+		logger.Log("debug", fmt.Sprintf("Config file does not exist: %s", pathToFile))
+		if profile != "" {
+			return Config{}, Config{}, fmt.Errorf("Profile: %s is set, but config file: %s does not exist", profile, pathToFile)
+		}
+		return Config{}, Config{}, nil
+	}
+	var err error
+	if isStructuredConfigFile(pathToFile) {
+		err = readStructuredConfigFile(logger, pathToFile, "", fileConfig, []string{pathToFile}, make(map[string]string))
+	} else {
+		err = readConfigFile(logger, pathToFile, "", fileConfig, []string{pathToFile}, make(map[string]string))
+	}
+	if err != nil {
+		return Config{}, Config{}, err
 	}
-	if strings.HasPrefix(input, "'") && strings.HasSuffix(input, "'") {
-		output := strings.TrimPrefix(input, "'")
-		output = strings.TrimSuffix(output, "'")
-		return output
+	if profile != "" && !stringInList(profile, fileConfig.Profiles) {
+		return Config{}, Config{}, fmt.Errorf("Profile: %s not found in config file: %s. Available profiles: %s",
+			profile, pathToFile, strings.Join(fileConfig.Profiles, ", "))
 	}
-	return input
+	return fileConfig.BaseConfig, fileConfig.ProfileConfig, nil
 }
 
-// getFileConfig never returns error. If config file does not exist,
-// it returns Config object with default values.
-func getFileConfig(logger *Logger, pathToFile string) Config {
-	config := Config{}
-	if _, err := os.Stat(pathToFile); err == nil {
-		contents, err := ioutil.ReadFile(pathToFile)
+func stringInList(value string, list []string) bool {
+	for _, element := range list {
+		if element == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads the config file into fileConfig, line by line, so that the later keys override the earlier ones.
+// A line: [name] starts the section of the profile: name, which lasts until the next section or the end of the file.
+// The keys outside of any section are read into the base config, the keys in the sections of the selected
+// profile into the profile config and the keys of the other profiles are ignored.
+// DOJO_INCLUDE=path reads the included file in place of that line. The path is relative to the including file.
+// The keys outside of any section in the included file belong to the section of the DOJO_INCLUDE line.
+// The includeChain is the list of files, starting with the top config file, which led to this file.
+// The variables are the keys read so far (also the keys unknown to dojo), which can be used in the next values.
+func readConfigFile(logger *Logger, pathToFile string, section string, fileConfig *FileConfig, includeChain []string,
+	variables map[string]string) error {
+	contents, err := ioutil.ReadFile(pathToFile)
+	if err != nil {
+		panic(err)
+	}
+	lines := strings.Split(string(contents), "\n")
+
+	currentSection := section
+	for i, line := range lines {
+		if name, isSection := parseConfigFileSection(line); isSection {
+			if !configProfileNameRegexp.MatchString(name) {
+				return fmt.Errorf("%s:%v: invalid profile name: %s. It must match: %s",
+					pathToFile, i+1, name, configProfileNameRegexp.String())
+			}
+			if !stringInList(name, fileConfig.Profiles) {
+				fileConfig.Profiles = append(fileConfig.Profiles, name)
+			}
+			currentSection = name
+			continue
+		}
+		parsedLine, err := parseConfigFileLine(line)
 		if err != nil {
-			panic(err)
+			return fmt.Errorf("%s:%v: %s", pathToFile, i+1, err)
 		}
-		lines := strings.Split(string(contents), "\n")
-
-		for _, line := range lines {
-			if !strings.HasPrefix(line, "#") && line != "" {
-				// the file line is not a comment
-
-				// there may be many "=" signs in this line, let's just consider the 1st one
-				kv := strings.SplitN(line, "=", 2)
-				key := kv[0]
-				value := kv[1]
-				value = ensureNoOuterQuotes(value)
-
-				switch key {
-				case "DOJO_DRIVER":
-					config.Driver = value
-				case "DOJO_DOCKER_IMAGE":
-					config.DockerImage = value
-				case "DOJO_DOCKER_OPTIONS":
-					config.DockerOptions = value
-				case "DOJO_DOCKER_COMPOSE_FILE":
-					config.DockerComposeFile = value
-				case "DOJO_DOCKER_COMPOSE_OPTIONS":
-					config.DockerComposeOptions = value
-				case "DOJO_DOCKER_COMPOSE_PRINT_LOGS":
-					config.PrintLogs = value
-				case "DOJO_DOCKER_COMPOSE_PRINT_LOGS_TARGET":
-					config.PrintLogsTarget = value
-				case "DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS":
-					config.PreserveEnvironmentToAllContainers = value
-				case "DOJO_WORK_OUTER":
-					dir := getAbsPathOrPanic(value)
-					config.WorkDirOuter = dir
-				case "DOJO_WORK_INNER":
-					dir := getAbsPathOrPanic(value)
-					config.WorkDirInner = dir
-				case "DOJO_IDENTITY_OUTER":
-					dir := getAbsPathOrPanic(value)
-					config.IdentityDirOuter = dir
-				case "DOJO_EXIT_BEHAVIOR":
-					config.ExitBehavior = value
-				case "DOJO_BLACKLIST_VARIABLES":
-					config.BlacklistVariables = value
-				case "DOJO_LOG_LEVEL":
-					if value == "debug" || value == "DEBUG" {
-						config.Debug = "true"
-						// the stronger option value (the more verbose) wins
-						config.LogLevel = "debug"
-					} else {
-						config.Debug = "false"
-						config.LogLevel = value
-					}
-				}
+		if parsedLine == nil {
+			// an empty line or a comment
+			continue
+		}
+		for _, warning := range parsedLine.Warnings {
+			if fileConfig.Strict {
+				return fmt.Errorf("%s:%v: %s", pathToFile, i+1, warning)
 			}
+			logger.Log("warn", fmt.Sprintf("%s:%v: %s", pathToFile, i+1, warning))
+		}
+		location := fmt.Sprintf("%s:%v", pathToFile, i+1)
+		err = readConfigFileValue(logger, pathToFile, location, currentSection, *parsedLine, fileConfig, includeChain, variables)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sets the key read from the config file in the config of the section: the base config or the profile config.
+// Interpolates the value, expands "~" in the paths and reads the included files. The location of the key,
+// e.g. Dojofile:7, is printed in the errors.
+func readConfigFileValue(logger *Logger, pathToFile string, location string, section string, line ConfigFileLine,
+	fileConfig *FileConfig, includeChain []string, variables map[string]string) error {
+	if section != "" && section != fileConfig.Profile {
+		// the keys of another profile, not even interpolated, because they may need other variables
+		return nil
+	}
+	config := &fileConfig.BaseConfig
+	if section != "" {
+		config = &fileConfig.ProfileConfig
+	}
+
+	value, err := interpolateConfigValue(line.Value, variables)
+	if err != nil {
+		return fmt.Errorf("%s: %s: %s", location, line.Key, err)
+	}
+	if configPathKeys[line.Key] {
+		value = expandHomeDir(value)
+	}
+	variables[line.Key] = value
+
+	if line.Key == "DOJO_INCLUDE" {
+		return includeConfigFile(logger, pathToFile, value, section, fileConfig, includeChain, variables)
+	}
+	setFileConfigValue(config, line.Key, value)
+	return nil
+}
+
+func includeConfigFile(logger *Logger, includingFile string, includedFile string, section string, fileConfig *FileConfig,
+	includeChain []string, variables map[string]string) error {
+	if !filepath.IsAbs(includedFile) {
+		includedFile = filepath.Join(filepath.Dir(includingFile), includedFile)
+	}
+	chain := append(append([]string{}, includeChain...), includedFile)
+	includedFileAbs := getAbsPathOrPanic(includedFile)
+	for _, file := range includeChain {
+		if getAbsPathOrPanic(file) == includedFileAbs {
+			return fmt.Errorf("Config files include each other in a cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	if _, err := os.Stat(includedFile); err != nil {
+		return fmt.Errorf("Included config file: %s does not exist. Include chain: %s", includedFile, strings.Join(chain, " -> "))
+	}
+	logger.Log("debug", fmt.Sprintf("Including config file: %s into: %s", includedFile, includingFile))
+	if isStructuredConfigFile(includedFile) {
+		return readStructuredConfigFile(logger, includedFile, section, fileConfig, chain, variables)
+	}
+	return readConfigFile(logger, includedFile, section, fileConfig, chain, variables)
+}
+
+func setFileConfigValue(config *Config, key string, value string) {
+	switch key {
+	case "DOJO_DRIVER":
+		config.Driver = value
+	case "DOJO_DOCKER_IMAGE":
+		config.DockerImage = value
+	case "DOJO_DOCKER_BUILD_CONTEXT":
+		config.DockerBuildContext = getAbsPathOrPanic(value)
+	case "DOJO_DOCKERFILE":
+		config.Dockerfile = getAbsPathOrPanic(value)
+	case "DOJO_DOCKER_OPTIONS":
+		config.DockerOptions = value
+	case "DOJO_DOCKER_COMPOSE_FILE":
+		config.DockerComposeFile = value
+	case "DOJO_DOCKER_COMPOSE_OPTIONS":
+		config.DockerComposeOptions = value
+	case "DOJO_DOCKER_COMPOSE_SERVICE":
+		config.DockerComposeService = value
+	case "DOJO_DOCKER_COMPOSE_COMMAND":
+		config.DockerComposeCommand = value
+	case "DOJO_DOCKER_COMPOSE_PRINT_LOGS":
+		config.PrintLogs = value
+	case "DOJO_DOCKER_COMPOSE_PRINT_LOGS_TARGET":
+		config.PrintLogsTarget = value
+	case "DOJO_LOGS_DIR":
+		dir := getAbsPathOrPanic(value)
+		config.LogsDir = dir
+	case "DOJO_LOGS_ARCHIVE":
+		config.LogsArchive = value
+	case "DOJO_REPORT_JSON":
+		config.ReportJSON = getAbsPathOrPanic(value)
+	case "DOJO_REPORT_JUNIT":
+		config.ReportJUnit = getAbsPathOrPanic(value)
+	case "DOJO_PULL_POLICY":
+		config.PullPolicy = value
+	case "DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS":
+		config.PreserveEnvironmentToAllContainers = value
+	case "DOJO_WORK_OUTER":
+		dir := getAbsPathOrPanic(value)
+		config.WorkDirOuter = dir
+	case "DOJO_WORK_INNER":
+		dir := getAbsPathOrPanic(value)
+		config.WorkDirInner = dir
+	case "DOJO_IDENTITY_OUTER":
+		dir := getAbsPathOrPanic(value)
+		config.IdentityDirOuter = dir
+	case "DOJO_EXIT_BEHAVIOR":
+		config.ExitBehavior = value
+	case "DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT":
+		config.HealthTimeout = value
+	case "DOJO_DOCKER_COMPOSE_MAX_RESTARTS":
+		config.MaxRestarts = value
+	case "DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE":
+		config.FailOnSidecarFailure = value
+	case "DOJO_BLACKLIST_VARIABLES":
+		config.BlacklistVariables = value
+	case "DOJO_LOG_LEVEL":
+		if value == "debug" || value == "DEBUG" {
+			config.Debug = "true"
+			// the stronger option value (the more verbose) wins
+			config.LogLevel = "debug"
+		} else {
+			config.Debug = "false"
+			config.LogLevel = value
 		}
-	} else {
-		logger.Log("debug", fmt.Sprintf("Config file does not exist: %s", pathToFile))
 	}
-	return config
 }
 
 func getDefaultConfig(configFile string) Config {
@@ -401,27 +676,37 @@ func getDefaultConfig(configFile string) Config {
 		IdentityDirOuter:                   currentUser.HomeDir,
 		BlacklistVariables:                 "BASH*,HOME,USERNAME,USER,LOGNAME,PATH,TERM,SHELL,MAIL,SUDO_*,WINDOWID,SSH_*,SESSION_*,GEM_HOME,GEM_PATH,GEM_ROOT,HOSTNAME,HOSTTYPE,IFS,PPID,PWD,OLDPWD,LC*,TMPDIR",
 		DockerComposeFile:                  "docker-compose.yml",
+		DockerComposeService:               "default",
 		ExitBehavior:                       "abort",
+		HealthTimeout:                      "0",
+		MaxRestarts:                        "5",
+		FailOnSidecarFailure:               "false",
 		PreserveEnvironmentToAllContainers: "true",
 		PrintLogs:                          "failure",
 		PrintLogsTarget:                    "console",
+		LogsArchive:                        "false",
+		PullPolicy:                         "missing",
 	}
 	return defaultConfig
 }
 
-func getMergedConfig(moreImportantConfig Config, lessImportantConfig Config, leastImportantConfig Config) Config {
-	leastImportantConfigMap := ConfigToMap(leastImportantConfig)
-	moreImportantConfigMap := ConfigToMap(moreImportantConfig)
-	lessImportantConfigMap := ConfigToMap(lessImportantConfig)
+// Merges the configs, ordered from the most important one, e.g. the CLI config, to the least important one,
+// e.g. the default config. The first not empty value of each option wins. The image and the build context
+// are an exception, see: resolveDockerImageLayers.
+func getMergedConfig(configs ...Config) Config {
+	configMaps := make([]map[string]string, 0)
+	for _, config := range resolveDockerImageLayers(configs) {
+		configMaps = append(configMaps, ConfigToMap(config))
+	}
 
 	mergedConfigMap := make(map[string]string, 0)
-	for k, v := range moreImportantConfigMap {
-		if v != "" {
-			mergedConfigMap[k] = v
-		} else if lessImportantConfigMap[k] != "" {
-			mergedConfigMap[k] = lessImportantConfigMap[k]
-		} else {
-			mergedConfigMap[k] = leastImportantConfigMap[k]
+	for k := range configMaps[0] {
+		mergedConfigMap[k] = ""
+		for _, configMap := range configMaps {
+			if configMap[k] != "" {
+				mergedConfigMap[k] = configMap[k]
+				break
+			}
 		}
 	}
 
@@ -429,15 +714,45 @@ func getMergedConfig(moreImportantConfig Config, lessImportantConfig Config, lea
 	return config
 }
 
+// The image is either set or built, so DockerImage and DockerBuildContext are merged as a pair: a config,
+// which sets one of them, clears the other one (and Dockerfile, which is used only with DockerBuildContext)
+// in the less important configs. E.g. --docker-build-context overrides DOJO_DOCKER_IMAGE set in a Dojofile.
+// Returns the new configs, the configs given are not changed.
+func resolveDockerImageLayers(configs []Config) []Config {
+	resolvedConfigs := make([]Config, 0)
+	imageSet := false
+	buildContextSet := false
+	for _, config := range configs {
+		if buildContextSet {
+			config.DockerImage = ""
+		}
+		if imageSet {
+			config.DockerBuildContext = ""
+			config.Dockerfile = ""
+		}
+		imageSet = imageSet || config.DockerImage != ""
+		buildContextSet = buildContextSet || config.DockerBuildContext != ""
+		resolvedConfigs = append(resolvedConfigs, config)
+	}
+	return resolvedConfigs
+}
+
+// The same as the docker-compose service names pattern
+var dcServiceNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
 func verifyConfig(logger *Logger, config *Config) error {
 	if config.Driver == "dc" {
 		config.Driver = "docker-compose"
 	}
-	if config.Action != "run" && config.Action != "pull" {
-		return fmt.Errorf("Invalid configuration, unsupported Action: %s. Supported: run, pull", config.Action)
+	if config.Action != "run" && config.Action != "pull" && config.Action != "clean" {
+		return fmt.Errorf("Invalid configuration, unsupported Action: %s. Supported: run, pull, clean", config.Action)
+	}
+	if config.Driver != "docker" && config.Driver != "docker-compose" && config.Driver != "podman" &&
+		config.Driver != "docker-api" {
+		return fmt.Errorf("Invalid configuration, unsupported Driver: %s. Supported: docker, docker-compose, podman, docker-api", config.Driver)
 	}
-	if config.Driver != "docker" && config.Driver != "docker-compose" {
-		return fmt.Errorf("Invalid configuration, unsupported Driver: %s. Supported: docker, docker-compose", config.Driver)
+	if config.Action == "clean" && config.Driver != "docker-compose" {
+		return fmt.Errorf("Invalid configuration, Action: clean is supported only for driver: docker-compose")
 	}
 	if config.Debug != "true" && config.Debug != "false" {
 		return fmt.Errorf("Invalid configuration, unsupported Debug: %s. Supported: true, false", config.Debug)
@@ -454,15 +769,15 @@ func verifyConfig(logger *Logger, config *Config) error {
 		// the more verbose option takes precedence
 		config.Debug = "true"
 	}
-	if config.DockerComposeOptions != "" && config.Driver == "docker" {
-		return fmt.Errorf("DockerComposeOptions option is unsupported for driver: docker")
+	if config.DockerComposeOptions != "" && config.Driver != "docker-compose" {
+		return fmt.Errorf("DockerComposeOptions option is unsupported for driver: %s", config.Driver)
+	}
+	if config.DockerComposeCommand != "" && config.Driver != "docker-compose" {
+		return fmt.Errorf("DockerComposeCommand option is unsupported for driver: %s", config.Driver)
 	}
 	if config.DockerOptions != "" && config.Driver == "docker-compose" {
 		return fmt.Errorf("DockerOptions option is unsupported for driver: docker-compose")
 	}
-	if config.RemoveContainers == "false" && config.Driver == "docker-compose" {
-		logger.Log("warn", "RemoveContainers=false is unsupported for driver: docker-compose")
-	}
 	if config.RemoveContainers != "true" && config.RemoveContainers != "false" {
 		return fmt.Errorf("Invalid configuration, unsupported RemoveContainers: %s. Supported: true, false", config.RemoveContainers)
 	}
@@ -472,9 +787,9 @@ func verifyConfig(logger *Logger, config *Config) error {
 	if config.PreserveEnvironmentToAllContainers != "true" && config.PreserveEnvironmentToAllContainers != "false" {
 		return fmt.Errorf("Invalid configuration, unsupported PreserveEnvironmentToAllContainers: %s. Supported: true, false", config.PreserveEnvironmentToAllContainers)
 	}
-	if config.PrintLogs != "always" && config.PrintLogs != "failure" && config.PrintLogs != "never" {
+	if config.PrintLogs != "always" && config.PrintLogs != "failure" && config.PrintLogs != "never" && config.PrintLogs != "stream" {
 		return fmt.Errorf(
-			"Invalid configuration, PrintLogs supported values are: always, failure, never. It was set to: %s",
+			"Invalid configuration, PrintLogs supported values are: always, failure, never, stream. It was set to: %s",
 			config.PrintLogs)
 	}
 	if config.PrintLogsTarget != "console" && config.PrintLogsTarget != "file" {
@@ -482,7 +797,28 @@ func verifyConfig(logger *Logger, config *Config) error {
 			"Invalid configuration, PrintLogsTarget supported values are: console, file. It was set to: %s",
 			config.PrintLogsTarget)
 	}
-	if config.DockerImage == "" {
+	if config.PullPolicy != "always" && config.PullPolicy != "missing" && config.PullPolicy != "never" {
+		return fmt.Errorf(
+			"Invalid configuration, PullPolicy supported values are: always, missing, never. It was set to: %s",
+			config.PullPolicy)
+	}
+	if config.DockerBuildContext != "" {
+		if config.DockerImage != "" {
+			// both are set in the same config, otherwise the less important one was cleared when merging
+			return fmt.Errorf("Invalid configuration, DockerImage and DockerBuildContext cannot be both set")
+		}
+		if info, err := os.Stat(config.DockerBuildContext); err != nil || !info.IsDir() {
+			return fmt.Errorf("Invalid configuration, DockerBuildContext: %s is not a directory", config.DockerBuildContext)
+		}
+		dockerfile := getDockerfilePath(*config)
+		if _, err := os.Stat(dockerfile); err != nil {
+			return fmt.Errorf("Invalid configuration, Dockerfile: %s does not exist", dockerfile)
+		}
+	} else if config.Dockerfile != "" {
+		return fmt.Errorf("Invalid configuration, Dockerfile is set, but DockerBuildContext is unset")
+	}
+	// the default service of docker-compose may be built, this is verified with the docker-compose file
+	if config.DockerImage == "" && config.DockerBuildContext == "" && config.Driver != "docker-compose" {
 		return fmt.Errorf("Invalid configuration, DockerImage is unset")
 	}
 	if config.Driver == "docker-compose" {
@@ -493,10 +829,31 @@ func verifyConfig(logger *Logger, config *Config) error {
 		if _, err := os.Stat(dcFile); err != nil {
 			return fmt.Errorf("docker-compose config file: %s does not exist", dcFile)
 		}
-		if config.ExitBehavior != "abort" && config.ExitBehavior != "ignore" && config.ExitBehavior != "restart" {
+		if !dcServiceNameRegexp.MatchString(config.DockerComposeService) {
+			return fmt.Errorf(
+				"Invalid configuration, DockerComposeService must contain only letters, digits, \".\", \"_\" and \"-\". It was set to: %s",
+				config.DockerComposeService)
+		}
+		if _, err := parseExitBehaviors(config.ExitBehavior); err != nil {
+			return err
+		}
+		if healthTimeout, err := strconv.Atoi(config.HealthTimeout); err != nil || healthTimeout < 0 {
+			return fmt.Errorf(
+				"Invalid configuration, HealthTimeout must be a number of seconds, 0 for not waiting. It was set to: %s",
+				config.HealthTimeout)
+		}
+		if maxRestarts, err := strconv.Atoi(config.MaxRestarts); err != nil || maxRestarts < 0 {
+			return fmt.Errorf(
+				"Invalid configuration, MaxRestarts must be a number, 0 for no limit. It was set to: %s",
+				config.MaxRestarts)
+		}
+		if _, err := parseFailOnSidecarFailure(config.FailOnSidecarFailure); err != nil {
+			return err
+		}
+		if config.LogsArchive != "true" && config.LogsArchive != "false" {
 			return fmt.Errorf(
-				"Invalid configuration, ExitBehavior supported values are: abort, ignore, restart. It was set to: %s",
-				config.ExitBehavior)
+				"Invalid configuration, LogsArchive supported values are: true, false. It was set to: %s",
+				config.LogsArchive)
 		}
 	}
 	return nil
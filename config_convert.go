@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Translates the config file in the Dojofile format into the structured format. The values are not interpolated,
+// so that the variables are kept. The included files are not converted, only their paths are kept.
+// Returns also the warnings about what is not converted or works differently in the structured format.
+func convertConfigFile(pathToFile string) (StructuredConfig, []string, error) {
+	structuredConfig := StructuredConfig{}
+	warnings := make([]string, 0)
+	contents, err := ioutil.ReadFile(pathToFile)
+	if err != nil {
+		return structuredConfig, warnings, err
+	}
+
+	current := &structuredConfig
+	// whether the base config or the profile has any keys yet
+	hasKeys := make(map[*StructuredConfig]bool)
+	for i, line := range strings.Split(string(contents), "\n") {
+		location := fmt.Sprintf("%s:%v", pathToFile, i+1)
+		if name, isSection := parseConfigFileSection(line); isSection {
+			if !configProfileNameRegexp.MatchString(name) {
+				return structuredConfig, warnings, fmt.Errorf("%s: invalid profile name: %s. It must match: %s",
+					location, name, configProfileNameRegexp.String())
+			}
+			if structuredConfig.Profiles == nil {
+				structuredConfig.Profiles = make(map[string]*StructuredConfig)
+			}
+			if structuredConfig.Profiles[name] == nil {
+				structuredConfig.Profiles[name] = &StructuredConfig{}
+			}
+			current = structuredConfig.Profiles[name]
+			continue
+		}
+		parsedLine, err := parseConfigFileLine(line)
+		if err != nil {
+			return structuredConfig, warnings, fmt.Errorf("%s: %s", location, err)
+		}
+		if parsedLine == nil {
+			// an empty line or a comment
+			continue
+		}
+		if strings.HasPrefix(parsedLine.Key, "DOJO_") && !stringInList(parsedLine.Key, configFileKeys) {
+			warnings = append(warnings, fmt.Sprintf("%s: unknown key %s is not converted", location, parsedLine.Key))
+			continue
+		}
+		if parsedLine.Key == "DOJO_INCLUDE" && hasKeys[current] {
+			warnings = append(warnings, fmt.Sprintf("%s: DOJO_INCLUDE is below other keys. In the structured format, "+
+				"the included files are read first, so the keys above it are no longer overridden by the included file",
+				location))
+		}
+		err = current.setConfigFileValue(parsedLine.Key, parsedLine.Value)
+		if err != nil {
+			return structuredConfig, warnings, fmt.Errorf("%s: %s: %s", location, parsedLine.Key, err)
+		}
+		hasKeys[current] = true
+	}
+	return structuredConfig, warnings, nil
+}
+
+// Sets the key of a Dojofile in the structured config, the reverse of ToConfigFileLines.
+// The keys not starting with "DOJO_" are the variables.
+func (c *StructuredConfig) setConfigFileValue(key string, value string) error {
+	var err error
+	switch key {
+	case "DOJO_INCLUDE":
+		c.Include = append(c.Include, value)
+	case "DOJO_DRIVER":
+		c.Driver = value
+	case "DOJO_DOCKER_IMAGE":
+		c.Image = value
+	case "DOJO_DOCKER_BUILD_CONTEXT":
+		c.BuildContext = value
+	case "DOJO_DOCKERFILE":
+		c.Dockerfile = value
+	case "DOJO_PULL_POLICY":
+		c.PullPolicy = value
+	case "DOJO_LOG_LEVEL":
+		c.LogLevel = value
+	case "DOJO_WORK_OUTER":
+		c.WorkOuter = value
+	case "DOJO_WORK_INNER":
+		c.WorkInner = value
+	case "DOJO_IDENTITY_OUTER":
+		c.IdentityOuter = value
+	case "DOJO_DOCKER_OPTIONS":
+		c.DockerOptions, c.Volumes, err = splitDockerOptions(value)
+	case "DOJO_BLACKLIST_VARIABLES":
+		c.Blacklist = strings.Split(value, ",")
+	case "DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS":
+		c.PreserveEnvToAllContainers, err = parseStructuredBool(value)
+	case "DOJO_LOGS_DIR":
+		c.LogsDir = value
+	case "DOJO_LOGS_ARCHIVE":
+		c.LogsArchive, err = parseStructuredBool(value)
+	case "DOJO_REPORT_JSON":
+		c.ReportJSON = value
+	case "DOJO_REPORT_JUNIT":
+		c.ReportJUnit = value
+	case "DOJO_DOCKER_COMPOSE_FILE":
+		c.dockerCompose().File = value
+	case "DOJO_DOCKER_COMPOSE_OPTIONS":
+		c.dockerCompose().Options, err = splitShellWords(value)
+	case "DOJO_DOCKER_COMPOSE_SERVICE":
+		c.dockerCompose().Service = value
+	case "DOJO_DOCKER_COMPOSE_COMMAND":
+		c.dockerCompose().Command = value
+	case "DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT":
+		c.dockerCompose().HealthTimeout, err = parseStructuredInt(value)
+	case "DOJO_DOCKER_COMPOSE_MAX_RESTARTS":
+		c.dockerCompose().MaxRestarts, err = parseStructuredInt(value)
+	case "DOJO_DOCKER_COMPOSE_PRINT_LOGS":
+		c.dockerCompose().PrintLogs = value
+	case "DOJO_DOCKER_COMPOSE_PRINT_LOGS_TARGET":
+		c.dockerCompose().PrintLogsTarget = value
+	case "DOJO_EXIT_BEHAVIOR":
+		dc := c.dockerCompose()
+		dc.ExitBehavior = ""
+		for _, service := range dc.Services {
+			service.ExitBehavior = ""
+		}
+		// e.g. "db=abort,mock=restart,ignore"
+		for _, exitBehavior := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(exitBehavior), "=", 2)
+			if len(kv) == 2 {
+				dc.service(kv[0]).ExitBehavior = kv[1]
+			} else {
+				dc.ExitBehavior = kv[0]
+			}
+		}
+	case "DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE":
+		dc := c.dockerCompose()
+		dc.FailOnSidecarFailure = nil
+		for _, service := range dc.Services {
+			service.FailOnFailure = false
+		}
+		if value == "true" || value == "false" {
+			dc.FailOnSidecarFailure, err = parseStructuredBool(value)
+		} else {
+			// a list of services
+			for _, service := range strings.Split(value, ",") {
+				dc.service(strings.TrimSpace(service)).FailOnFailure = true
+			}
+		}
+	default:
+		c.Variables.set(key, value)
+	}
+	return err
+}
+
+func (c *StructuredConfig) dockerCompose() *StructuredDockerComposeConfig {
+	if c.DockerCompose == nil {
+		c.DockerCompose = &StructuredDockerComposeConfig{}
+	}
+	return c.DockerCompose
+}
+
+func (dc *StructuredDockerComposeConfig) service(name string) *StructuredServiceConfig {
+	if dc.Services == nil {
+		dc.Services = make(map[string]*StructuredServiceConfig)
+	}
+	if dc.Services[name] == nil {
+		dc.Services[name] = &StructuredServiceConfig{}
+	}
+	return dc.Services[name]
+}
+
+// Splits the docker options into the volumes (-v and --volume) and the other options
+func splitDockerOptions(value string) ([]string, []string, error) {
+	words, err := splitShellWords(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	// nil, not empty, so that they are omitted in YAML
+	var options, volumes []string
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		switch {
+		case (word == "-v" || word == "--volume") && i+1 < len(words):
+			volumes = append(volumes, words[i+1])
+			i++
+		case strings.HasPrefix(word, "--volume="):
+			volumes = append(volumes, strings.TrimPrefix(word, "--volume="))
+		default:
+			options = append(options, word)
+		}
+	}
+	return options, volumes, nil
+}
+
+func parseStructuredBool(value string) (*bool, error) {
+	if value != "true" && value != "false" {
+		return nil, fmt.Errorf("expected true or false, got: %s", value)
+	}
+	parsed := value == "true"
+	return &parsed, nil
+}
+
+func parseStructuredInt(value string) (*int, error) {
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("expected a number, got: %s", value)
+	}
+	return &parsed, nil
+}
+
+// Handles the command: dojo config convert [Dojofile], which prints the Dojofile in the structured format.
+// Returns the exit status.
+func handleConfigConvert(logger *Logger, args []string) int {
+	pathToFile := "Dojofile"
+	if len(args) > 1 {
+		logger.Log("error", fmt.Sprintf("Usage: dojo config convert [Dojofile] > Dojofile.yaml, got: %s", strings.Join(args, " ")))
+		return 1
+	}
+	if len(args) == 1 {
+		pathToFile = args[0]
+	}
+	structuredConfig, warnings, err := convertConfigFile(pathToFile)
+	for _, warning := range warnings {
+		logger.Log("warn", warning)
+	}
+	if err != nil {
+		logger.Log("error", fmt.Sprintf("Error when converting config file: %s", err))
+		return 1
+	}
+	output, err := structuredConfig.ToYAML()
+	if err != nil {
+		logger.Log("error", fmt.Sprintf("Error when converting config file: %s", err))
+		return 1
+	}
+	fmt.Print(output)
+	return 0
+}
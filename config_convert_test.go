@@ -0,0 +1,116 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConvertedConfigFile = `DOJO_DOCKER_IMAGE=alpine:3.20
+REGISTRY="docker-registry.example.com"
+DOJO_INCLUDE=Dojofile.base
+DOJO_DOCKER_IMAGE="${REGISTRY}/openjdk-dojo:${DOJO_TEST_TAG:-1.4.1}"
+DOJO_DOCKER_OPTIONS="--init -v '/tmp/m2 dir:/home/dojo/.m2' --volume=/tmp/cache:/cache -e PRICE=\$5"
+DOJO_BLACKLIST_VARIABLES="HOME,SSH_*"
+DOJO_LOGS_ARCHIVE=true
+DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT=30
+DOJO_EXIT_BEHAVIOR="abort,db=restart"
+DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE=db
+DOJO_DOCKER_IMAGES=typo
+
+[e2e]
+DOJO_DOCKER_IMAGE=alpine:3.21
+DOJO_LOG_LEVEL=debug
+`
+
+const testConvertedConfigFileYAML = `include:
+  - Dojofile.base
+variables:
+  REGISTRY: docker-registry.example.com
+image: ${REGISTRY}/openjdk-dojo:${DOJO_TEST_TAG:-1.4.1}
+dockerOptions:
+  - --init
+  - -e
+  - PRICE=$$5
+volumes:
+  - /tmp/m2 dir:/home/dojo/.m2
+  - /tmp/cache:/cache
+blacklist:
+  - HOME
+  - SSH_*
+logsArchive: true
+dockerCompose:
+  healthTimeout: 30
+  exitBehavior: abort
+  services:
+    db:
+      exitBehavior: restart
+      failOnFailure: true
+profiles:
+  e2e:
+    image: alpine:3.21
+    logLevel: debug
+`
+
+func Test_convertConfigFile(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile":      testConvertedConfigFile,
+		"Dojofile.base": "DOJO_DRIVER=docker-compose\n",
+	})
+	defer os.RemoveAll(dir)
+	pathToFile := filepath.Join(dir, "Dojofile")
+
+	structuredConfig, warnings, err := convertConfigFile(pathToFile)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		pathToFile + ":3: DOJO_INCLUDE is below other keys. In the structured format, the included files are read first, " +
+			"so the keys above it are no longer overridden by the included file",
+		pathToFile + ":11: unknown key DOJO_DOCKER_IMAGES is not converted",
+	}, warnings)
+	output, err := structuredConfig.ToYAML()
+	assert.Nil(t, err)
+	assert.Equal(t, testConvertedConfigFileYAML, output)
+
+	// the converted file is read into the same config
+	err = ioutil.WriteFile(filepath.Join(dir, "Dojofile.yaml"), []byte(output), 0644)
+	assert.Nil(t, err)
+	logger := NewLogger("debug")
+	for _, profile := range []string{"", "e2e"} {
+		config, profileConfig, err := getFileConfig(logger, pathToFile, profile, false)
+		assert.Nil(t, err)
+		convertedConfig, convertedProfileConfig, err := getFileConfig(logger, filepath.Join(dir, "Dojofile.yaml"), profile, false)
+		assert.Nil(t, err)
+		assert.Equal(t, profileConfig, convertedProfileConfig)
+		// only the quoting differs
+		assert.Equal(t, "--init -v '/tmp/m2 dir:/home/dojo/.m2' --volume=/tmp/cache:/cache -e PRICE=$5", config.DockerOptions)
+		assert.Equal(t, "--init -e PRICE=$5 -v '/tmp/m2 dir:/home/dojo/.m2' -v /tmp/cache:/cache", convertedConfig.DockerOptions)
+		config.DockerOptions = convertedConfig.DockerOptions
+		assert.Equal(t, config, convertedConfig)
+	}
+}
+
+func Test_convertConfigFile_errors(t *testing.T) {
+	type mytestStruct struct {
+		contents      string
+		expectedError string
+	}
+	mytests := []mytestStruct{
+		{contents: "DOJO_DOCKER_IMAGE\n", expectedError: ":1: expected KEY=value, got: DOJO_DOCKER_IMAGE"},
+		{contents: "DOJO_LOGS_ARCHIVE=yes\n", expectedError: ":1: DOJO_LOGS_ARCHIVE: expected true or false, got: yes"},
+		{contents: "DOJO_DOCKER_COMPOSE_MAX_RESTARTS=${MAX_RESTARTS}\n",
+			expectedError: ":1: DOJO_DOCKER_COMPOSE_MAX_RESTARTS: expected a number, got: ${MAX_RESTARTS}"},
+		{contents: "DOJO_DOCKER_OPTIONS=\"-e 'A=1\"\n", expectedError: ":1: DOJO_DOCKER_OPTIONS: unterminated single quote in: -e 'A=1"},
+		{contents: "\n[e2e alpine]\n", expectedError: ":2: invalid profile name: e2e alpine. It must match: ^[a-zA-Z0-9._-]+$"},
+	}
+	for _, v := range mytests {
+		dir := writeConfigFiles(t, map[string]string{"Dojofile": v.contents})
+		_, _, err := convertConfigFile(filepath.Join(dir, "Dojofile"))
+		os.RemoveAll(dir)
+		assert.NotNil(t, err, v.contents)
+		if err != nil {
+			assert.Equal(t, filepath.Join(dir, "Dojofile")+v.expectedError, err.Error())
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var configVariableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// The keys of the config file, which values are paths on the host. In their values, "~" is expanded.
+var configPathKeys = map[string]bool{
+	"DOJO_INCLUDE":              true,
+	"DOJO_WORK_OUTER":           true,
+	"DOJO_IDENTITY_OUTER":       true,
+	"DOJO_DOCKER_COMPOSE_FILE":  true,
+	"DOJO_DOCKER_BUILD_CONTEXT": true,
+	"DOJO_DOCKERFILE":           true,
+	"DOJO_LOGS_DIR":             true,
+	"DOJO_REPORT_JSON":          true,
+	"DOJO_REPORT_JUNIT":         true,
+}
+
+// Returns the value of the variable: from the keys set earlier in the config files or from the environment
+func lookupConfigVariable(name string, variables map[string]string) (string, bool) {
+	if value, exists := variables[name]; exists {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// Expands ${VAR} and ${VAR:-default} in the value of a config file key. The variables are looked up
+// in the keys set earlier in the config files and then in the environment. The default is used when
+// the variable is not set or is empty. The default may use variables too, e.g. ${VAR:-${OTHER}}.
+// "$$" is a literal "$". It is an error if a variable is not set and has no default.
+func interpolateConfigValue(value string, variables map[string]string) (string, error) {
+	var result strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' {
+			result.WriteByte(value[i])
+			continue
+		}
+		if strings.HasPrefix(value[i:], "$$") {
+			result.WriteByte('$')
+			i++
+			continue
+		}
+		if !strings.HasPrefix(value[i:], "${") {
+			result.WriteByte('$')
+			continue
+		}
+		end := findClosingBrace(value[i:])
+		if end == -1 {
+			return "", fmt.Errorf("missing } in: %s", value[i:])
+		}
+		expression := value[i+2 : i+end]
+		name := expression
+		defaultValue := ""
+		hasDefault := false
+		if separator := strings.Index(expression, ":-"); separator != -1 {
+			name = expression[:separator]
+			defaultValue = expression[separator+2:]
+			hasDefault = true
+		}
+		if !configVariableNameRegexp.MatchString(name) {
+			return "", fmt.Errorf("invalid variable name: %s in: ${%s}", name, expression)
+		}
+		variableValue, isSet := lookupConfigVariable(name, variables)
+		if hasDefault && variableValue == "" {
+			// like in bash, the default is interpolated only when it is used
+			var err error
+			variableValue, err = interpolateConfigValue(defaultValue, variables)
+			if err != nil {
+				return "", err
+			}
+		} else if !isSet {
+			return "", fmt.Errorf("variable: %s is not set. Set it or use a default value: ${%s:-default}", name, name)
+		}
+		result.WriteString(variableValue)
+		i += end
+	}
+	return result.String(), nil
+}
+
+// Returns the index of "}", which closes "${" at the beginning of the value, skipping the nested "${...}"
+// and "$$". Returns -1 if there is no such "}".
+func findClosingBrace(value string) int {
+	depth := 0
+	for i := 0; i < len(value); i++ {
+		switch {
+		case strings.HasPrefix(value[i:], "$$"):
+			i++
+		case strings.HasPrefix(value[i:], "${"):
+			depth++
+			i++
+		case value[i] == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// Expands "~" at the beginning of the path into the home directory of the current user
+func expandHomeDir(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, strings.TrimPrefix(path, "~"))
+}
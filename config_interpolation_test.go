@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_interpolateConfigValue(t *testing.T) {
+	os.Setenv("DOJO_TEST_IMAGE_NAME", "myimage")
+	os.Setenv("DOJO_TEST_EMPTY", "")
+	os.Unsetenv("DOJO_TEST_UNSET")
+	defer os.Unsetenv("DOJO_TEST_IMAGE_NAME")
+	defer os.Unsetenv("DOJO_TEST_EMPTY")
+	variables := map[string]string{"TAG": "1.2.3", "DOJO_TEST_IMAGE_NAME": "fromfile"}
+
+	type mytestStruct struct {
+		value         string
+		expectedValue string
+		expectedError string
+	}
+	mytests := []mytestStruct{
+		{value: "alpine:3.21", expectedValue: "alpine:3.21"},
+		{value: "registry/${DOJO_TEST_EMPTY}img:${TAG}", expectedValue: "registry/img:1.2.3"},
+		// the keys from the config file take precedence over the environment
+		{value: "registry/${DOJO_TEST_IMAGE_NAME}", expectedValue: "registry/fromfile"},
+		{value: "${DOJO_TEST_UNSET:-latest}", expectedValue: "latest"},
+		{value: "${DOJO_TEST_EMPTY:-latest}", expectedValue: "latest"},
+		{value: "${TAG:-latest}", expectedValue: "1.2.3"},
+		{value: "${DOJO_TEST_UNSET:-}", expectedValue: ""},
+		{value: "${DOJO_TEST_UNSET:-${TAG}}", expectedValue: "1.2.3"},
+		{value: "img:${DOJO_TEST_UNSET:-${DOJO_TEST_EMPTY:-v${TAG}}}-alpine", expectedValue: "img:v1.2.3-alpine"},
+		{value: "${TAG:-${DOJO_TEST_UNSET}}", expectedValue: "1.2.3"},
+		{value: "${DOJO_TEST_UNSET:-$${TAG}}", expectedValue: "${TAG}"},
+		{value: "echo $$HOME $PATH", expectedValue: "echo $HOME $PATH"},
+		{value: "$", expectedValue: "$"},
+		{value: "${DOJO_TEST_UNSET}",
+			expectedError: "variable: DOJO_TEST_UNSET is not set. Set it or use a default value: ${DOJO_TEST_UNSET:-default}"},
+		{value: "${TAG", expectedError: "missing } in: ${TAG"},
+		{value: "${DOJO_TEST_UNSET:-${TAG}", expectedError: "missing } in: ${DOJO_TEST_UNSET:-${TAG}"},
+		{value: "${DOJO_TEST_UNSET:-${DOJO_TEST_UNSET}}",
+			expectedError: "variable: DOJO_TEST_UNSET is not set. Set it or use a default value: ${DOJO_TEST_UNSET:-default}"},
+		{value: "${${TAG}}", expectedError: "invalid variable name: ${TAG} in: ${${TAG}}"},
+		{value: "${1TAG}", expectedError: "invalid variable name: 1TAG in: ${1TAG}"},
+		{value: "${:-latest}", expectedError: "invalid variable name:  in: ${:-latest}"},
+	}
+	for _, v := range mytests {
+		value, err := interpolateConfigValue(v.value, variables)
+		if v.expectedError != "" {
+			assert.NotNil(t, err, v.value)
+			assert.Equal(t, v.expectedError, err.Error())
+		} else {
+			assert.Nil(t, err, v.value)
+			assert.Equal(t, v.expectedValue, value)
+		}
+	}
+}
+
+func Test_expandHomeDir(t *testing.T) {
+	homeDir, _ := os.UserHomeDir()
+	assert.Equal(t, homeDir, expandHomeDir("~"))
+	assert.Equal(t, filepath.Join(homeDir, ".m2"), expandHomeDir("~/.m2"))
+	assert.Equal(t, "~user/.m2", expandHomeDir("~user/.m2"))
+	assert.Equal(t, "/tmp/~", expandHomeDir("/tmp/~"))
+}
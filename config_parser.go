@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The keys of the config file known to dojo. The other keys starting with "DOJO_" are reported as unknown,
+// the keys not starting with "DOJO_" are only variables, which can be used in the next values.
+var configFileKeys = []string{
+	"DOJO_INCLUDE",
+	"DOJO_DRIVER",
+	"DOJO_DOCKER_IMAGE",
+	"DOJO_DOCKER_BUILD_CONTEXT",
+	"DOJO_DOCKERFILE",
+	"DOJO_DOCKER_OPTIONS",
+	"DOJO_DOCKER_COMPOSE_FILE",
+	"DOJO_DOCKER_COMPOSE_OPTIONS",
+	"DOJO_DOCKER_COMPOSE_SERVICE",
+	"DOJO_DOCKER_COMPOSE_COMMAND",
+	"DOJO_DOCKER_COMPOSE_PRINT_LOGS",
+	"DOJO_DOCKER_COMPOSE_PRINT_LOGS_TARGET",
+	"DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT",
+	"DOJO_DOCKER_COMPOSE_MAX_RESTARTS",
+	"DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE",
+	"DOJO_LOGS_DIR",
+	"DOJO_LOGS_ARCHIVE",
+	"DOJO_REPORT_JSON",
+	"DOJO_REPORT_JUNIT",
+	"DOJO_PULL_POLICY",
+	"DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS",
+	"DOJO_WORK_OUTER",
+	"DOJO_WORK_INNER",
+	"DOJO_IDENTITY_OUTER",
+	"DOJO_EXIT_BEHAVIOR",
+	"DOJO_BLACKLIST_VARIABLES",
+	"DOJO_LOG_LEVEL",
+}
+
+// ConfigFileLine is a line of the config file: KEY=value, split into the key and the value
+type ConfigFileLine struct {
+	Key string
+	// The value without the quotes, ready to be interpolated: the "$" characters, which must not be
+	// interpolated (in single quotes or escaped with "\"), are "$$"
+	Value string
+	// The problems, which do not stop reading the line, e.g. an unknown key. They are errors in the strict mode.
+	Warnings []string
+}
+
+// Returns the name of the section (the profile), if the line starts a section: [name]
+func parseConfigFileSection(line string) (string, bool) {
+	trimmedLine := strings.TrimSpace(line)
+	if strings.HasPrefix(trimmedLine, "[") && strings.HasSuffix(trimmedLine, "]") {
+		return strings.TrimSpace(trimmedLine[1 : len(trimmedLine)-1]), true
+	}
+	return "", false
+}
+
+// Parses a line of the config file, like bash parses a variable assignment: [export] KEY=value [# comment].
+// The value may be made of unquoted, 'single-quoted' and "double-quoted" parts. In the unquoted parts, "\"
+// escapes any character. In the double-quoted parts, "\" escapes only: " \ $ and `. Unlike in bash, there may
+// be whitespace around "=" and an unquoted value may contain whitespace (this is only a warning).
+// Returns nil for an empty line or a comment.
+func parseConfigFileLine(line string) (*ConfigFileLine, error) {
+	rest := strings.TrimSpace(line)
+	if rest == "" || strings.HasPrefix(rest, "#") {
+		return nil, nil
+	}
+	if strings.HasPrefix(rest, "export ") || strings.HasPrefix(rest, "export\t") {
+		rest = strings.TrimLeft(rest[len("export"):], " \t")
+	}
+	separator := strings.Index(rest, "=")
+	if separator == -1 {
+		return nil, fmt.Errorf("expected KEY=value, got: %s", rest)
+	}
+	parsedLine := &ConfigFileLine{
+		Key:      strings.TrimRight(rest[:separator], " \t"),
+		Warnings: make([]string, 0),
+	}
+	if !configVariableNameRegexp.MatchString(parsedLine.Key) {
+		return nil, fmt.Errorf("invalid key: %s", parsedLine.Key)
+	}
+	if strings.HasPrefix(parsedLine.Key, "DOJO_") && !stringInList(parsedLine.Key, configFileKeys) {
+		warning := fmt.Sprintf("unknown key %s", parsedLine.Key)
+		if suggestion := suggestConfigFileKey(parsedLine.Key); suggestion != "" {
+			warning += fmt.Sprintf(" (did you mean %s?)", suggestion)
+		}
+		parsedLine.Warnings = append(parsedLine.Warnings, warning)
+	}
+
+	value := strings.TrimLeft(rest[separator+1:], " \t")
+	var result strings.Builder
+	unquotedWhitespace := false
+	for i := 0; i < len(value); {
+		switch value[i] {
+		case '\'':
+			end := strings.Index(value[i+1:], "'")
+			if end == -1 {
+				return nil, fmt.Errorf("%s: missing closing ' in: %s", parsedLine.Key, value[i:])
+			}
+			result.WriteString(strings.ReplaceAll(value[i+1:i+1+end], "$", "$$"))
+			i += end + 2
+		case '"':
+			end, err := parseDoubleQuoted(value[i+1:], &result)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s in: %s", parsedLine.Key, err, value[i:])
+			}
+			i += end + 2
+		case '\\':
+			if i+1 < len(value) {
+				writeEscapedChar(&result, value[i+1])
+			}
+			i += 2
+		case ' ', '\t':
+			words := strings.TrimLeft(value[i:], " \t")
+			if words == "" || strings.HasPrefix(words, "#") {
+				// the end of the value, maybe followed by an inline comment
+				i = len(value)
+				continue
+			}
+			if !unquotedWhitespace {
+				parsedLine.Warnings = append(parsedLine.Warnings,
+					fmt.Sprintf("%s: the value contains unquoted whitespace, quote the whole value", parsedLine.Key))
+				unquotedWhitespace = true
+			}
+			whitespaceEnd := len(value) - len(words)
+			result.WriteString(value[i:whitespaceEnd])
+			i = whitespaceEnd
+		default:
+			result.WriteByte(value[i])
+			i++
+		}
+	}
+	parsedLine.Value = result.String()
+	return parsedLine, nil
+}
+
+// Writes the double-quoted part of the value, which starts right after the opening quote, into result.
+// Returns the index of the closing quote.
+func parseDoubleQuoted(value string, result *strings.Builder) (int, error) {
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '"':
+			return i, nil
+		case value[i] == '\\' && i+1 < len(value) && strings.IndexByte("\"\\$`", value[i+1]) != -1:
+			writeEscapedChar(result, value[i+1])
+			i++
+		default:
+			result.WriteByte(value[i])
+		}
+	}
+	return 0, fmt.Errorf("missing closing \"")
+}
+
+func writeEscapedChar(result *strings.Builder, char byte) {
+	if char == '$' {
+		// not interpolated
+		result.WriteString("$$")
+		return
+	}
+	result.WriteByte(char)
+}
+
+// Returns the known key, which is the most similar to the unknown key, or an empty string if none is similar enough
+func suggestConfigFileKey(key string) string {
+	suggestion := ""
+	bestDistance := len(key)/3 + 1
+	for _, knownKey := range configFileKeys {
+		distance := levenshteinDistance(key, knownKey)
+		if distance < bestDistance {
+			suggestion = knownKey
+			bestDistance = distance
+		}
+	}
+	return suggestion
+}
+
+// Returns how many characters must be inserted, deleted or substituted to change a into b
+func levenshteinDistance(a string, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(minInt(previous[j]+1, current[j-1]+1), previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_parseConfigFileLine(t *testing.T) {
+	type mytestStruct struct {
+		line             string
+		expectedKey      string
+		expectedValue    string
+		expectedWarnings []string
+		expectedError    string
+	}
+	mytests := []mytestStruct{
+		{line: "DOJO_DOCKER_IMAGE=alpine:3.21", expectedKey: "DOJO_DOCKER_IMAGE", expectedValue: "alpine:3.21"},
+		{line: "export DOJO_DOCKER_IMAGE=\"alpine:3.21\"", expectedKey: "DOJO_DOCKER_IMAGE", expectedValue: "alpine:3.21"},
+		{line: "  DOJO_DOCKER_IMAGE = 'alpine:3.21'  ", expectedKey: "DOJO_DOCKER_IMAGE", expectedValue: "alpine:3.21"},
+		{line: "DOJO_DOCKER_IMAGE=alpine:3.21 # the same as in CI", expectedKey: "DOJO_DOCKER_IMAGE", expectedValue: "alpine:3.21"},
+		{line: "DOJO_DOCKER_IMAGE=\"alpine:3.21\"# the same as in CI", expectedKey: "DOJO_DOCKER_IMAGE", expectedValue: "alpine:3.21# the same as in CI",
+			expectedWarnings: []string{"DOJO_DOCKER_IMAGE: the value contains unquoted whitespace, quote the whole value"}},
+		{line: "DOJO_DOCKER_OPTIONS=\"-e MSG=\\\"a # b\\\" -e A=\\\\\" # comment", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-e MSG=\"a # b\" -e A=\\"},
+		{line: "DOJO_DOCKER_OPTIONS='-e MSG=\"$HOME\"'", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-e MSG=\"$$HOME\""},
+		{line: "DOJO_DOCKER_OPTIONS=\"-e HOME=\\$HOME -e TAG=${TAG} -e \\n\"", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-e HOME=$$HOME -e TAG=${TAG} -e \\n"},
+		{line: "DOJO_DOCKER_OPTIONS=-e\\ A=\\$B\\'", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-e A=$$B'"},
+		{line: "DOJO_DOCKER_OPTIONS=\"-e A='1'\"' -e B=\"2\"'", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-e A='1' -e B=\"2\""},
+		{line: "DOJO_DOCKER_OPTIONS=", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: ""},
+		{line: "MY_VARIABLE=a=b", expectedKey: "MY_VARIABLE", expectedValue: "a=b"},
+		{line: "DOJO_DOCKER_OPTIONS=-v /tmp:/tmp --init", expectedKey: "DOJO_DOCKER_OPTIONS", expectedValue: "-v /tmp:/tmp --init",
+			expectedWarnings: []string{"DOJO_DOCKER_OPTIONS: the value contains unquoted whitespace, quote the whole value"}},
+		{line: "DOJO_DOCKER_IMAG=alpine:3.21", expectedKey: "DOJO_DOCKER_IMAG", expectedValue: "alpine:3.21",
+			expectedWarnings: []string{"unknown key DOJO_DOCKER_IMAG (did you mean DOJO_DOCKER_IMAGE?)"}},
+		{line: "DOJO_SOMETHING_ELSE=1", expectedKey: "DOJO_SOMETHING_ELSE", expectedValue: "1",
+			expectedWarnings: []string{"unknown key DOJO_SOMETHING_ELSE"}},
+		{line: "DOJO_DOCKER_IMAGE alpine:3.21", expectedError: "expected KEY=value, got: DOJO_DOCKER_IMAGE alpine:3.21"},
+		{line: "DOJO-DOCKER-IMAGE=alpine:3.21", expectedError: "invalid key: DOJO-DOCKER-IMAGE"},
+		{line: "=alpine:3.21", expectedError: "invalid key: "},
+		{line: "DOJO_DOCKER_IMAGE=\"alpine:3.21", expectedError: "DOJO_DOCKER_IMAGE: missing closing \" in: \"alpine:3.21"},
+		{line: "DOJO_DOCKER_IMAGE='alpine:3.21", expectedError: "DOJO_DOCKER_IMAGE: missing closing ' in: 'alpine:3.21"},
+	}
+	for _, v := range mytests {
+		parsedLine, err := parseConfigFileLine(v.line)
+		if v.expectedError != "" {
+			assert.NotNil(t, err, v.line)
+			assert.Equal(t, v.expectedError, err.Error())
+			continue
+		}
+		assert.Nil(t, err, v.line)
+		assert.Equal(t, v.expectedKey, parsedLine.Key, v.line)
+		assert.Equal(t, v.expectedValue, parsedLine.Value, v.line)
+		if v.expectedWarnings == nil {
+			v.expectedWarnings = []string{}
+		}
+		assert.Equal(t, v.expectedWarnings, parsedLine.Warnings, v.line)
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "  # DOJO_DOCKER_IMAGE=alpine:3.21"} {
+		parsedLine, err := parseConfigFileLine(line)
+		assert.Nil(t, err)
+		assert.Nil(t, parsedLine, line)
+	}
+}
+
+func Test_configFileKeys(t *testing.T) {
+	for _, key := range configFileKeys {
+		if key == "DOJO_INCLUDE" {
+			continue
+		}
+		config := Config{}
+		setFileConfigValue(&config, key, "value")
+		assert.NotEqual(t, Config{}, config, key)
+	}
+}
+
+func Test_getFileConfig_strict(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile": "# the image\n" +
+			"export DOJO_DOCKER_IMAGE=\"alpine:3.21\" # the same as in CI\n" +
+			"DOJO_DOCKER_IMAG=alpine:3.20\n",
+		"Dojofile-invalid": "DOJO_DOCKER_IMAGE=alpine:3.21\n" +
+			"\n" +
+			"DOJO_DOCKER_OPTIONS\n",
+	})
+	defer os.RemoveAll(dir)
+	logger := NewLogger("debug")
+
+	config, _, err := getFileConfig(logger, filepath.Join(dir, "Dojofile"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "alpine:3.21", config.DockerImage)
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile"), "", true)
+	assert.Equal(t, filepath.Join(dir, "Dojofile")+":3: unknown key DOJO_DOCKER_IMAG (did you mean DOJO_DOCKER_IMAGE?)", err.Error())
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-invalid"), "", false)
+	assert.Equal(t, filepath.Join(dir, "Dojofile-invalid")+":3: expected KEY=value, got: DOJO_DOCKER_OPTIONS", err.Error())
+}
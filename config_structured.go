@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StructuredConfig is the config file in the structured format: YAML, e.g. Dojofile.yaml. Unlike in a Dojofile,
+// the values are typed: lists, maps, booleans and numbers. It is translated into the keys of a Dojofile,
+// e.g. dockerOptions and volumes into DOJO_DOCKER_OPTIONS, which are then read the same way as from a Dojofile.
+type StructuredConfig struct {
+	// The same as DOJO_INCLUDE, but the included files are read before the other keys
+	Include []string `yaml:"include,omitempty"`
+	// The same as the keys not starting with "DOJO_" in a Dojofile, they can be used in the values
+	Variables yamlStringMap `yaml:"variables,omitempty"`
+
+	Driver        string   `yaml:"driver,omitempty"`
+	Image         string   `yaml:"image,omitempty"`
+	BuildContext  string   `yaml:"buildContext,omitempty"`
+	Dockerfile    string   `yaml:"dockerfile,omitempty"`
+	PullPolicy    string   `yaml:"pullPolicy,omitempty"`
+	LogLevel      string   `yaml:"logLevel,omitempty"`
+	WorkOuter     string   `yaml:"workOuter,omitempty"`
+	WorkInner     string   `yaml:"workInner,omitempty"`
+	IdentityOuter string   `yaml:"identityOuter,omitempty"`
+	DockerOptions []string `yaml:"dockerOptions,omitempty"`
+	// Each volume is added to the docker options as: -v volume
+	Volumes                    []string `yaml:"volumes,omitempty"`
+	Blacklist                  []string `yaml:"blacklist,omitempty"`
+	PreserveEnvToAllContainers *bool    `yaml:"preserveEnvToAllContainers,omitempty"`
+	LogsDir                    string   `yaml:"logsDir,omitempty"`
+	LogsArchive                *bool    `yaml:"logsArchive,omitempty"`
+	ReportJSON                 string   `yaml:"reportJSON,omitempty"`
+	ReportJUnit                string   `yaml:"reportJUnit,omitempty"`
+
+	DockerCompose *StructuredDockerComposeConfig `yaml:"dockerCompose,omitempty"`
+
+	// The same as the sections of a Dojofile, selected with --profile
+	Profiles map[string]*StructuredConfig `yaml:"profiles,omitempty"`
+}
+
+// StructuredDockerComposeConfig is the part of the structured config file used only with the docker-compose driver
+type StructuredDockerComposeConfig struct {
+	File                 string   `yaml:"file,omitempty"`
+	Options              []string `yaml:"options,omitempty"`
+	Service              string   `yaml:"service,omitempty"`
+	Command              string   `yaml:"command,omitempty"`
+	HealthTimeout        *int     `yaml:"healthTimeout,omitempty"`
+	MaxRestarts          *int     `yaml:"maxRestarts,omitempty"`
+	PrintLogs            string   `yaml:"printLogs,omitempty"`
+	PrintLogsTarget      string   `yaml:"printLogsTarget,omitempty"`
+	ExitBehavior         string   `yaml:"exitBehavior,omitempty"`
+	FailOnSidecarFailure *bool    `yaml:"failOnSidecarFailure,omitempty"`
+	// The settings of the services other than the default one, by the service name
+	Services map[string]*StructuredServiceConfig `yaml:"services,omitempty"`
+}
+
+type StructuredServiceConfig struct {
+	// Overrides the exitBehavior of docker-compose for this service
+	ExitBehavior string `yaml:"exitBehavior,omitempty"`
+	// Fail, when the default container succeeded, but the container of this service failed.
+	// If no service sets it, failOnSidecarFailure of docker-compose applies to all the services.
+	FailOnFailure bool `yaml:"failOnFailure,omitempty"`
+}
+
+// yamlStringMap is a YAML mapping of strings, which keeps the order of its keys, e.g. the variables
+// are read in the order, in which they are written
+type yamlStringMap []yamlStringMapEntry
+
+type yamlStringMapEntry struct {
+	Key   string
+	Value string
+}
+
+func (m *yamlStringMap) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %v: expected a mapping, got: %s", node.Line, yamlKindToString(node))
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode := node.Content[i]
+		valueNode := node.Content[i+1]
+		if valueNode.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %v: %s: expected a string, got: %s", valueNode.Line, keyNode.Value, yamlKindToString(valueNode))
+		}
+		m.set(keyNode.Value, valueNode.Value)
+	}
+	return nil
+}
+
+func (m yamlStringMap) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, entry := range m {
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Key},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: entry.Value})
+	}
+	return node, nil
+}
+
+func (m *yamlStringMap) set(key string, value string) {
+	for i := range *m {
+		if (*m)[i].Key == key {
+			(*m)[i].Value = value
+			return
+		}
+	}
+	*m = append(*m, yamlStringMapEntry{Key: key, Value: value})
+}
+
+// Returns true for the config files in the structured format, e.g. Dojofile.yaml
+func isStructuredConfigFile(pathToFile string) bool {
+	extension := strings.ToLower(filepath.Ext(pathToFile))
+	return extension == ".yaml" || extension == ".yml"
+}
+
+var yamlUnmarshalErrorRegexp = regexp.MustCompile(`^line (\d+): (.*?)( in type main\.\w+)?$`)
+
+// Parses the config file in the structured format. The unknown fields are errors.
+func parseStructuredConfigFile(pathToFile string) (StructuredConfig, error) {
+	contents, err := ioutil.ReadFile(pathToFile)
+	if err != nil {
+		panic(err)
+	}
+	config := StructuredConfig{}
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&config)
+	if err == io.EOF {
+		// an empty file
+		return config, nil
+	}
+	if typeError, ok := err.(*yaml.TypeError); ok {
+		messages := make([]string, 0)
+		for _, message := range typeError.Errors {
+			matches := yamlUnmarshalErrorRegexp.FindStringSubmatch(message)
+			if matches != nil {
+				message = fmt.Sprintf("%s:%s: %s", pathToFile, matches[1], matches[2])
+			}
+			messages = append(messages, message)
+		}
+		return config, fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+	if err != nil {
+		matches := yamlErrorLineRegexp.FindStringSubmatch(err.Error())
+		if matches != nil {
+			return config, fmt.Errorf("%s:%s: invalid YAML: %s", pathToFile, matches[1], matches[2])
+		}
+		// returned by UnmarshalYAML
+		matches = yamlUnmarshalErrorRegexp.FindStringSubmatch(err.Error())
+		if matches != nil {
+			return config, fmt.Errorf("%s:%s: %s", pathToFile, matches[1], matches[2])
+		}
+		return config, fmt.Errorf("%s: invalid YAML: %s", pathToFile, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	return config, nil
+}
+
+// Reads the config file in the structured format into fileConfig, the same way as readConfigFile
+// reads a Dojofile: the base keys into the config of the section and the profiles into their sections.
+func readStructuredConfigFile(logger *Logger, pathToFile string, section string, fileConfig *FileConfig, includeChain []string,
+	variables map[string]string) error {
+	structuredConfig, err := parseStructuredConfigFile(pathToFile)
+	if err != nil {
+		return err
+	}
+	for _, line := range structuredConfig.ToConfigFileLines() {
+		err = readConfigFileValue(logger, pathToFile, pathToFile, section, line, fileConfig, includeChain, variables)
+		if err != nil {
+			return err
+		}
+	}
+
+	profiles := make([]string, 0)
+	for name := range structuredConfig.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	for _, name := range profiles {
+		location := fmt.Sprintf("%s: profiles.%s", pathToFile, name)
+		if !configProfileNameRegexp.MatchString(name) {
+			return fmt.Errorf("%s: invalid profile name: %s. It must match: %s", location, name, configProfileNameRegexp.String())
+		}
+		if !stringInList(name, fileConfig.Profiles) {
+			fileConfig.Profiles = append(fileConfig.Profiles, name)
+		}
+		profile := structuredConfig.Profiles[name]
+		if profile == nil {
+			continue
+		}
+		if len(profile.Profiles) > 0 {
+			return fmt.Errorf("%s: a profile cannot have profiles", location)
+		}
+		for _, line := range profile.ToConfigFileLines() {
+			err = readConfigFileValue(logger, pathToFile, location, name, line, fileConfig, includeChain, variables)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Translates the structured config (without its profiles) into the keys of a Dojofile, in the order in which
+// they are read: the variables, the included files and then the other keys. The values are not interpolated yet.
+func (c StructuredConfig) ToConfigFileLines() []ConfigFileLine {
+	lines := make([]ConfigFileLine, 0)
+	add := func(key string, value string) {
+		if value != "" {
+			lines = append(lines, ConfigFileLine{Key: key, Value: value})
+		}
+	}
+	for _, variable := range c.Variables {
+		lines = append(lines, ConfigFileLine{Key: variable.Key, Value: variable.Value})
+	}
+	for _, include := range c.Include {
+		add("DOJO_INCLUDE", include)
+	}
+	add("DOJO_DRIVER", c.Driver)
+	add("DOJO_DOCKER_IMAGE", c.Image)
+	add("DOJO_DOCKER_BUILD_CONTEXT", c.BuildContext)
+	add("DOJO_DOCKERFILE", c.Dockerfile)
+	add("DOJO_PULL_POLICY", c.PullPolicy)
+	add("DOJO_LOG_LEVEL", c.LogLevel)
+	add("DOJO_WORK_OUTER", c.WorkOuter)
+	add("DOJO_WORK_INNER", c.WorkInner)
+	add("DOJO_IDENTITY_OUTER", c.IdentityOuter)
+	dockerOptions := append([]string{}, c.DockerOptions...)
+	for _, volume := range c.Volumes {
+		dockerOptions = append(dockerOptions, "-v", volume)
+	}
+	add("DOJO_DOCKER_OPTIONS", joinShellWords(dockerOptions))
+	add("DOJO_BLACKLIST_VARIABLES", strings.Join(c.Blacklist, ","))
+	add("DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS", boolPointerToString(c.PreserveEnvToAllContainers))
+	add("DOJO_LOGS_DIR", c.LogsDir)
+	add("DOJO_LOGS_ARCHIVE", boolPointerToString(c.LogsArchive))
+	add("DOJO_REPORT_JSON", c.ReportJSON)
+	add("DOJO_REPORT_JUNIT", c.ReportJUnit)
+
+	dc := c.DockerCompose
+	if dc == nil {
+		return lines
+	}
+	add("DOJO_DOCKER_COMPOSE_FILE", dc.File)
+	add("DOJO_DOCKER_COMPOSE_OPTIONS", joinShellWords(dc.Options))
+	add("DOJO_DOCKER_COMPOSE_SERVICE", dc.Service)
+	add("DOJO_DOCKER_COMPOSE_COMMAND", dc.Command)
+	add("DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT", intPointerToString(dc.HealthTimeout))
+	add("DOJO_DOCKER_COMPOSE_MAX_RESTARTS", intPointerToString(dc.MaxRestarts))
+	add("DOJO_DOCKER_COMPOSE_PRINT_LOGS", dc.PrintLogs)
+	add("DOJO_DOCKER_COMPOSE_PRINT_LOGS_TARGET", dc.PrintLogsTarget)
+	exitBehaviors := make([]string, 0)
+	if dc.ExitBehavior != "" {
+		exitBehaviors = append(exitBehaviors, dc.ExitBehavior)
+	}
+	failOnFailureServices := make([]string, 0)
+	for _, service := range dc.sortedServices() {
+		if dc.Services[service] == nil {
+			continue
+		}
+		if dc.Services[service].ExitBehavior != "" {
+			exitBehaviors = append(exitBehaviors, fmt.Sprintf("%s=%s", service, dc.Services[service].ExitBehavior))
+		}
+		if dc.Services[service].FailOnFailure {
+			failOnFailureServices = append(failOnFailureServices, service)
+		}
+	}
+	add("DOJO_EXIT_BEHAVIOR", strings.Join(exitBehaviors, ","))
+	if len(failOnFailureServices) > 0 {
+		add("DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE", strings.Join(failOnFailureServices, ","))
+	} else {
+		add("DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE", boolPointerToString(dc.FailOnSidecarFailure))
+	}
+	return lines
+}
+
+func (dc StructuredDockerComposeConfig) sortedServices() []string {
+	services := make([]string, 0)
+	for service := range dc.Services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+func boolPointerToString(value *bool) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.FormatBool(*value)
+}
+
+func intPointerToString(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+func (c StructuredConfig) ToYAML() (string, error) {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(c)
+	if err != nil {
+		return "", err
+	}
+	err = encoder.Close()
+	if err != nil {
+		return "", err
+	}
+	return buffer.String(), nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testStructuredConfigFile = `variables:
+  REGISTRY: docker-registry.example.com
+  TAG: ${DOJO_TEST_TAG:-1.4.1}
+image: ${REGISTRY}/openjdk-dojo:${TAG}
+workInner: /dojo/work/src
+dockerOptions: [--init, --network, host]
+volumes:
+  - /tmp/m2 dir:/home/dojo/.m2
+blacklist: [HOME, SSH_*]
+preserveEnvToAllContainers: false
+logsArchive: true
+dockerCompose:
+  options: [--service-ports]
+  healthTimeout: 30
+  exitBehavior: abort
+  failOnSidecarFailure: true
+  services:
+    db:
+      exitBehavior: restart
+    mock:
+      exitBehavior: ignore
+      failOnFailure: true
+profiles:
+  e2e:
+    image: alpine:3.21
+    logLevel: debug
+`
+
+func Test_getFileConfig_structured(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile.yaml":        testStructuredConfigFile,
+		"Dojofile.base":        "DOJO_DRIVER=docker-compose\nDOJO_DOCKER_IMAGE=overridden\n",
+		"Dojofile-include.yml": "include: [Dojofile.base]\nimage: alpine:3.21\n",
+		"Dojofile-empty.yaml":  "",
+	})
+	defer os.RemoveAll(dir)
+	logger := NewLogger("debug")
+
+	config, profileConfig, err := getFileConfig(logger, filepath.Join(dir, "Dojofile.yaml"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, Config{}, profileConfig)
+	assert.Equal(t, "docker-registry.example.com/openjdk-dojo:1.4.1", config.DockerImage)
+	assert.Equal(t, "/dojo/work/src", config.WorkDirInner)
+	assert.Equal(t, "--init --network host -v '/tmp/m2 dir:/home/dojo/.m2'", config.DockerOptions)
+	assert.Equal(t, "HOME,SSH_*", config.BlacklistVariables)
+	assert.Equal(t, "false", config.PreserveEnvironmentToAllContainers)
+	assert.Equal(t, "true", config.LogsArchive)
+	assert.Equal(t, "--service-ports", config.DockerComposeOptions)
+	assert.Equal(t, "30", config.HealthTimeout)
+	assert.Equal(t, "abort,db=restart,mock=ignore", config.ExitBehavior)
+	assert.Equal(t, "mock", config.FailOnSidecarFailure)
+
+	config, profileConfig, err = getFileConfig(logger, filepath.Join(dir, "Dojofile.yaml"), "e2e", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "docker-registry.example.com/openjdk-dojo:1.4.1", config.DockerImage)
+	assert.Equal(t, "alpine:3.21", profileConfig.DockerImage)
+	assert.Equal(t, "debug", profileConfig.LogLevel)
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile.yaml"), "build", false)
+	assert.Equal(t, "Profile: build not found in config file: "+filepath.Join(dir, "Dojofile.yaml")+". Available profiles: e2e", err.Error())
+
+	config, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-include.yml"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "docker-compose", config.Driver)
+	assert.Equal(t, "alpine:3.21", config.DockerImage)
+
+	config, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-empty.yaml"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, Config{}, config)
+}
+
+func Test_getFileConfig_structuredQuoting(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile.yaml": "dockerOptions: [-e, \"GREETING=it's\", -e, 'QUOTE=\"a\"', -e, 'PATH=C:\\dojo', -e, PRICE=$$5]\n" +
+			"volumes: [/tmp/m2 dir:/home/dojo/.m2]\n" +
+			"dockerCompose:\n  options: [--env, \"NAME=it's\"]\n",
+	})
+	defer os.RemoveAll(dir)
+
+	config, _, err := getFileConfig(NewLogger("debug"), filepath.Join(dir, "Dojofile.yaml"), "", false)
+	assert.Nil(t, err)
+	words, err := splitShellWords(config.DockerOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"-e", "GREETING=it's", "-e", "QUOTE=\"a\"", "-e", "PATH=C:\\dojo", "-e", "PRICE=$5",
+		"-v", "/tmp/m2 dir:/home/dojo/.m2"}, words)
+	words, err = splitShellWords(config.DockerComposeOptions)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"--env", "NAME=it's"}, words)
+}
+
+func Test_getFileConfig_structuredErrors(t *testing.T) {
+	type mytestStruct struct {
+		contents      string
+		expectedError string
+	}
+	mytests := []mytestStruct{
+		{contents: "image: alpine:3.21\nimag: alpine:3.20\n",
+			expectedError: "Dojofile.yaml:2: field imag not found"},
+		{contents: "image: alpine:3.21\ndockerCompose:\n  healthTimeout: 1m\n",
+			expectedError: "Dojofile.yaml:3: cannot unmarshal !!str `1m` into int"},
+		{contents: "variables: [A=1]\n",
+			expectedError: "Dojofile.yaml:1: expected a mapping, got: sequence"},
+		{contents: "image: [alpine\n",
+			expectedError: "Dojofile.yaml:1: invalid YAML: did not find expected ',' or ']'"},
+		{contents: "image: ${DOJO_TEST_UNSET_TAG}\n",
+			expectedError: "Dojofile.yaml: DOJO_DOCKER_IMAGE: variable: DOJO_TEST_UNSET_TAG is not set"},
+		{contents: "profiles:\n  e2e:\n    image: ${DOJO_TEST_UNSET_TAG}\n",
+			expectedError: "Dojofile.yaml: profiles.e2e: DOJO_DOCKER_IMAGE: variable: DOJO_TEST_UNSET_TAG is not set"},
+		{contents: "profiles:\n  e2e:\n    profiles:\n      ubuntu: {}\n",
+			expectedError: "Dojofile.yaml: profiles.e2e: a profile cannot have profiles"},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		dir := writeConfigFiles(t, map[string]string{"Dojofile.yaml": v.contents})
+		_, _, err := getFileConfig(logger, filepath.Join(dir, "Dojofile.yaml"), "e2e", false)
+		os.RemoveAll(dir)
+		assert.NotNil(t, err, v.contents)
+		if err != nil {
+			assert.Contains(t, err.Error(), v.expectedError)
+		}
+	}
+}
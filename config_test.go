@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -55,26 +57,6 @@ func Test_getAbsPathOrPanic(t *testing.T) {
 	}
 }
 
-func Test_ensureNoOuterQuotes(t *testing.T) {
-	var mytests = []struct {
-		input          string
-		expectedOutput string
-	}{
-		{"", ""},
-		{"/tmp/123", "/tmp/123"},
-		{"\"/tmp/123\"", "/tmp/123"},
-		{"'/tmp/123'", "/tmp/123"},
-		{"\"/tmp/123", "\"/tmp/123"},
-		{"/tmp/123\"", "/tmp/123\""},
-		{"'/tmp/123", "'/tmp/123"},
-		{"/tmp/123'", "/tmp/123'"},
-	}
-	for _, v := range mytests {
-		outputCmd := ensureNoOuterQuotes(v.input)
-		assert.Equal(t, v.expectedOutput, outputCmd, v.input)
-	}
-}
-
 func Test_getCLIConfig(t *testing.T) {
 	oldArgs := os.Args
 	defer func() { os.Args = oldArgs }()
@@ -111,6 +93,19 @@ func Test_getCLIConfig(t *testing.T) {
 		{[]string{"cmd", "-w=/tmp/bla"}, Config{WorkDirInner: "/tmp/bla"}},
 		{[]string{"cmd", "--identity-dir-outer=/tmp/bla"}, Config{IdentityDirOuter: "/tmp/bla"}},
 		{[]string{"cmd", "--blacklist=abc,123,ABC_4"}, Config{BlacklistVariables: "abc,123,ABC_4"}},
+		{[]string{"cmd", "--docker-compose-service=app"}, Config{DockerComposeService: "app"}},
+		{[]string{"cmd", "--dcs", "app"}, Config{DockerComposeService: "app"}},
+		{[]string{"cmd", "--docker-compose-command=docker compose"}, Config{DockerComposeCommand: "docker compose"}},
+		{[]string{"cmd", "--health-timeout=30"}, Config{HealthTimeout: "30"}},
+		{[]string{"cmd", "--max-restarts=3"}, Config{MaxRestarts: "3"}},
+		{[]string{"cmd", "--fail-on-sidecar-failure=stub,db"}, Config{FailOnSidecarFailure: "stub,db"}},
+		{[]string{"cmd", "--logs-dir=/tmp/dojo-logs"}, Config{LogsDir: "/tmp/dojo-logs"}},
+		{[]string{"cmd", "--logs-archive=true"}, Config{LogsArchive: "true"}},
+		{[]string{"cmd", "--report-json=/tmp/report.json"}, Config{ReportJSON: "/tmp/report.json"}},
+		{[]string{"cmd", "--report-junit=/tmp/report.xml"}, Config{ReportJUnit: "/tmp/report.xml"}},
+		{[]string{"cmd", "--pull-policy=never"}, Config{PullPolicy: "never"}},
+		{[]string{"cmd", "--docker-build-context=/tmp/image", "--dockerfile=/tmp/Dockerfile.dev"},
+			Config{DockerBuildContext: "/tmp/image", Dockerfile: "/tmp/Dockerfile.dev"}},
 
 		{[]string{"cmd", "--action", "run", "-c", "Dojofile"}, Config{Action: "run", ConfigFile: "Dojofile", Driver: "", LogLevel: ""}},
 		{[]string{"cmd", "--action", "run", "-c", "Dojofile", "--driver", "mydriver"}, Config{Action: "run", ConfigFile: "Dojofile", Driver: "mydriver", LogLevel: ""}},
@@ -130,7 +125,7 @@ func Test_getCLIConfig(t *testing.T) {
 
 	for _, currentTest := range flagTest {
 		os.Args = currentTest.flags
-		config := getCLIConfig()
+		config, _ := getCLIConfig()
 		assert.Equal(t, currentTest.expectedConfig.Action, config.Action, currentTest.flags)
 		assert.Equal(t, currentTest.expectedConfig.ConfigFile, config.ConfigFile, currentTest.flags)
 		assert.Equal(t, currentTest.expectedConfig.Driver, config.Driver, currentTest.flags)
@@ -143,6 +138,38 @@ func Test_getCLIConfig(t *testing.T) {
 		assert.Equal(t, currentTest.expectedConfig.WorkDirInner, config.WorkDirInner, currentTest.flags)
 		assert.Equal(t, currentTest.expectedConfig.IdentityDirOuter, config.IdentityDirOuter, currentTest.flags)
 		assert.Equal(t, currentTest.expectedConfig.BlacklistVariables, config.BlacklistVariables, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.DockerComposeService, config.DockerComposeService, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.DockerComposeCommand, config.DockerComposeCommand, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.HealthTimeout, config.HealthTimeout, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.MaxRestarts, config.MaxRestarts, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.FailOnSidecarFailure, config.FailOnSidecarFailure, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.LogsDir, config.LogsDir, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.LogsArchive, config.LogsArchive, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.ReportJSON, config.ReportJSON, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.ReportJUnit, config.ReportJUnit, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.PullPolicy, config.PullPolicy, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.DockerBuildContext, config.DockerBuildContext, currentTest.flags)
+		assert.Equal(t, currentTest.expectedConfig.Dockerfile, config.Dockerfile, currentTest.flags)
+	}
+}
+
+func Test_getCLIConfig_strict(t *testing.T) {
+	oldArgs := os.Args
+	defer func() { os.Args = oldArgs }()
+
+	var flagTest = []struct {
+		flags          []string
+		expectedStrict bool
+	}{
+		{[]string{"cmd"}, false},
+		{[]string{"cmd", "--strict"}, true},
+		{[]string{"cmd", "-strict=false"}, false},
+	}
+
+	for _, currentTest := range flagTest {
+		os.Args = currentTest.flags
+		_, strict := getCLIConfig()
+		assert.Equal(t, currentTest.expectedStrict, strict, currentTest.flags)
 	}
 }
 func Test_getCLIConfig_undefinedFlag(t *testing.T) {
@@ -184,17 +211,30 @@ func Test_getFileConfig(t *testing.T) {
 	fmt.Fprintf(file, "DOJO_DRIVER=somedriver\n")
 	fmt.Fprintf(file, "DOJO_DOCKER_OPTIONS=-v /tmp/bla:/home/dojo/bla:ro -e ABC=123\n")
 	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_FILE=docker-compose.yml\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_SERVICE=app\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_COMMAND=\"docker compose\"\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_HEALTH_TIMEOUT=30\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_MAX_RESTARTS=3\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_COMPOSE_FAIL_ON_SIDECAR_FAILURE=true\n")
+	fmt.Fprintf(file, "DOJO_LOGS_ARCHIVE=true\n")
+	fmt.Fprintf(file, "DOJO_PULL_POLICY=always\n")
 	// absolute path
 	fmt.Fprintf(file, "DOJO_WORK_OUTER=/tmp/123\n")
 	// relative path
 	fmt.Fprintf(file, "DOJO_WORK_INNER=inner\n")
+	fmt.Fprintf(file, "DOJO_LOGS_DIR=dojo-logs\n")
+	fmt.Fprintf(file, "DOJO_REPORT_JSON=/tmp/report.json\n")
+	fmt.Fprintf(file, "DOJO_REPORT_JUNIT=report.xml\n")
+	fmt.Fprintf(file, "DOJO_DOCKER_BUILD_CONTEXT=image\n")
+	fmt.Fprintf(file, "DOJO_DOCKERFILE=/tmp/Dockerfile.dev\n")
 	fmt.Fprintf(file, "DOJO_IDENTITY_OUTER=/tmp/outer\n")
 	fmt.Fprintf(file, "DOJO_BLACKLIST_VARIABLES=VAR1,VAR2,ABC\n")
 	fmt.Fprintf(file, "DOJO_LOG_LEVEL=info\n")
 	fmt.Fprintf(file, "DOJO_PRESERVE_ENV_TO_ALL_CONTAINERS=false\n")
 
 	logger := NewLogger("debug")
-	config := getFileConfig(logger, configFile)
+	config, _, err := getFileConfig(logger, configFile, "", false)
+	assert.Nil(t, err)
 	expectedConfig := Config{
 		Action:                             "",
 		DockerImage:                        "docker-registry.example.com/dojo:1.3.2",
@@ -202,6 +242,14 @@ func Test_getFileConfig(t *testing.T) {
 		DockerOptions:                      "-v /tmp/bla:/home/dojo/bla:ro -e ABC=123",
 		PreserveEnvironmentToAllContainers: "false",
 		DockerComposeFile:                  "docker-compose.yml",
+		DockerComposeService:               "app",
+		DockerComposeCommand:               "docker compose",
+		HealthTimeout:                      "30",
+		MaxRestarts:                        "3",
+		FailOnSidecarFailure:               "true",
+		LogsArchive:                        "true",
+		ReportJSON:                         "/tmp/report.json",
+		PullPolicy:                         "always",
 		WorkDirOuter:                       "/tmp/123",
 		IdentityDirOuter:                   "/tmp/outer",
 		BlacklistVariables:                 "VAR1,VAR2,ABC",
@@ -214,9 +262,22 @@ func Test_getFileConfig(t *testing.T) {
 	assert.Equal(t, expectedConfig.Driver, config.Driver)
 	assert.Equal(t, expectedConfig.DockerOptions, config.DockerOptions)
 	assert.Equal(t, expectedConfig.DockerComposeFile, config.DockerComposeFile)
+	assert.Equal(t, expectedConfig.DockerComposeService, config.DockerComposeService)
+	assert.Equal(t, expectedConfig.DockerComposeCommand, config.DockerComposeCommand)
+	assert.Equal(t, expectedConfig.HealthTimeout, config.HealthTimeout)
+	assert.Equal(t, expectedConfig.MaxRestarts, config.MaxRestarts)
+	assert.Equal(t, expectedConfig.FailOnSidecarFailure, config.FailOnSidecarFailure)
+	assert.Equal(t, expectedConfig.LogsArchive, config.LogsArchive)
+	assert.Equal(t, expectedConfig.PullPolicy, config.PullPolicy)
 	assert.Equal(t, expectedConfig.WorkDirOuter, config.WorkDirOuter)
 	// relative path got saved as absolute path
 	assert.Contains(t, config.WorkDirInner, "/inner")
+	assert.Contains(t, config.LogsDir, "/dojo-logs")
+	assert.Equal(t, expectedConfig.ReportJSON, config.ReportJSON)
+	assert.Contains(t, config.ReportJUnit, "/report.xml")
+	assert.True(t, filepath.IsAbs(config.DockerBuildContext))
+	assert.Contains(t, config.DockerBuildContext, "/image")
+	assert.Equal(t, "/tmp/Dockerfile.dev", config.Dockerfile)
 	assert.Equal(t, expectedConfig.IdentityDirOuter, config.IdentityDirOuter)
 	assert.Equal(t, expectedConfig.BlacklistVariables, config.BlacklistVariables)
 	assert.Equal(t, expectedConfig.PreserveEnvironmentToAllContainers, config.PreserveEnvironmentToAllContainers)
@@ -234,7 +295,8 @@ func Test_getFileConfig_debug(t *testing.T) {
 	fmt.Fprintf(file, "DOJO_LOG_LEVEL=debug\n")
 
 	logger := NewLogger("debug")
-	config := getFileConfig(logger, configFile)
+	config, _, err := getFileConfig(logger, configFile, "", false)
+	assert.Nil(t, err)
 	expectedConfig := Config{
 		Action:   "",
 		Debug:    "true",
@@ -245,6 +307,167 @@ func Test_getFileConfig_debug(t *testing.T) {
 	assert.Equal(t, expectedConfig.LogLevel, config.LogLevel)
 }
 
+// Writes the config files into a temporary directory. Returns the directory.
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "dojo-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, contents := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		err = ioutil.WriteFile(path, []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_getFileConfig_include(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile": "DOJO_LOG_LEVEL=error\n" +
+			"DOJO_INCLUDE=shared/Dojofile.base\n" +
+			"DOJO_DOCKER_IMAGE=\"alpine:3.21\"\n",
+		"shared/Dojofile.base": "DOJO_DOCKER_IMAGE=alpine:3.19\n" +
+			"DOJO_INCLUDE=Dojofile.logs\n" +
+			"DOJO_BLACKLIST_VARIABLES=VAR1,VAR2\n" +
+			"DOJO_DOCKER_OPTIONS=--init\n",
+		"shared/Dojofile.logs": "DOJO_LOG_LEVEL=warn\n" +
+			"DOJO_DOCKER_COMPOSE_PRINT_LOGS=never\n",
+	})
+	defer os.RemoveAll(dir)
+
+	logger := NewLogger("debug")
+	config, _, err := getFileConfig(logger, filepath.Join(dir, "Dojofile"), "", false)
+	assert.Nil(t, err)
+	// set later in the including file
+	assert.Equal(t, "alpine:3.21", config.DockerImage)
+	// set earlier in the including file, overridden by the included file
+	assert.Equal(t, "warn", config.LogLevel)
+	assert.Equal(t, "VAR1,VAR2", config.BlacklistVariables)
+	assert.Equal(t, "--init", config.DockerOptions)
+	assert.Equal(t, "never", config.PrintLogs)
+}
+
+func Test_getFileConfig_includeErrors(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile-cycle":                 "DOJO_INCLUDE=shared/Dojofile.a\n",
+		"shared/Dojofile.a":              "DOJO_INCLUDE=Dojofile.b\n",
+		"shared/Dojofile.b":              "DOJO_INCLUDE=../Dojofile-cycle\n",
+		"Dojofile-self":                  "DOJO_INCLUDE=Dojofile-self\n",
+		"Dojofile-missing":               "DOJO_INCLUDE=shared/Dojofile.missing-parent\n",
+		"shared/Dojofile.missing-parent": "DOJO_INCLUDE=Dojofile.missing\n",
+		"Dojofile-diamond":               "DOJO_INCLUDE=shared/Dojofile.c\nDOJO_INCLUDE=shared/Dojofile.c\n",
+		"shared/Dojofile.c":              "DOJO_DOCKER_IMAGE=alpine:3.21\n",
+	})
+	defer os.RemoveAll(dir)
+	type mytestStruct struct {
+		file          string
+		expectedError string
+	}
+	mytests := []mytestStruct{
+		{file: "Dojofile-cycle", expectedError: fmt.Sprintf("Config files include each other in a cycle: %[1]s/Dojofile-cycle -> "+
+			"%[1]s/shared/Dojofile.a -> %[1]s/shared/Dojofile.b -> %[1]s/Dojofile-cycle", dir)},
+		{file: "Dojofile-self", expectedError: fmt.Sprintf("Config files include each other in a cycle: %[1]s/Dojofile-self -> "+
+			"%[1]s/Dojofile-self", dir)},
+		{file: "Dojofile-missing", expectedError: fmt.Sprintf("Included config file: %[1]s/shared/Dojofile.missing does not exist. "+
+			"Include chain: %[1]s/Dojofile-missing -> %[1]s/shared/Dojofile.missing-parent -> %[1]s/shared/Dojofile.missing", dir)},
+		// the same file included twice is not a cycle
+		{file: "Dojofile-diamond"},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		_, _, err := getFileConfig(logger, filepath.Join(dir, v.file), "", false)
+		if v.expectedError == "" {
+			assert.Nil(t, err, v.file)
+		} else {
+			assert.NotNil(t, err, v.file)
+			assert.Equal(t, v.expectedError, err.Error())
+		}
+	}
+}
+
+func Test_getFileConfig_interpolation(t *testing.T) {
+	os.Setenv("DOJO_TEST_IMAGE_NAME", "myimage")
+	defer os.Unsetenv("DOJO_TEST_IMAGE_NAME")
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile.base": "REGISTRY=docker-registry.example.com\n",
+		"Dojofile": "DOJO_INCLUDE=Dojofile.base\n" +
+			"DOJO_DOCKER_IMAGE=\"${REGISTRY}/${DOJO_TEST_IMAGE_NAME}:${DOJO_TEST_TAG:-latest}\"\n" +
+			"DOJO_DOCKER_OPTIONS='-e PATTERN=${NOT_INTERPOLATED}'\n" +
+			"DOJO_IDENTITY_OUTER=~/identity\n" +
+			"DOJO_WORK_OUTER=${DOJO_IDENTITY_OUTER}/work\n",
+		"Dojofile-unset": "DOJO_LOG_LEVEL=info\n" +
+			"DOJO_DOCKER_IMAGE=alpine:${DOJO_TEST_UNSET_TAG}\n",
+	})
+	defer os.RemoveAll(dir)
+	homeDir, _ := os.UserHomeDir()
+
+	logger := NewLogger("debug")
+	config, _, err := getFileConfig(logger, filepath.Join(dir, "Dojofile"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "docker-registry.example.com/myimage:latest", config.DockerImage)
+	assert.Equal(t, "-e PATTERN=${NOT_INTERPOLATED}", config.DockerOptions)
+	assert.Equal(t, filepath.Join(homeDir, "identity"), config.IdentityDirOuter)
+	assert.Equal(t, filepath.Join(homeDir, "identity", "work"), config.WorkDirOuter)
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-unset"), "", false)
+	assert.Equal(t, filepath.Join(dir, "Dojofile-unset")+":2: DOJO_DOCKER_IMAGE: variable: DOJO_TEST_UNSET_TAG is not set. "+
+		"Set it or use a default value: ${DOJO_TEST_UNSET_TAG:-default}", err.Error())
+}
+
+func Test_getFileConfig_profiles(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"Dojofile.e2e": "DOJO_DOCKER_OPTIONS=\"--init\"\n",
+		"Dojofile": "DOJO_DOCKER_IMAGE=\"alpine:3.21\"\n" +
+			"DOJO_LOG_LEVEL=debug\n" +
+			"TAG=3.21\n" +
+			"\n" +
+			"[e2e-ubuntu]\n" +
+			"DOJO_DOCKER_IMAGE=\"ubuntu:${TAG}\"\n" +
+			"DOJO_INCLUDE=Dojofile.e2e\n" +
+			"\n" +
+			"[build]\n" +
+			"DOJO_DOCKER_IMAGE=\"golang:${UNSET_IN_OTHER_PROFILE}\"\n" +
+			"[ e2e-ubuntu ]\n" +
+			"DOJO_LOG_LEVEL=info\n",
+		"Dojofile-invalid": "[e2e ubuntu]\n",
+	})
+	defer os.RemoveAll(dir)
+	logger := NewLogger("debug")
+
+	config, profileConfig, err := getFileConfig(logger, filepath.Join(dir, "Dojofile"), "", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "alpine:3.21", config.DockerImage)
+	assert.Equal(t, "debug", config.LogLevel)
+	assert.Equal(t, "", config.DockerOptions)
+	assert.Equal(t, Config{}, profileConfig)
+
+	config, profileConfig, err = getFileConfig(logger, filepath.Join(dir, "Dojofile"), "e2e-ubuntu", false)
+	assert.Nil(t, err)
+	assert.Equal(t, "alpine:3.21", config.DockerImage)
+	assert.Equal(t, "debug", config.LogLevel)
+	assert.Equal(t, "ubuntu:3.21", profileConfig.DockerImage)
+	assert.Equal(t, "--init", profileConfig.DockerOptions)
+	assert.Equal(t, "info", profileConfig.LogLevel)
+	assert.Equal(t, "false", profileConfig.Debug)
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile"), "build", false)
+	assert.Contains(t, err.Error(), ":10: DOJO_DOCKER_IMAGE: variable: UNSET_IN_OTHER_PROFILE is not set")
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile"), "e2e-alpine", false)
+	assert.Equal(t, "Profile: e2e-alpine not found in config file: "+filepath.Join(dir, "Dojofile")+
+		". Available profiles: e2e-ubuntu, build", err.Error())
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-invalid"), "", false)
+	assert.Equal(t, filepath.Join(dir, "Dojofile-invalid")+":1: invalid profile name: e2e ubuntu. It must match: ^[a-zA-Z0-9._-]+$",
+		err.Error())
+
+	_, _, err = getFileConfig(logger, filepath.Join(dir, "Dojofile-missing"), "e2e", false)
+	assert.Equal(t, "Profile: e2e is set, but config file: "+filepath.Join(dir, "Dojofile-missing")+" does not exist", err.Error())
+}
+
 func Test_getMergedConfig(t *testing.T) {
 	config1 := Config{
 		Driver: "mydriver",
@@ -264,6 +487,7 @@ func Test_getMergedConfig(t *testing.T) {
 
 	mergedConfig := getMergedConfig(config1, config2, config3)
 	assert.Equal(t, "dummy", mergedConfig.Action)
+	assert.Equal(t, "", mergedConfig.Profile)
 	assert.Equal(t, "somefile", mergedConfig.ConfigFile)
 	assert.Equal(t, "false", mergedConfig.Debug)
 	assert.Equal(t, "mydriver", mergedConfig.Driver)
@@ -277,6 +501,58 @@ func Test_getMergedConfig(t *testing.T) {
 		mergedConfig.BlacklistVariables)
 }
 
+func Test_getMergedConfig_profile(t *testing.T) {
+	cliConfig := Config{Profile: "e2e", LogLevel: "warn"}
+	profileConfig := Config{DockerImage: "ubuntu:24.10", LogLevel: "info"}
+	fileConfig := Config{DockerImage: "alpine:3.21", DockerOptions: "--init", LogLevel: "debug"}
+	defaultConfig := getDefaultConfig("somefile")
+
+	mergedConfig := getMergedConfig(cliConfig, profileConfig, fileConfig, defaultConfig)
+	assert.Equal(t, "e2e", mergedConfig.Profile)
+	assert.Equal(t, "warn", mergedConfig.LogLevel)
+	assert.Equal(t, "ubuntu:24.10", mergedConfig.DockerImage)
+	assert.Equal(t, "--init", mergedConfig.DockerOptions)
+	assert.Equal(t, "docker", mergedConfig.Driver)
+}
+
+func Test_getMergedConfig_dockerImage(t *testing.T) {
+	type mytestStruct struct {
+		cliConfig            Config
+		profileConfig        Config
+		fileConfig           Config
+		expectedImage        string
+		expectedBuildContext string
+		expectedDockerfile   string
+	}
+	mytests := []mytestStruct{
+		{cliConfig: Config{DockerBuildContext: "ctx"}, fileConfig: Config{DockerImage: "alpine:3.21"},
+			expectedBuildContext: "ctx"},
+		{profileConfig: Config{DockerBuildContext: "ctx"}, fileConfig: Config{DockerImage: "alpine:3.21"},
+			expectedBuildContext: "ctx"},
+		{cliConfig: Config{DockerImage: "alpine:3.21"},
+			fileConfig:    Config{DockerBuildContext: "ctx", Dockerfile: "ctx/Dockerfile.dev"},
+			expectedImage: "alpine:3.21"},
+		{cliConfig: Config{DockerBuildContext: "ctx2"},
+			fileConfig:           Config{DockerBuildContext: "ctx", Dockerfile: "ctx/Dockerfile.dev"},
+			expectedBuildContext: "ctx2", expectedDockerfile: "ctx/Dockerfile.dev"},
+		{profileConfig: Config{DockerImage: "ubuntu:24.10"}, fileConfig: Config{DockerImage: "alpine:3.21"},
+			expectedImage: "ubuntu:24.10"},
+		// both set in the same config, rejected by verifyConfig
+		{fileConfig: Config{DockerImage: "alpine:3.21", DockerBuildContext: "ctx"},
+			expectedImage: "alpine:3.21", expectedBuildContext: "ctx"},
+	}
+	for i, v := range mytests {
+		mergedConfig := getMergedConfig(v.cliConfig, v.profileConfig, v.fileConfig, getDefaultConfig("somefile"))
+		assert.Equal(t, v.expectedImage, mergedConfig.DockerImage, i)
+		assert.Equal(t, v.expectedBuildContext, mergedConfig.DockerBuildContext, i)
+		assert.Equal(t, v.expectedDockerfile, mergedConfig.Dockerfile, i)
+	}
+	// the configs given are not changed
+	fileConfig := Config{DockerImage: "alpine:3.21"}
+	getMergedConfig(Config{DockerBuildContext: "ctx"}, fileConfig)
+	assert.Equal(t, "alpine:3.21", fileConfig.DockerImage)
+}
+
 func Test_verifyConfig_invalidAction(t *testing.T) {
 	config := &Config{
 		Action:   "dummy",
@@ -286,7 +562,33 @@ func Test_verifyConfig_invalidAction(t *testing.T) {
 	logger := NewLogger("debug")
 	err := verifyConfig(logger, config)
 	assert.NotNil(t, err)
-	assert.Equal(t, "Invalid configuration, unsupported Action: dummy. Supported: run, pull", err.Error())
+	assert.Equal(t, "Invalid configuration, unsupported Action: dummy. Supported: run, pull, clean", err.Error())
+}
+
+func Test_verifyConfig_actionCleanNotDockerCompose(t *testing.T) {
+	config := &Config{
+		Action:   "clean",
+		Driver:   "docker",
+		LogLevel: "true",
+	}
+	logger := NewLogger("debug")
+	err := verifyConfig(logger, config)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Invalid configuration, Action: clean is supported only for driver: docker-compose", err.Error())
+}
+
+func Test_verifyConfig_dockerComposeCommandNotDockerCompose(t *testing.T) {
+	config := &Config{
+		Action:               "run",
+		Driver:               "docker",
+		Debug:                "false",
+		LogLevel:             "info",
+		DockerComposeCommand: "docker compose",
+	}
+	logger := NewLogger("debug")
+	err := verifyConfig(logger, config)
+	assert.NotNil(t, err)
+	assert.Equal(t, "DockerComposeCommand option is unsupported for driver: docker", err.Error())
 }
 
 func Test_verifyConfig_invalidDriver(t *testing.T) {
@@ -298,7 +600,7 @@ func Test_verifyConfig_invalidDriver(t *testing.T) {
 	logger := NewLogger("debug")
 	err := verifyConfig(logger, config)
 	assert.NotNil(t, err)
-	assert.Equal(t, "Invalid configuration, unsupported Driver: mydriver. Supported: docker, docker-compose", err.Error())
+	assert.Equal(t, "Invalid configuration, unsupported Driver: mydriver. Supported: docker, docker-compose, podman, docker-api", err.Error())
 }
 
 func Test_verifyConfig_invalidPrintLogs(t *testing.T) {
@@ -314,7 +616,7 @@ func Test_verifyConfig_invalidPrintLogs(t *testing.T) {
 	logger := NewLogger("debug")
 	err := verifyConfig(logger, config)
 	assert.NotNil(t, err)
-	assert.Equal(t, "Invalid configuration, PrintLogs supported values are: always, failure, never. It was set to: bla", err.Error())
+	assert.Equal(t, "Invalid configuration, PrintLogs supported values are: always, failure, never, stream. It was set to: bla", err.Error())
 }
 
 func Test_verifyConfig_invalidPrintLogsTarget(t *testing.T) {
@@ -334,6 +636,73 @@ func Test_verifyConfig_invalidPrintLogsTarget(t *testing.T) {
 	assert.Equal(t, "Invalid configuration, PrintLogsTarget supported values are: console, file. It was set to: invalid", err.Error())
 }
 
+func Test_verifyConfig_invalidPullPolicy(t *testing.T) {
+	config := &Config{
+		Action:                             "run",
+		Driver:                             "docker",
+		Debug:                              "true",
+		LogLevel:                           "info",
+		RemoveContainers:                   "true",
+		DockerImage:                        "bla",
+		PreserveEnvironmentToAllContainers: "true",
+		PrintLogs:                          "failure",
+		PrintLogsTarget:                    "console",
+		PullPolicy:                         "if-not-present",
+	}
+	logger := NewLogger("debug")
+	err := verifyConfig(logger, config)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Invalid configuration, PullPolicy supported values are: always, missing, never. It was set to: if-not-present", err.Error())
+}
+
+func Test_verifyConfig_dockerBuildContext(t *testing.T) {
+	contextDir := createTestBuildContext(t)
+	defer os.RemoveAll(filepath.Dir(contextDir))
+	type mytestStruct struct {
+		dockerImage   string
+		buildContext  string
+		dockerfile    string
+		expectedError string
+	}
+	mytests := []mytestStruct{
+		{buildContext: contextDir},
+		{buildContext: contextDir, dockerfile: filepath.Join(contextDir, "Dockerfile")},
+		{dockerImage: "bla", buildContext: contextDir,
+			expectedError: "Invalid configuration, DockerImage and DockerBuildContext cannot be both set"},
+		{buildContext: filepath.Join(contextDir, "missing"),
+			expectedError: "Invalid configuration, DockerBuildContext: " + filepath.Join(contextDir, "missing") + " is not a directory"},
+		{buildContext: contextDir, dockerfile: filepath.Join(contextDir, "Dockerfile.dev"),
+			expectedError: "Invalid configuration, Dockerfile: " + filepath.Join(contextDir, "Dockerfile.dev") + " does not exist"},
+		{dockerImage: "bla", dockerfile: filepath.Join(contextDir, "Dockerfile"),
+			expectedError: "Invalid configuration, Dockerfile is set, but DockerBuildContext is unset"},
+		{expectedError: "Invalid configuration, DockerImage is unset"},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		config := &Config{
+			Action:                             "run",
+			Driver:                             "docker",
+			Debug:                              "false",
+			LogLevel:                           "info",
+			RemoveContainers:                   "true",
+			DockerImage:                        v.dockerImage,
+			DockerBuildContext:                 v.buildContext,
+			Dockerfile:                         v.dockerfile,
+			PreserveEnvironmentToAllContainers: "true",
+			PrintLogs:                          "failure",
+			PrintLogsTarget:                    "console",
+			PullPolicy:                         "missing",
+		}
+		err := verifyConfig(logger, config)
+		if v.expectedError == "" {
+			assert.Nil(t, err, v)
+		} else {
+			assert.NotNil(t, err, v)
+			assert.Equal(t, v.expectedError, err.Error())
+		}
+	}
+}
+
 func Test_verifyConfig_driverShorthandDC(t *testing.T) {
 	dcFile := "/tmp/dojo-Test_verifyConfig_driverShorthandDC.yml"
 	config := &Config{
@@ -344,10 +713,16 @@ func Test_verifyConfig_driverShorthandDC(t *testing.T) {
 		RemoveContainers:                   "true",
 		DockerImage:                        "bla",
 		DockerComposeFile:                  dcFile,
+		DockerComposeService:               "default",
 		PreserveEnvironmentToAllContainers: "true",
 		ExitBehavior:                       "ignore",
+		HealthTimeout:                      "0",
+		MaxRestarts:                        "0",
+		FailOnSidecarFailure:               "false",
 		PrintLogs:                          "never",
 		PrintLogsTarget:                    "console",
+		PullPolicy:                         "missing",
+		LogsArchive:                        "false",
 	}
 	os.Create(dcFile)
 	logger := NewLogger("debug")
@@ -357,6 +732,120 @@ func Test_verifyConfig_driverShorthandDC(t *testing.T) {
 	os.Remove(dcFile)
 }
 
+func Test_verifyConfig_invalidDockerComposeService(t *testing.T) {
+	dcFile := "/tmp/dojo-Test_verifyConfig_invalidDockerComposeService.yml"
+	config := &Config{
+		Action:                             "run",
+		Driver:                             "docker-compose",
+		Debug:                              "false",
+		LogLevel:                           "info",
+		RemoveContainers:                   "true",
+		DockerImage:                        "bla",
+		DockerComposeFile:                  dcFile,
+		DockerComposeService:               "my app",
+		PreserveEnvironmentToAllContainers: "true",
+		ExitBehavior:                       "ignore",
+		PrintLogs:                          "never",
+		PrintLogsTarget:                    "console",
+		PullPolicy:                         "missing",
+	}
+	os.Create(dcFile)
+	defer os.Remove(dcFile)
+	logger := NewLogger("debug")
+	err := verifyConfig(logger, config)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Invalid configuration, DockerComposeService must contain only letters, digits, \".\", \"_\" and \"-\". It was set to: my app", err.Error())
+}
+
+func Test_verifyConfig_invalidHealthTimeout(t *testing.T) {
+	dcFile := "/tmp/dojo-Test_verifyConfig_invalidHealthTimeout.yml"
+	os.Create(dcFile)
+	defer os.Remove(dcFile)
+	logger := NewLogger("debug")
+	for _, healthTimeout := range []string{"", "-1", "1m"} {
+		config := &Config{
+			Action:                             "run",
+			Driver:                             "docker-compose",
+			Debug:                              "false",
+			LogLevel:                           "info",
+			RemoveContainers:                   "true",
+			DockerImage:                        "bla",
+			DockerComposeFile:                  dcFile,
+			DockerComposeService:               "default",
+			PreserveEnvironmentToAllContainers: "true",
+			ExitBehavior:                       "ignore",
+			HealthTimeout:                      healthTimeout,
+			MaxRestarts:                        "5",
+			PrintLogs:                          "never",
+			PrintLogsTarget:                    "console",
+			PullPolicy:                         "missing",
+		}
+		err := verifyConfig(logger, config)
+		assert.NotNil(t, err, healthTimeout)
+		assert.Equal(t, "Invalid configuration, HealthTimeout must be a number of seconds, 0 for not waiting. It was set to: "+healthTimeout,
+			err.Error())
+	}
+}
+
+func Test_verifyConfig_invalidMaxRestarts(t *testing.T) {
+	dcFile := "/tmp/dojo-Test_verifyConfig_invalidMaxRestarts.yml"
+	os.Create(dcFile)
+	defer os.Remove(dcFile)
+	logger := NewLogger("debug")
+	for _, maxRestarts := range []string{"", "-1", "many"} {
+		config := &Config{
+			Action:                             "run",
+			Driver:                             "docker-compose",
+			Debug:                              "false",
+			LogLevel:                           "info",
+			RemoveContainers:                   "true",
+			DockerImage:                        "bla",
+			DockerComposeFile:                  dcFile,
+			DockerComposeService:               "default",
+			PreserveEnvironmentToAllContainers: "true",
+			ExitBehavior:                       "restart",
+			HealthTimeout:                      "120",
+			MaxRestarts:                        maxRestarts,
+			PrintLogs:                          "never",
+			PrintLogsTarget:                    "console",
+			PullPolicy:                         "missing",
+		}
+		err := verifyConfig(logger, config)
+		assert.NotNil(t, err, maxRestarts)
+		assert.Equal(t, "Invalid configuration, MaxRestarts must be a number, 0 for no limit. It was set to: "+maxRestarts,
+			err.Error())
+	}
+}
+
+func Test_verifyConfig_invalidLogsArchive(t *testing.T) {
+	dcFile := "/tmp/dojo-Test_verifyConfig_invalidLogsArchive.yml"
+	os.Create(dcFile)
+	defer os.Remove(dcFile)
+	logger := NewLogger("debug")
+	config := &Config{
+		Action:                             "run",
+		Driver:                             "docker-compose",
+		Debug:                              "false",
+		LogLevel:                           "info",
+		RemoveContainers:                   "true",
+		DockerImage:                        "bla",
+		DockerComposeFile:                  dcFile,
+		DockerComposeService:               "default",
+		PreserveEnvironmentToAllContainers: "true",
+		ExitBehavior:                       "abort",
+		HealthTimeout:                      "120",
+		MaxRestarts:                        "5",
+		FailOnSidecarFailure:               "false",
+		PrintLogs:                          "never",
+		PrintLogsTarget:                    "console",
+		PullPolicy:                         "missing",
+		LogsArchive:                        "yes",
+	}
+	err := verifyConfig(logger, config)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Invalid configuration, LogsArchive supported values are: true, false. It was set to: yes", err.Error())
+}
+
 func Test_verifyConfig_logLevelStrongerPrecedence1(t *testing.T) {
 	config := &Config{
 		Action:                             "run",
@@ -368,6 +857,7 @@ func Test_verifyConfig_logLevelStrongerPrecedence1(t *testing.T) {
 		PreserveEnvironmentToAllContainers: "true",
 		PrintLogs:                          "never",
 		PrintLogsTarget:                    "console",
+		PullPolicy:                         "missing",
 	}
 	logger := NewLogger("debug")
 	err := verifyConfig(logger, config)
@@ -387,6 +877,7 @@ func Test_verifyConfig_logLevelStrongerPrecedence2(t *testing.T) {
 		PreserveEnvironmentToAllContainers: "true",
 		PrintLogs:                          "never",
 		PrintLogsTarget:                    "console",
+		PullPolicy:                         "missing",
 	}
 	logger := NewLogger("debug")
 	err := verifyConfig(logger, config)
@@ -399,6 +890,7 @@ func Test_mapToConfig(t *testing.T) {
 	mymap := make(map[string]string, 0)
 	mymap["action"] = "run"
 	mymap["config"] = "somefile"
+	mymap["profile"] = "e2e"
 	mymap["driver"] = "mydriver"
 	mymap["debug"] = "maybe"
 	mymap["logLevel"] = "maybe"
@@ -413,11 +905,23 @@ func Test_mapToConfig(t *testing.T) {
 	mymap["dockerOptions"] = "-v sth:sth"
 	mymap["dockerComposeFile"] = "aaa"
 	mymap["dockerComposeOptions"] = "--some-option"
+	mymap["dockerComposeService"] = "app"
+	mymap["dockerComposeCommand"] = "docker compose"
 	mymap["preserveEnvironmentToAllContainers"] = "false"
 	mymap["exitBehavior"] = "ignore"
+	mymap["healthTimeout"] = "120"
+	mymap["maxRestarts"] = "5"
+	mymap["failOnSidecarFailure"] = "false"
 	mymap["test"] = "false"
 	mymap["printLogs"] = "always"
 	mymap["printLogsTarget"] = "console"
+	mymap["logsDir"] = "/tmp/dojo-logs"
+	mymap["logsArchive"] = "false"
+	mymap["reportJSON"] = "/tmp/report.json"
+	mymap["reportJUnit"] = "/tmp/report.xml"
+	mymap["pullPolicy"] = "missing"
+	mymap["dockerBuildContext"] = "/tmp/image"
+	mymap["dockerfile"] = "/tmp/image/Dockerfile"
 	config := MapToConfig(mymap)
 	assert.Equal(t, "mydriver", config.Driver)
 	assert.Equal(t, "run", config.Action)
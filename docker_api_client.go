@@ -0,0 +1,467 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultDockerHost = "unix:///var/run/docker.sock"
+
+// DockerAPIError is returned when the Docker Engine API responds with a non successful status code.
+type DockerAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e DockerAPIError) Error() string {
+	return fmt.Sprintf("Docker Engine API error, status code: %v, message: %s", e.StatusCode, e.Message)
+}
+
+func isDockerAPINotFound(err error) bool {
+	apiErr, ok := err.(DockerAPIError)
+	return ok && apiErr.StatusCode == http.StatusNotFound
+}
+
+// DockerAPIClient talks to the Docker Engine HTTP API, by default over the unix socket.
+// https://docs.docker.com/engine/api/
+type DockerAPIClient struct {
+	// network and address are passed to net.Dial, e.g. "unix" and "/var/run/docker.sock"
+	network    string
+	address    string
+	baseURL    string
+	HTTPClient *http.Client
+}
+
+// dockerHost is in the same format as the DOCKER_HOST environment variable,
+// e.g. unix:///var/run/docker.sock or tcp://127.0.0.1:2375. Empty string means the default unix socket.
+func NewDockerAPIClient(dockerHost string) (*DockerAPIClient, error) {
+	if dockerHost == "" {
+		dockerHost = defaultDockerHost
+	}
+	var network, address, baseURL string
+	if strings.HasPrefix(dockerHost, "unix://") {
+		network = "unix"
+		address = strings.TrimPrefix(dockerHost, "unix://")
+		// the host part is ignored when dialing a unix socket
+		baseURL = "http://docker"
+	} else if strings.HasPrefix(dockerHost, "tcp://") {
+		network = "tcp"
+		address = strings.TrimPrefix(dockerHost, "tcp://")
+		baseURL = "http://" + address
+	} else {
+		return nil, fmt.Errorf("Unsupported DOCKER_HOST: %s. Supported schemes: unix://, tcp://", dockerHost)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("Unsupported DOCKER_HOST: %s. Address was empty", dockerHost)
+	}
+	c := &DockerAPIClient{
+		network: network,
+		address: address,
+		baseURL: baseURL,
+	}
+	c.HTTPClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return c.dial(ctx)
+			},
+		},
+	}
+	return c, nil
+}
+
+func (c *DockerAPIClient) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{}
+	return dialer.DialContext(ctx, c.network, c.address)
+}
+
+func (c *DockerAPIClient) newRequest(method string, path string, query url.Values, body interface{}) (*http.Request, error) {
+	urlStr := c.baseURL + path
+	if len(query) > 0 {
+		urlStr += "?" + query.Encode()
+	}
+	var bodyReader io.Reader
+	contentType := "application/json"
+	if reader, isReader := body.(io.Reader); isReader {
+		// a tar archive, e.g. the build context
+		bodyReader = reader
+		contentType = "application/x-tar"
+	} else if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequest(method, urlStr, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return req, nil
+}
+
+// do sends a request and returns the response if its status code is 2xx or 304.
+// The caller must close the response body.
+func (c *DockerAPIClient) do(method string, path string, query url.Values, body interface{}) (*http.Response, error) {
+	req, err := c.newRequest(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || (resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotModified) {
+		defer resp.Body.Close()
+		return nil, readDockerAPIError(resp)
+	}
+	return resp, nil
+}
+
+func readDockerAPIError(resp *http.Response) error {
+	body, _ := ioutil.ReadAll(resp.Body)
+	var errResponse struct {
+		Message string `json:"message"`
+	}
+	message := strings.TrimSuffix(string(body), "\n")
+	if json.Unmarshal(body, &errResponse) == nil && errResponse.Message != "" {
+		message = errResponse.Message
+	}
+	return DockerAPIError{StatusCode: resp.StatusCode, Message: message}
+}
+
+func (c *DockerAPIClient) doAndDecode(method string, path string, query url.Values, body interface{}, output interface{}) error {
+	resp, err := c.do(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if output == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	return json.NewDecoder(resp.Body).Decode(output)
+}
+
+type DockerAPIVersion struct {
+	Version    string `json:"Version"`
+	APIVersion string `json:"ApiVersion"`
+	Os         string `json:"Os"`
+	Arch       string `json:"Arch"`
+}
+
+func (c *DockerAPIClient) Version() (DockerAPIVersion, error) {
+	var version DockerAPIVersion
+	err := c.doAndDecode("GET", "/version", nil, nil, &version)
+	return version, err
+}
+
+type DockerAPIPortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+type DockerAPIHostConfig struct {
+	Binds        []string                          `json:"Binds,omitempty"`
+	Privileged   bool                              `json:"Privileged"`
+	Init         *bool                             `json:"Init,omitempty"`
+	NetworkMode  string                            `json:"NetworkMode,omitempty"`
+	SecurityOpt  []string                          `json:"SecurityOpt,omitempty"`
+	CapAdd       []string                          `json:"CapAdd,omitempty"`
+	ExtraHosts   []string                          `json:"ExtraHosts,omitempty"`
+	PortBindings map[string][]DockerAPIPortBinding `json:"PortBindings,omitempty"`
+}
+
+// The body of the POST /containers/create request
+type DockerAPIContainerConfig struct {
+	Image        string              `json:"Image"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Env          []string            `json:"Env,omitempty"`
+	User         string              `json:"User,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Tty          bool                `json:"Tty"`
+	OpenStdin    bool                `json:"OpenStdin"`
+	StdinOnce    bool                `json:"StdinOnce"`
+	AttachStdin  bool                `json:"AttachStdin"`
+	AttachStdout bool                `json:"AttachStdout"`
+	AttachStderr bool                `json:"AttachStderr"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   DockerAPIHostConfig `json:"HostConfig"`
+}
+
+// Returns: the ID of the created container
+func (c *DockerAPIClient) CreateContainer(name string, config DockerAPIContainerConfig) (string, error) {
+	query := url.Values{}
+	query.Set("name", name)
+	var output struct {
+		ID       string   `json:"Id"`
+		Warnings []string `json:"Warnings"`
+	}
+	err := c.doAndDecode("POST", "/containers/create", query, config, &output)
+	return output.ID, err
+}
+
+func (c *DockerAPIClient) StartContainer(id string) error {
+	return c.doAndDecode("POST", fmt.Sprintf("/containers/%s/start", id), nil, nil, nil)
+}
+
+func (c *DockerAPIClient) StopContainer(id string) error {
+	return c.doAndDecode("POST", fmt.Sprintf("/containers/%s/stop", id), nil, nil, nil)
+}
+
+func (c *DockerAPIClient) KillContainer(id string) error {
+	return c.doAndDecode("POST", fmt.Sprintf("/containers/%s/kill", id), nil, nil, nil)
+}
+
+func (c *DockerAPIClient) ResizeContainerTTY(id string, height uint16, width uint16) error {
+	query := url.Values{}
+	query.Set("h", fmt.Sprint(height))
+	query.Set("w", fmt.Sprint(width))
+	return c.doAndDecode("POST", fmt.Sprintf("/containers/%s/resize", id), query, nil, nil)
+}
+
+// Removes the container together with its anonymous volumes, like "docker run --rm" does.
+func (c *DockerAPIClient) RemoveContainer(id string) error {
+	query := url.Values{}
+	query.Set("v", "1")
+	return c.doAndDecode("DELETE", fmt.Sprintf("/containers/%s", id), query, nil, nil)
+}
+
+// Blocks until the container reaches the condition: not-running, next-exit or removed. Returns the container exit code.
+func (c *DockerAPIClient) WaitContainer(id string, condition string) (int, error) {
+	query := url.Values{}
+	query.Set("condition", condition)
+	var output struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	err := c.doAndDecode("POST", fmt.Sprintf("/containers/%s/wait", id), query, nil, &output)
+	if err != nil {
+		return -1, err
+	}
+	if output.Error != nil && output.Error.Message != "" {
+		return output.StatusCode, fmt.Errorf("Error when waiting for container %s: %s", id, output.Error.Message)
+	}
+	return output.StatusCode, nil
+}
+
+// DockerAPIContainerJSON is the subset of GET /containers/{id}/json response that dojo needs.
+type DockerAPIContainerJSON struct {
+	ID    string `json:"Id"`
+	Name  string `json:"Name"`
+	Image string `json:"Image"`
+	State struct {
+		Status   string `json:"Status"`
+		Running  bool   `json:"Running"`
+		ExitCode int    `json:"ExitCode"`
+	} `json:"State"`
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+}
+
+// The counterpart of getContainerInfo. A container, which does not exist, is not an error.
+func (c *DockerAPIClient) InspectContainer(id string) (*ContainerInfo, error) {
+	var output DockerAPIContainerJSON
+	err := c.doAndDecode("GET", fmt.Sprintf("/containers/%s/json", id), nil, nil, &output)
+	if err != nil {
+		if isDockerAPINotFound(err) {
+			return &ContainerInfo{Exists: false}, nil
+		}
+		return &ContainerInfo{}, err
+	}
+	return &ContainerInfo{
+		ID:       output.ID,
+		Name:     strings.TrimPrefix(output.Name, "/"),
+		Status:   output.State.Status,
+		ExitCode: fmt.Sprint(output.State.ExitCode),
+		Exists:   true,
+	}, nil
+}
+
+type DockerAPIPullMessage struct {
+	Status   string `json:"status"`
+	ID       string `json:"id"`
+	Progress string `json:"progress"`
+	Error    string `json:"error"`
+}
+
+// Pulls the image. Each progress message is written as 1 line to the progress writer.
+func (c *DockerAPIClient) PullImage(image string, progress io.Writer) error {
+	name, tag := splitImageTag(image)
+	query := url.Values{}
+	query.Set("fromImage", name)
+	query.Set("tag", tag)
+	resp, err := c.do("POST", "/images/create", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg DockerAPIPullMessage
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			// the status code was already sent, errors during pull are reported in the stream
+			return DockerAPIError{StatusCode: resp.StatusCode, Message: msg.Error}
+		}
+		if progress != nil {
+			line := strings.TrimSpace(strings.Join([]string{msg.ID, msg.Status, msg.Progress}, " "))
+			fmt.Fprintln(progress, line)
+		}
+	}
+}
+
+type DockerAPIBuildMessage struct {
+	Stream string `json:"stream"`
+	Error  string `json:"error"`
+}
+
+// Builds the image from the build context (a tar archive) and tags it. The dockerfile is the path
+// of the Dockerfile in the build context. The build output is written to the progress writer.
+func (c *DockerAPIClient) BuildImage(buildContext io.Reader, tag string, dockerfile string, progress io.Writer) error {
+	query := url.Values{}
+	query.Set("t", tag)
+	query.Set("dockerfile", dockerfile)
+	resp, err := c.do("POST", "/build", query, buildContext)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg DockerAPIBuildMessage
+		err := decoder.Decode(&msg)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			// the status code was already sent, errors during build are reported in the stream
+			return DockerAPIError{StatusCode: resp.StatusCode, Message: msg.Error}
+		}
+		if progress != nil {
+			fmt.Fprint(progress, msg.Stream)
+		}
+	}
+}
+
+// Returns the repository digests of the image, e.g. alpine@sha256:1234. An image built locally has no digests.
+func (c *DockerAPIClient) InspectImageDigests(image string) ([]string, error) {
+	var output struct {
+		RepoDigests []string `json:"RepoDigests"`
+	}
+	err := c.doAndDecode("GET", fmt.Sprintf("/images/%s/json", image), nil, nil, &output)
+	if err != nil {
+		return nil, err
+	}
+	return output.RepoDigests, nil
+}
+
+// Splits an image reference into name and tag. Digests are kept in the name.
+// E.g. alpine:3.21 -> alpine, 3.21 and localhost:5000/img -> localhost:5000/img, latest
+func splitImageTag(image string) (string, string) {
+	if strings.Contains(image, "@") {
+		return image, ""
+	}
+	lastColon := strings.LastIndex(image, ":")
+	lastSlash := strings.LastIndex(image, "/")
+	if lastColon == -1 || lastColon < lastSlash {
+		return image, "latest"
+	}
+	return image[:lastColon], image[lastColon+1:]
+}
+
+// Attaches to the container streams. The connection is hijacked from HTTP, so that
+// it can be used to read the container output and to write the container input.
+// Returns: the connection (to write stdin and to close), a reader for the output stream.
+func (c *DockerAPIClient) AttachContainer(id string, stdin bool) (net.Conn, *bufio.Reader, error) {
+	query := url.Values{}
+	query.Set("stream", "1")
+	query.Set("stdout", "1")
+	query.Set("stderr", "1")
+	if stdin {
+		query.Set("stdin", "1")
+	}
+	req, err := c.newRequest("POST", fmt.Sprintf("/containers/%s/attach", id), query, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	conn, err := c.dial(context.Background())
+	if err != nil {
+		return nil, nil, err
+	}
+	err = req.Write(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, readDockerAPIError(resp)
+	}
+	return conn, reader, nil
+}
+
+// When a container has no TTY, the attached stream multiplexes stdout and stderr. Each frame has an 8 bytes header:
+// [STREAM_TYPE, 0, 0, 0, SIZE1, SIZE2, SIZE3, SIZE4], where STREAM_TYPE is 1 for stdout and 2 for stderr
+// and SIZE is big endian uint32 of the frame payload.
+// https://docs.docker.com/engine/api/v1.41/#operation/ContainerAttach
+func demuxDockerStream(input io.Reader, stdout io.Writer, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		_, err := io.ReadFull(input, header)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:8]))
+		var target io.Writer
+		switch header[0] {
+		case 0, 1:
+			target = stdout
+		case 2:
+			target = stderr
+		default:
+			return fmt.Errorf("Unexpected stream type: %v in the attached stream", header[0])
+		}
+		_, err = io.CopyN(target, input, size)
+		if err != nil {
+			return err
+		}
+	}
+}
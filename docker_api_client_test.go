@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Starts a fake Docker Engine listening on a unix socket.
+// Returns: a client connected to it and a function which stops the fake Docker Engine.
+func startFakeDockerEngine(t *testing.T, handler http.Handler) (*DockerAPIClient, func()) {
+	// unix socket paths are limited to ~100 characters, t.TempDir() may be too long
+	dir, err := ioutil.TempDir("", "dojo-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	socketPath := filepath.Join(dir, "docker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(handler)
+	server.Listener = listener
+	server.Start()
+	client, err := NewDockerAPIClient("unix://" + socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, func() {
+		server.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// Returns a frame of the multiplexed attach stream
+func dockerStreamFrame(streamType byte, payload string) []byte {
+	header := []byte{streamType, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(payload)))
+	return append(header, []byte(payload)...)
+}
+
+func TestNewDockerAPIClient(t *testing.T) {
+	type mytestStruct struct {
+		dockerHost  string
+		expNetwork  string
+		expAddress  string
+		expBaseURL  string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{dockerHost: "", expNetwork: "unix", expAddress: "/var/run/docker.sock", expBaseURL: "http://docker"},
+		mytestStruct{dockerHost: "unix:///tmp/d.sock", expNetwork: "unix", expAddress: "/tmp/d.sock", expBaseURL: "http://docker"},
+		mytestStruct{dockerHost: "tcp://127.0.0.1:2375", expNetwork: "tcp", expAddress: "127.0.0.1:2375", expBaseURL: "http://127.0.0.1:2375"},
+		mytestStruct{dockerHost: "ssh://me@host", expErrorMsg: "Unsupported DOCKER_HOST: ssh://me@host. Supported schemes: unix://, tcp://"},
+		mytestStruct{dockerHost: "tcp://", expErrorMsg: "Unsupported DOCKER_HOST: tcp://. Address was empty"},
+	}
+	for _, v := range mytests {
+		client, err := NewDockerAPIClient(v.dockerHost)
+		if v.expErrorMsg != "" {
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.dockerHost)
+			continue
+		}
+		assert.Nil(t, err, v.dockerHost)
+		assert.Equal(t, v.expNetwork, client.network, v.dockerHost)
+		assert.Equal(t, v.expAddress, client.address, v.dockerHost)
+		assert.Equal(t, v.expBaseURL, client.baseURL, v.dockerHost)
+	}
+}
+
+func TestDockerAPIClient_Version(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/version", r.URL.Path)
+		fmt.Fprint(w, `{"Version":"27.4.1","ApiVersion":"1.47","Os":"linux","Arch":"amd64"}`)
+	}))
+	defer stop()
+	version, err := client.Version()
+	assert.Nil(t, err)
+	assert.Equal(t, "27.4.1", version.Version)
+	assert.Equal(t, "1.47", version.APIVersion)
+}
+
+func TestDockerAPIClient_InspectContainer(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/containers/name1/json" {
+			fmt.Fprint(w, `{"Id":"1234","Name":"/name1","State":{"Status":"exited","Running":false,"ExitCode":3}}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such container: name2"}`)
+	}))
+	defer stop()
+	info, err := client.InspectContainer("name1")
+	assert.Nil(t, err)
+	assert.Equal(t, &ContainerInfo{ID: "1234", Name: "name1", Status: "exited", ExitCode: "3", Exists: true}, info)
+
+	info, err = client.InspectContainer("name2")
+	assert.Nil(t, err)
+	assert.False(t, info.Exists)
+}
+
+func TestDockerAPIClient_InspectImageDigests(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/images/alpine:3.21/json" {
+			fmt.Fprint(w, `{"Id":"sha256:1234","RepoDigests":["alpine@sha256:abcd"]}`)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such image: local:1"}`)
+	}))
+	defer stop()
+	digests, err := client.InspectImageDigests("alpine:3.21")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alpine@sha256:abcd"}, digests)
+
+	_, err = client.InspectImageDigests("local:1")
+	assert.True(t, isDockerAPINotFound(err))
+}
+
+func TestDockerAPIClient_Error(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+		fmt.Fprint(w, `{"message":"Conflict. The container name \"/name1\" is already in use"}`)
+	}))
+	defer stop()
+	_, err := client.CreateContainer("name1", DockerAPIContainerConfig{Image: "img:1.2.3"})
+	assert.NotNil(t, err)
+	assert.False(t, isDockerAPINotFound(err))
+	assert.Equal(t, DockerAPIError{StatusCode: 409, Message: "Conflict. The container name \"/name1\" is already in use"}, err)
+}
+
+func TestDockerAPIClient_PullImage(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/images/create", r.URL.Path)
+		if r.URL.Query().Get("fromImage") == "bad" {
+			fmt.Fprint(w, `{"error":"pull access denied for bad"}`)
+			return
+		}
+		assert.Equal(t, "img", r.URL.Query().Get("fromImage"))
+		assert.Equal(t, "1.2.3", r.URL.Query().Get("tag"))
+		fmt.Fprint(w, `{"status":"Pulling from library/img","id":"1.2.3"}`+"\n"+`{"status":"Downloaded newer image for img:1.2.3"}`)
+	}))
+	defer stop()
+	var progress bytes.Buffer
+	err := client.PullImage("img:1.2.3", &progress)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.2.3 Pulling from library/img\nDownloaded newer image for img:1.2.3\n", progress.String())
+
+	err = client.PullImage("bad", nil)
+	assert.Equal(t, "pull access denied for bad", err.(DockerAPIError).Message)
+}
+
+func TestDockerAPIClient_BuildImage(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/build", r.URL.Path)
+		assert.Equal(t, "application/x-tar", r.Header.Get("Content-Type"))
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.URL.Query().Get("t") == "bad:1" {
+			fmt.Fprint(w, `{"stream":"Step 1/1 : FROM missing\n"}`+"\n"+`{"error":"pull access denied for missing"}`)
+			return
+		}
+		assert.Equal(t, "img:1.2.3", r.URL.Query().Get("t"))
+		assert.Equal(t, ".dojo.Dockerfile", r.URL.Query().Get("dockerfile"))
+		assert.Equal(t, "context", string(body))
+		fmt.Fprint(w, `{"stream":"Step 1/1 : FROM alpine:3.21\n"}`+"\n"+`{"stream":"Successfully tagged img:1.2.3\n"}`)
+	}))
+	defer stop()
+	var progress bytes.Buffer
+	err := client.BuildImage(strings.NewReader("context"), "img:1.2.3", ".dojo.Dockerfile", &progress)
+	assert.Nil(t, err)
+	assert.Equal(t, "Step 1/1 : FROM alpine:3.21\nSuccessfully tagged img:1.2.3\n", progress.String())
+
+	err = client.BuildImage(strings.NewReader("context"), "bad:1", "Dockerfile", nil)
+	assert.Equal(t, "pull access denied for missing", err.(DockerAPIError).Message)
+}
+
+func TestDockerAPIClient_WaitContainer(t *testing.T) {
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/containers/1234/wait", r.URL.Path)
+		assert.Equal(t, "not-running", r.URL.Query().Get("condition"))
+		fmt.Fprint(w, `{"StatusCode":7}`)
+	}))
+	defer stop()
+	exitCode, err := client.WaitContainer("1234", "not-running")
+	assert.Nil(t, err)
+	assert.Equal(t, 7, exitCode)
+}
+
+func Test_splitImageTag(t *testing.T) {
+	type mytestStruct struct {
+		image   string
+		expName string
+		expTag  string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{image: "alpine:3.21", expName: "alpine", expTag: "3.21"},
+		mytestStruct{image: "alpine", expName: "alpine", expTag: "latest"},
+		mytestStruct{image: "localhost:5000/img", expName: "localhost:5000/img", expTag: "latest"},
+		mytestStruct{image: "localhost:5000/img:1.0", expName: "localhost:5000/img", expTag: "1.0"},
+		mytestStruct{image: "img@sha256:abcd", expName: "img@sha256:abcd", expTag: ""},
+	}
+	for _, v := range mytests {
+		name, tag := splitImageTag(v.image)
+		assert.Equal(t, v.expName, name, v.image)
+		assert.Equal(t, v.expTag, tag, v.image)
+	}
+}
+
+func Test_demuxDockerStream(t *testing.T) {
+	var input bytes.Buffer
+	input.Write(dockerStreamFrame(1, "out1\n"))
+	input.Write(dockerStreamFrame(2, "err1\n"))
+	input.Write(dockerStreamFrame(1, "out2\n"))
+	var stdout, stderr bytes.Buffer
+	err := demuxDockerStream(&input, &stdout, &stderr)
+	assert.Nil(t, err)
+	assert.Equal(t, "out1\nout2\n", stdout.String())
+	assert.Equal(t, "err1\n", stderr.String())
+
+	err = demuxDockerStream(bytes.NewReader([]byte{5, 0, 0, 0, 0, 0, 0, 1, 'a'}), &stdout, &stderr)
+	assert.Equal(t, "Unexpected stream type: 5 in the attached stream", err.Error())
+}
@@ -0,0 +1,538 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// DockerAPIDriver runs the container through the Docker Engine HTTP API, instead of shelling out
+// to the docker CLI, like DockerDriver does.
+type DockerAPIDriver struct {
+	Client *DockerAPIClient
+	// ShellService is used only to check whether the current shell is interactive
+	ShellService ShellServiceInterface
+	FileService  FileServiceInterface
+	Logger       *Logger
+	Stdin        io.Reader
+	Stdout       io.Writer
+	Stderr       io.Writer
+
+	ReportContainers *RunReportContainers
+}
+
+func NewDockerAPIDriver(client *DockerAPIClient, shellService ShellServiceInterface, fs FileServiceInterface, logger *Logger) DockerAPIDriver {
+	if client == nil {
+		panic(errors.New("client was nil"))
+	}
+	if shellService == nil {
+		panic(errors.New("shellService was nil"))
+	}
+	if fs == nil {
+		panic(errors.New("fs was nil"))
+	}
+	if logger == nil {
+		panic(errors.New("logger was nil"))
+	}
+	return DockerAPIDriver{
+		Client:       client,
+		ShellService: shellService,
+		FileService:  fs,
+		Logger:       logger,
+		Stdin:        os.Stdin,
+		Stdout:       os.Stdout,
+		Stderr:       os.Stderr,
+
+		ReportContainers: NewRunReportContainers(),
+	}
+}
+
+// Translates the docker run options (DockerOptions) into the container config. Only the most common options
+// are supported, because the docker CLI options do not map 1:1 onto the Docker Engine API.
+func parseDockerOptions(options string, containerConfig *DockerAPIContainerConfig) error {
+	words, err := splitShellWords(options)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(words); i++ {
+		option := words[i]
+		value := ""
+		hasValue := false
+		if strings.HasPrefix(option, "-") && strings.Contains(option, "=") {
+			kv := strings.SplitN(option, "=", 2)
+			option = kv[0]
+			value = kv[1]
+			hasValue = true
+		}
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i+1 >= len(words) {
+				return "", fmt.Errorf("docker option: %s requires a value", option)
+			}
+			i++
+			return words[i], nil
+		}
+		switch option {
+		case "--privileged":
+			containerConfig.HostConfig.Privileged = value != "false"
+		case "--init":
+			init := value != "false"
+			containerConfig.HostConfig.Init = &init
+		case "-v", "--volume":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.HostConfig.Binds = append(containerConfig.HostConfig.Binds, v)
+		case "-e", "--env":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			if !strings.Contains(v, "=") {
+				// the same as docker CLI does: take the value from the current environment
+				hostValue, ok := os.LookupEnv(v)
+				if !ok {
+					continue
+				}
+				v = fmt.Sprintf("%s=%s", v, hostValue)
+			}
+			containerConfig.Env = append(containerConfig.Env, v)
+		case "-u", "--user":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.User = v
+		case "-w", "--workdir":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.WorkingDir = v
+		case "--entrypoint":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.Entrypoint = []string{v}
+		case "--network", "--net":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.HostConfig.NetworkMode = v
+		case "--security-opt":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.HostConfig.SecurityOpt = append(containerConfig.HostConfig.SecurityOpt, v)
+		case "--cap-add":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.HostConfig.CapAdd = append(containerConfig.HostConfig.CapAdd, v)
+		case "--add-host":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			containerConfig.HostConfig.ExtraHosts = append(containerConfig.HostConfig.ExtraHosts, v)
+		case "-p", "--publish":
+			v, err := takeValue()
+			if err != nil {
+				return err
+			}
+			err = addPortBinding(v, containerConfig)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("docker option: %s is unsupported for driver: docker-api", option)
+		}
+	}
+	return nil
+}
+
+// Supported formats: containerPort, hostPort:containerPort, hostIP:hostPort:containerPort;
+// containerPort may end with /tcp or /udp
+func addPortBinding(portMapping string, containerConfig *DockerAPIContainerConfig) error {
+	parts := strings.Split(portMapping, ":")
+	var hostIP, hostPort, containerPort string
+	switch len(parts) {
+	case 1:
+		containerPort = parts[0]
+	case 2:
+		hostPort, containerPort = parts[0], parts[1]
+	case 3:
+		hostIP, hostPort, containerPort = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid port mapping: %s", portMapping)
+	}
+	if containerPort == "" {
+		return fmt.Errorf("invalid port mapping: %s", portMapping)
+	}
+	if !strings.Contains(containerPort, "/") {
+		containerPort += "/tcp"
+	}
+	if containerConfig.ExposedPorts == nil {
+		containerConfig.ExposedPorts = make(map[string]struct{})
+	}
+	if containerConfig.HostConfig.PortBindings == nil {
+		containerConfig.HostConfig.PortBindings = make(map[string][]DockerAPIPortBinding)
+	}
+	containerConfig.ExposedPorts[containerPort] = struct{}{}
+	containerConfig.HostConfig.PortBindings[containerPort] = append(containerConfig.HostConfig.PortBindings[containerPort],
+		DockerAPIPortBinding{HostIP: hostIP, HostPort: hostPort})
+	return nil
+}
+
+// The counterpart of DockerDriver.ConstructDockerRunCmd.
+// envVariables replace the env file, each element is of format: VariableName=VariableValue
+func (d DockerAPIDriver) ConstructContainerConfig(config Config, envVariables []string, envFileMultiLine string, envFileBashFunctions string) (DockerAPIContainerConfig, error) {
+	containerConfig := DockerAPIContainerConfig{
+		Image:        config.DockerImage,
+		Env:          envVariables,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+	containerConfig.HostConfig.Binds = []string{
+		fmt.Sprintf("%s:%s", config.WorkDirOuter, config.WorkDirInner),
+		fmt.Sprintf("%s:/dojo/identity:ro", config.IdentityDirOuter),
+		fmt.Sprintf("%s:/etc/dojo.d/variables/00-multiline-vars.sh", envFileMultiLine),
+		fmt.Sprintf("%s:/etc/dojo.d/variables/01-bash-functions.sh", envFileBashFunctions),
+	}
+	if os.Getenv("DISPLAY") != "" {
+		// DISPLAY is set, enable running in graphical mode (opinionated)
+		containerConfig.HostConfig.Binds = append(containerConfig.HostConfig.Binds, "/tmp/.X11-unix:/tmp/.X11-unix")
+	}
+	if config.DockerOptions != "" {
+		err := parseDockerOptions(config.DockerOptions, &containerConfig)
+		if err != nil {
+			return DockerAPIContainerConfig{}, err
+		}
+	}
+	shellIsInteractive := d.ShellService.CheckIfInteractive()
+	if config.Interactive == "true" || (config.Interactive == "" && shellIsInteractive) {
+		containerConfig.Tty = true
+		containerConfig.OpenStdin = true
+		containerConfig.StdinOnce = true
+		containerConfig.AttachStdin = true
+	}
+	if config.RunCommand != "" {
+		cmd, err := splitShellWords(config.RunCommand)
+		if err != nil {
+			return DockerAPIContainerConfig{}, err
+		}
+		containerConfig.Cmd = cmd
+	}
+	return containerConfig, nil
+}
+
+func (d DockerAPIDriver) PrintVersion() {
+	version, err := d.Client.Version()
+	if err != nil {
+		d.Logger.Log("debug", fmt.Sprintf("Error when getting Docker Engine version: %s", err))
+	} else {
+		d.Logger.Log("info", fmt.Sprintf("Docker Engine version %s, API version %s", version.Version, version.APIVersion))
+	}
+}
+
+// Creates the container and pulls the image if it does not exist locally, like "docker run" does.
+func (d DockerAPIDriver) createContainer(name string, containerConfig DockerAPIContainerConfig) (string, error) {
+	id, err := d.Client.CreateContainer(name, containerConfig)
+	if err == nil || !isDockerAPINotFound(err) {
+		return id, err
+	}
+	d.Logger.Log("info", fmt.Sprintf("Unable to find image '%s' locally, pulling it", containerConfig.Image))
+	err = d.Client.PullImage(containerConfig.Image, d.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return d.Client.CreateContainer(name, containerConfig)
+}
+
+// Builds the image from the build context, unless an image with that tag already exists,
+// the same way as buildImageIfMissing does for the docker CLI.
+func (d DockerAPIDriver) buildImageIfMissing(config Config) error {
+	image := config.DockerImage
+	_, err := d.Client.InspectImageDigests(image)
+	if err == nil {
+		d.Logger.Log("info", fmt.Sprintf("Image: %s is already built, the build context did not change", image))
+		return nil
+	}
+	if !isDockerAPINotFound(err) {
+		return fmt.Errorf("Inspecting image: %s failed: %s", image, err)
+	}
+	buildContext, dockerfile, err := tarBuildContext(config.DockerBuildContext, getDockerfilePath(config))
+	if err != nil {
+		return fmt.Errorf("Error when packing the build context: %s. %s", config.DockerBuildContext, err)
+	}
+	d.Logger.Log("info", green(fmt.Sprintf("docker image: %s will be built from: %s", image, config.DockerBuildContext)))
+	err = d.Client.BuildImage(buildContext, image, dockerfile, d.Stderr)
+	if err != nil {
+		return fmt.Errorf("Building image: %s failed: %s", image, err)
+	}
+	return nil
+}
+
+// Pulls the image according to the pull policy, the same way as pullImageWithPolicy does for the docker CLI.
+func (d DockerAPIDriver) pullImageWithPolicy(image string, policy string) error {
+	if policy != "always" {
+		_, err := d.Client.InspectImageDigests(image)
+		if err == nil {
+			d.Logger.Log("debug", fmt.Sprintf("Image: %s is present locally, not pulling it (pull policy: %s)", image, policy))
+			return nil
+		}
+		if !isDockerAPINotFound(err) {
+			return fmt.Errorf("Inspecting image: %s failed: %s", image, err)
+		}
+		if policy == "never" {
+			return imageNotPresentError(image)
+		}
+	}
+	d.Logger.Log("info", fmt.Sprintf("Pulling image: %s (pull policy: %s)", image, policy))
+	err := d.Client.PullImage(image, d.Stderr)
+	if err != nil {
+		return fmt.Errorf("Pulling image: %s failed: %s", image, err)
+	}
+	return nil
+}
+
+// Propagates the terminal size to the container TTY, now and on every SIGWINCH.
+// Returns a function which stops the propagation.
+func (d DockerAPIDriver) monitorTTYSize(containerID string) func() {
+	resize := func() {
+		height, width, err := getTerminalSize(os.Stdout.Fd())
+		if err != nil || height == 0 || width == 0 {
+			return
+		}
+		err = d.Client.ResizeContainerTTY(containerID, height, width)
+		if err != nil {
+			d.Logger.Log("debug", fmt.Sprintf("Error when resizing container TTY: %s", err))
+		}
+	}
+	resize()
+	sigwinch := make(chan os.Signal, 1)
+	signal.Notify(sigwinch, syscall.SIGWINCH)
+	done := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-sigwinch:
+				resize()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigwinch)
+		close(done)
+	}
+}
+
+// Attaches to the container, starts it and streams its input and output until it exits.
+// Returns: the container exit code.
+func (d DockerAPIDriver) runContainer(containerID string, tty bool) (int, error) {
+	conn, reader, err := d.Client.AttachContainer(containerID, tty)
+	if err != nil {
+		return 1, err
+	}
+	defer conn.Close()
+
+	if tty {
+		terminalState, err := setRawTerminal(os.Stdin.Fd())
+		if err != nil {
+			d.Logger.Log("debug", fmt.Sprintf("Not setting the terminal in raw mode: %s", err))
+		} else {
+			defer restoreTerminal(os.Stdin.Fd(), terminalState)
+		}
+		go func() {
+			io.Copy(conn, d.Stdin)
+			if unixConn, ok := conn.(*net.UnixConn); ok {
+				unixConn.CloseWrite()
+			} else if tcpConn, ok := conn.(*net.TCPConn); ok {
+				tcpConn.CloseWrite()
+			}
+		}()
+	}
+
+	err = d.Client.StartContainer(containerID)
+	if err != nil {
+		return 1, err
+	}
+	if tty {
+		stopMonitoring := d.monitorTTYSize(containerID)
+		defer stopMonitoring()
+	}
+
+	// the attached stream ends when the container exits
+	if tty {
+		_, err = io.Copy(d.Stdout, reader)
+	} else {
+		err = demuxDockerStream(reader, d.Stdout, d.Stderr)
+	}
+	if err != nil {
+		d.Logger.Log("debug", fmt.Sprintf("Error when reading the container output: %s", err))
+	}
+	// The container is not auto removed, so there is no race between the container exit and waiting for it.
+	return d.Client.WaitContainer(containerID, "not-running")
+}
+
+func (d DockerAPIDriver) HandleRun(mergedConfig Config, runID string, envService EnvServiceInterface) int {
+	warnGeneral(d.FileService, mergedConfig, envService, d.Logger)
+	envFile, envFileMultiLine, envFileBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
+	saveEnvToFile(d.FileService, envFile, envFileMultiLine, envFileBashFunctions,
+		mergedConfig.BlacklistVariables, envService.GetVariables())
+	envVariables := singleLineVariables(filterBlacklistedVariables(mergedConfig.BlacklistVariables, envService.GetVariables()))
+
+	containerConfig, err := d.ConstructContainerConfig(mergedConfig, envVariables, envFileMultiLine, envFileBashFunctions)
+	if err != nil {
+		d.Logger.Log("error", fmt.Sprintf("Invalid configuration: %s", err))
+		return 1
+	}
+	// do not print the environment variables, they are the same as in the env file
+	printedConfig := containerConfig
+	printedConfig.Env = []string{fmt.Sprintf("<%v variables from: %s>", len(envVariables), envFile)}
+	printedConfigJSON, _ := json.MarshalIndent(printedConfig, "", "  ")
+	d.Logger.Log("info", green(fmt.Sprintf("docker container will be created with:\n %s", printedConfigJSON)))
+
+	if mergedConfig.DockerBuildContext != "" {
+		err = d.buildImageIfMissing(mergedConfig)
+	} else {
+		err = d.pullImageWithPolicy(containerConfig.Image, mergedConfig.PullPolicy)
+	}
+	if err != nil {
+		d.Logger.Log("error", err.Error())
+		return imageFailureExitStatus
+	}
+	if mergedConfig.RemoveContainers != "true" {
+		saveRunIDToDojoRC(d.FileService, runID)
+	}
+	containerID, err := d.createContainer(runID, containerConfig)
+	if err != nil {
+		d.Logger.Log("error", fmt.Sprintf("Error when creating container: %s", err))
+		return 1
+	}
+	d.Logger.Log("debug", fmt.Sprintf("Created container: %s", containerID))
+
+	exitStatus, err := d.runContainer(containerID, containerConfig.Tty)
+	if err != nil {
+		d.Logger.Log("error", fmt.Sprintf("Error when running container: %s", err))
+		exitStatus = 1
+	}
+	d.Logger.Log("debug", fmt.Sprintf("Exit status from run command: %v", exitStatus))
+	d.ReportContainers.Add(RunReportContainer{Name: runID, Default: true, Status: "exited", ExitCode: fmt.Sprint(exitStatus)})
+
+	if mergedConfig.RemoveContainers == "true" {
+		err = d.Client.RemoveContainer(containerID)
+		if err != nil && !isDockerAPINotFound(err) {
+			d.Logger.Log("error", fmt.Sprintf("Error when removing container: %s", err))
+		}
+	}
+	return exitStatus
+
+	// do not clean now, container may be being stopped in other goroutines
+}
+
+func (d DockerAPIDriver) HandlePull(mergedConfig Config) int {
+	if mergedConfig.DockerBuildContext != "" {
+		// there is nothing to pull, the image is built
+		err := d.buildImageIfMissing(mergedConfig)
+		if err != nil {
+			d.Logger.Log("error", err.Error())
+			return 1
+		}
+		return 0
+	}
+	d.Logger.Log("info", green(fmt.Sprintf("docker image will be pulled:\n %v", mergedConfig.DockerImage)))
+	err := d.Client.PullImage(mergedConfig.DockerImage, d.Stderr)
+	if err != nil {
+		d.Logger.Log("error", fmt.Sprintf("Error when pulling image: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// Stop the container if it is not removed.
+func (d DockerAPIDriver) HandleSignal(mergedConfig Config, runID string) int {
+	d.Logger.Log("info", "Stopping on signal")
+	containerInfo, err := d.Client.InspectContainer(runID)
+	if err != nil {
+		d.Logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
+		panic(err)
+	}
+	if !containerInfo.Exists {
+		d.Logger.Log("info", "Container already removed or not created at all, will not react on this signal")
+		return 0
+	}
+	d.Logger.Log("info", fmt.Sprintf("Stopping container: %s", runID))
+	err = d.Client.StopContainer(runID)
+	if err != nil {
+		d.Logger.Log("debug", fmt.Sprintf("Error when stopping container: %s", err))
+		return 1
+	}
+	d.Logger.Log("info", "Stopping on signal finished")
+	return 0
+}
+
+// Kill the container if it is not removed.
+func (d DockerAPIDriver) HandleMultipleSignal(mergedConfig Config, runID string) int {
+	d.Logger.Log("info", "Stopping on multiple signals")
+	containerInfo, err := d.Client.InspectContainer(runID)
+	if err != nil {
+		d.Logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
+		panic(err)
+	}
+	if !containerInfo.Exists {
+		d.Logger.Log("info", "Container already removed or not created at all, will not react on this signal")
+		return 0
+	}
+	d.Logger.Log("info", fmt.Sprintf("Killing container: %s", runID))
+	err = d.Client.KillContainer(runID)
+	if err != nil {
+		d.Logger.Log("debug", fmt.Sprintf("Error when killing container: %s", err))
+		return 1
+	}
+	d.Logger.Log("debug", "docker kill was successful")
+	d.Logger.Log("info", "Stopping on multiple signals finished")
+	return 0
+}
+
+func (d DockerAPIDriver) FillRunReport(mergedConfig Config, report *RunReport) {
+	report.Image = mergedConfig.DockerImage
+	digests, err := d.Client.InspectImageDigests(mergedConfig.DockerImage)
+	if err != nil {
+		d.Logger.Log("debug", fmt.Sprintf("Cannot get the digest of image: %s, error: %s", mergedConfig.DockerImage, err))
+	} else if len(digests) > 0 {
+		report.ImageDigest = digests[0]
+	}
+	report.Containers = d.ReportContainers.Get()
+}
+
+func (d DockerAPIDriver) CleanAfterRun(mergedConfig Config, runID string) int {
+	if mergedConfig.RemoveContainers == "true" {
+		d.Logger.Log("debug", "Cleaning, because RemoveContainers is set to true")
+		envFile, envFileMultiLine, envFilePathBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
+		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFile)
+		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFileMultiLine)
+		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFilePathBashFunctions)
+
+		// no need to remove the container, HandleRun already removed it
+		return 0
+	} else {
+		d.Logger.Log("debug", "Not cleaning, because RemoveContainers is not set to true")
+		return 0
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDockerAPIDriver_ConstructContainerConfig(t *testing.T) {
+	setTestEnv()
+	logger := NewLogger("debug")
+	client, _ := NewDockerAPIClient("")
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger)
+	config := getTestConfig()
+	config.RunCommand = "bash -c \"echo hello; exit 3\""
+	config.DockerOptions = "-v /tmp/cache:/cache --env=A=1 -p 8080:80 --init -u root"
+	containerConfig, err := d.ConstructContainerConfig(config, []string{"ABC=123"},
+		"/tmp/some-env-file-multiline", "/tmp/some-env-file-bash-functions")
+	assert.Nil(t, err)
+	assert.Equal(t, "img:1.2.3", containerConfig.Image)
+	assert.Equal(t, []string{"bash", "-c", "echo hello; exit 3"}, containerConfig.Cmd)
+	assert.Equal(t, []string{"ABC=123", "A=1"}, containerConfig.Env)
+	assert.Equal(t, []string{"/tmp/bla:/dojo/work", "/tmp/myidentity:/dojo/identity:ro",
+		"/tmp/some-env-file-multiline:/etc/dojo.d/variables/00-multiline-vars.sh",
+		"/tmp/some-env-file-bash-functions:/etc/dojo.d/variables/01-bash-functions.sh",
+		"/tmp/cache:/cache"}, containerConfig.HostConfig.Binds)
+	assert.Equal(t, "root", containerConfig.User)
+	assert.True(t, *containerConfig.HostConfig.Init)
+	assert.Equal(t, []DockerAPIPortBinding{DockerAPIPortBinding{HostPort: "8080"}}, containerConfig.HostConfig.PortBindings["80/tcp"])
+	assert.False(t, containerConfig.Tty)
+	assert.False(t, containerConfig.AttachStdin)
+}
+
+func TestDockerAPIDriver_ConstructContainerConfig_Interactive(t *testing.T) {
+	type mytestStruct struct {
+		shellInteractive bool
+		interactive      string
+		expTty           bool
+	}
+	mytests := []mytestStruct{
+		mytestStruct{shellInteractive: true, interactive: "", expTty: true},
+		mytestStruct{shellInteractive: true, interactive: "false", expTty: false},
+		mytestStruct{shellInteractive: false, interactive: "", expTty: false},
+		mytestStruct{shellInteractive: false, interactive: "true", expTty: true},
+	}
+	setTestEnv()
+	logger := NewLogger("debug")
+	client, _ := NewDockerAPIClient("")
+	for _, v := range mytests {
+		var shell ShellServiceInterface
+		if v.shellInteractive {
+			shell = NewMockedShellServiceInteractive(logger)
+		} else {
+			shell = NewMockedShellServiceNotInteractive(logger)
+		}
+		d := NewDockerAPIDriver(client, shell, NewMockedFileService(logger), logger)
+		config := getTestConfig()
+		config.Interactive = v.interactive
+		containerConfig, err := d.ConstructContainerConfig(config, []string{}, "/tmp/ml", "/tmp/bf")
+		assert.Nil(t, err)
+		msg := fmt.Sprintf("shellInteractive: %v, interactive: %v", v.shellInteractive, v.interactive)
+		assert.Equal(t, v.expTty, containerConfig.Tty, msg)
+		assert.Equal(t, v.expTty, containerConfig.OpenStdin, msg)
+		assert.Equal(t, v.expTty, containerConfig.AttachStdin, msg)
+	}
+}
+
+func Test_parseDockerOptions(t *testing.T) {
+	type mytestStruct struct {
+		options     string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{options: "--privileged --network host --security-opt seccomp=unconfined --cap-add=SYS_ADMIN --add-host a:1.2.3.4"},
+		mytestStruct{options: "-w /opt --entrypoint /bin/sh --volume=/a:/b -p 127.0.0.1:53:53/udp"},
+		mytestStruct{options: "--memory 1g", expErrorMsg: "docker option: --memory is unsupported for driver: docker-api"},
+		mytestStruct{options: "-v", expErrorMsg: "docker option: -v requires a value"},
+		mytestStruct{options: "-p 1:2:3:4", expErrorMsg: "invalid port mapping: 1:2:3:4"},
+		mytestStruct{options: "-e 'A=1", expErrorMsg: "unterminated single quote in: -e 'A=1"},
+	}
+	for _, v := range mytests {
+		containerConfig := DockerAPIContainerConfig{}
+		err := parseDockerOptions(v.options, &containerConfig)
+		if v.expErrorMsg != "" {
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.options)
+		} else {
+			assert.Nil(t, err, v.options)
+		}
+	}
+}
+
+func Test_parseDockerOptions_EnvFromHost(t *testing.T) {
+	os.Setenv("DOJO_TEST_API_VAR", "value1")
+	defer os.Unsetenv("DOJO_TEST_API_VAR")
+	containerConfig := DockerAPIContainerConfig{}
+	err := parseDockerOptions("-e DOJO_TEST_API_VAR -e DOJO_TEST_API_NOT_SET", &containerConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DOJO_TEST_API_VAR=value1"}, containerConfig.Env)
+}
+
+// Records the requests and plays the Docker Engine part of "docker run"
+type fakeDockerEngineRun struct {
+	mutex        sync.Mutex
+	requests     []string
+	imageExists  bool
+	createdEnv   []string
+	createdCmd   []string
+	exitCode     int
+	stdoutOutput string
+	stderrOutput string
+}
+
+func (f *fakeDockerEngineRun) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mutex.Lock()
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+	f.mutex.Unlock()
+	switch r.URL.Path {
+	case "/containers/create":
+		if !f.imageExists {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No such image: img:1.2.3"}`)
+			return
+		}
+		var body DockerAPIContainerConfig
+		json.NewDecoder(r.Body).Decode(&body)
+		f.createdEnv = body.Env
+		f.createdCmd = body.Cmd
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"Id":"1234","Warnings":[]}`)
+	case "/images/img:1.2.3/json":
+		if !f.imageExists {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"No such image: img:1.2.3"}`)
+			return
+		}
+		fmt.Fprint(w, `{"RepoDigests":["img@sha256:abcd"]}`)
+	case "/build":
+		f.imageExists = true
+		fmt.Fprint(w, `{"stream":"Successfully tagged img:1.2.3\n"}`)
+	case "/images/create":
+		f.imageExists = true
+		fmt.Fprint(w, `{"status":"Downloaded newer image for img:1.2.3"}`)
+	case "/containers/1234/attach":
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		fmt.Fprint(conn, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+		conn.Write(dockerStreamFrame(1, f.stdoutOutput))
+		conn.Write(dockerStreamFrame(2, f.stderrOutput))
+		conn.Close()
+	case "/containers/1234/start":
+		w.WriteHeader(http.StatusNoContent)
+	case "/containers/1234/wait":
+		fmt.Fprintf(w, `{"StatusCode":%v}`, f.exitCode)
+	case "/containers/1234":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"not found"}`)
+	}
+}
+
+func TestDockerAPIDriver_HandleRun_Unit(t *testing.T) {
+	engine := &fakeDockerEngineRun{imageExists: true, exitCode: 3, stdoutOutput: "hello\n", stderrOutput: "oops\n"}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), fs, logger)
+	var stdout, stderr bytes.Buffer
+	d.Stdout = &stdout
+	d.Stderr = &stderr
+	config := getTestConfig()
+	config.RunCommand = "echo hello"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 3, es)
+	assert.Equal(t, "hello\n", stdout.String())
+	assert.Equal(t, "oops\n", stderr.String())
+	assert.Equal(t, []string{"ABC=123"}, engine.createdEnv)
+	assert.Equal(t, []string{"echo", "hello"}, engine.createdCmd)
+	assert.Equal(t, []string{"GET /images/img:1.2.3/json", "POST /containers/create", "POST /containers/1234/attach",
+		"POST /containers/1234/start", "POST /containers/1234/wait", "DELETE /containers/1234"}, engine.requests)
+	assert.Equal(t, 3, len(fs.FilesWrittenTo))
+	assert.Equal(t, "ABC=123\n", fs.FilesWrittenTo["/tmp/dojo-environment-testrunid"])
+
+	es = d.CleanAfterRun(config, "testrunid")
+	assert.Equal(t, 0, es)
+	assert.Equal(t, 6, len(fs.FilesRemovals))
+}
+
+func TestDockerAPIDriver_HandleRun_PullsMissingImage(t *testing.T) {
+	engine := &fakeDockerEngineRun{imageExists: false}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), fs, logger)
+	d.Stdout = &bytes.Buffer{}
+	d.Stderr = &bytes.Buffer{}
+	config := getTestConfig()
+	config.RemoveContainers = "false"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"GET /images/img:1.2.3/json", "POST /images/create", "POST /containers/create",
+		"POST /containers/1234/attach", "POST /containers/1234/start", "POST /containers/1234/wait"}, engine.requests)
+	assert.Equal(t, "testrunid", fs.FilesWrittenTo["/tmp//dojorc.txt"])
+	assert.Equal(t, "DOJO_RUN_ID=testrunid", fs.FilesWrittenTo["/tmp//dojorc"])
+}
+
+func TestDockerAPIDriver_HandleRun_PullPolicyNever(t *testing.T) {
+	engine := &fakeDockerEngineRun{imageExists: false}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), fs, logger)
+	d.Stdout = &bytes.Buffer{}
+	d.Stderr = &bytes.Buffer{}
+	config := getTestConfig()
+	config.PullPolicy = "never"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, imageFailureExitStatus, es)
+	assert.Equal(t, []string{"GET /images/img:1.2.3/json"}, engine.requests)
+}
+
+func TestDockerAPIDriver_HandleRun_BuildImage(t *testing.T) {
+	contextDir := createTestBuildContext(t)
+	defer os.RemoveAll(filepath.Dir(contextDir))
+	engine := &fakeDockerEngineRun{imageExists: false}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), fs, logger)
+	d.Stdout = &bytes.Buffer{}
+	d.Stderr = &bytes.Buffer{}
+	config := getTestConfig()
+	config.DockerBuildContext = contextDir
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"GET /images/img:1.2.3/json", "POST /build", "POST /containers/create", "POST /containers/1234/attach",
+		"POST /containers/1234/start", "POST /containers/1234/wait", "DELETE /containers/1234"}, engine.requests)
+}
+
+func TestDockerAPIDriver_HandleRun_PullPolicyAlways(t *testing.T) {
+	engine := &fakeDockerEngineRun{imageExists: true}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), fs, logger)
+	d.Stdout = &bytes.Buffer{}
+	d.Stderr = &bytes.Buffer{}
+	config := getTestConfig()
+	config.PullPolicy = "always"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"POST /images/create", "POST /containers/create", "POST /containers/1234/attach",
+		"POST /containers/1234/start", "POST /containers/1234/wait", "DELETE /containers/1234"}, engine.requests)
+}
+
+func TestDockerAPIDriver_HandleRun_UnsupportedDockerOption(t *testing.T) {
+	engine := &fakeDockerEngineRun{imageExists: true}
+	client, stop := startFakeDockerEngine(t, engine)
+	defer stop()
+	logger := NewLogger("debug")
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger)
+	config := getTestConfig()
+	config.DockerOptions = "--memory=1g"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 1, es)
+	assert.Equal(t, 0, len(engine.requests))
+}
+
+func TestDockerAPIDriver_HandleSignal(t *testing.T) {
+	var requests []string
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		if r.URL.Path == "/containers/testrunid/json" {
+			fmt.Fprint(w, `{"Id":"1234","Name":"/testrunid","State":{"Status":"running","Running":true,"ExitCode":0}}`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer stop()
+	logger := NewLogger("debug")
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger)
+	es := d.HandleSignal(getTestConfig(), "testrunid")
+	assert.Equal(t, 0, es)
+	es = d.HandleMultipleSignal(getTestConfig(), "testrunid")
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"GET /containers/testrunid/json", "POST /containers/testrunid/stop",
+		"GET /containers/testrunid/json", "POST /containers/testrunid/kill"}, requests)
+}
+
+func TestDockerAPIDriver_HandleMultipleSignal_ContainerRemoved(t *testing.T) {
+	var requests []string
+	client, stop := startFakeDockerEngine(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s", r.Method, r.URL.Path))
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"message":"No such container: testrunid"}`)
+	}))
+	defer stop()
+	logger := NewLogger("debug")
+	d := NewDockerAPIDriver(client, NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger)
+	es := d.HandleMultipleSignal(getTestConfig(), "testrunid")
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"GET /containers/testrunid/json"}, requests)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// DCVersion is a semantic version of docker-compose, e.g. 2.24.5
+type DCVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// Accepts versions with or without the "v" prefix and with a suffix, e.g.: 1.29.2, v2.24.5, 2.31.0-desktop.2
+var dcVersionRegexp = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+func parseDCVersion(version string) (DCVersion, error) {
+	matches := dcVersionRegexp.FindStringSubmatch(version)
+	if matches == nil {
+		return DCVersion{}, fmt.Errorf("Unsupported docker-compose version: %q, expected a semantic version, e.g. 2.24.5", version)
+	}
+	major, _ := strconv.Atoi(matches[1])
+	minor, _ := strconv.Atoi(matches[2])
+	patch := 0
+	if matches[3] != "" {
+		patch, _ = strconv.Atoi(matches[3])
+	}
+	return DCVersion{Major: major, Minor: minor, Patch: patch}, nil
+}
+
+// Returns true if v is the same or a later version than: major.minor.patch
+func (v DCVersion) AtLeast(major int, minor int, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func (v DCVersion) String() string {
+	return fmt.Sprintf("%v.%v.%v", v.Major, v.Minor, v.Patch)
+}
+
+// DCCapabilities describe how a docker-compose release behaves, where it matters for dojo.
+// The driver branches on the capabilities, not on the version.
+type DCCapabilities struct {
+	Version DCVersion
+	// PSFormatJSON is true if "docker-compose ps --format json" is supported (docker-compose >=2).
+	// Otherwise, the ps output is a table.
+	PSFormatJSON bool
+	// PSJSONArray is true if "docker-compose ps --format json" prints a JSON array (docker-compose <2.21),
+	// false if it prints one JSON object per line.
+	PSJSONArray bool
+	// PSAll is true if "docker-compose ps" needs the option: --all to show the stopped containers and the
+	// containers created with "docker-compose run" (docker-compose >=2).
+	PSAll bool
+	// Wait is true if "docker-compose up --wait --wait-timeout" is supported (docker-compose >=2.17.0).
+	// Then, docker-compose waits for the containers to be healthy, instead of dojo.
+	Wait bool
+}
+
+// Returns the capabilities of the docker-compose version. If the version cannot be parsed, returns the
+// capabilities of docker-compose 1.x (which were assumed by dojo before docker-compose 2 was supported) and an error.
+func NewDCCapabilities(version string) (DCCapabilities, error) {
+	parsedVersion, err := parseDCVersion(version)
+	capabilities := DCCapabilities{
+		Version:      parsedVersion,
+		PSFormatJSON: parsedVersion.AtLeast(2, 0, 0),
+		PSJSONArray:  parsedVersion.AtLeast(2, 0, 0) && !parsedVersion.AtLeast(2, 21, 0),
+		PSAll:        parsedVersion.AtLeast(2, 0, 0),
+		Wait:         parsedVersion.AtLeast(2, 17, 0),
+	}
+	return capabilities, err
+}
+
+func (c DCCapabilities) String() string {
+	return fmt.Sprintf("{ Version: %s, PSFormatJSON: %v, PSJSONArray: %v, PSAll: %v, Wait: %v }",
+		c.Version, c.PSFormatJSON, c.PSJSONArray, c.PSAll, c.Wait)
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseDCVersion(t *testing.T) {
+	type mytestStruct struct {
+		version     string
+		expVersion  DCVersion
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{version: "1.29.2", expVersion: DCVersion{1, 29, 2}},
+		mytestStruct{version: "v2.24.5", expVersion: DCVersion{2, 24, 5}},
+		mytestStruct{version: "2.31.0-desktop.2", expVersion: DCVersion{2, 31, 0}},
+		mytestStruct{version: "3.0", expVersion: DCVersion{3, 0, 0}},
+		mytestStruct{version: "", expErrorMsg: "Unsupported docker-compose version: \"\", expected a semantic version, e.g. 2.24.5"},
+		mytestStruct{version: "latest", expErrorMsg: "Unsupported docker-compose version: \"latest\", expected a semantic version, e.g. 2.24.5"},
+	}
+	for _, v := range mytests {
+		version, err := parseDCVersion(v.version)
+		if v.expErrorMsg != "" {
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.version)
+			continue
+		}
+		assert.Nil(t, err, v.version)
+		assert.Equal(t, v.expVersion, version, v.version)
+	}
+}
+
+func Test_DCVersion_AtLeast(t *testing.T) {
+	version := DCVersion{2, 21, 0}
+	assert.True(t, version.AtLeast(2, 21, 0))
+	assert.True(t, version.AtLeast(2, 1, 1))
+	assert.True(t, version.AtLeast(1, 99, 99))
+	assert.False(t, version.AtLeast(2, 21, 1))
+	assert.False(t, version.AtLeast(3, 0, 0))
+}
+
+func Test_NewDCCapabilities(t *testing.T) {
+	type mytestStruct struct {
+		version         string
+		expCapabilities DCCapabilities
+		expError        bool
+	}
+	mytests := []mytestStruct{
+		mytestStruct{version: "1.29.2", expCapabilities: DCCapabilities{Version: DCVersion{1, 29, 2},
+			PSFormatJSON: false, PSJSONArray: false, PSAll: false, Wait: false}},
+		mytestStruct{version: "2.0.1", expCapabilities: DCCapabilities{Version: DCVersion{2, 0, 1},
+			PSFormatJSON: true, PSJSONArray: true, PSAll: true, Wait: false}},
+		mytestStruct{version: "2.16.0", expCapabilities: DCCapabilities{Version: DCVersion{2, 16, 0},
+			PSFormatJSON: true, PSJSONArray: true, PSAll: true, Wait: false}},
+		mytestStruct{version: "2.17.2", expCapabilities: DCCapabilities{Version: DCVersion{2, 17, 2},
+			PSFormatJSON: true, PSJSONArray: true, PSAll: true, Wait: true}},
+		mytestStruct{version: "2.21.0", expCapabilities: DCCapabilities{Version: DCVersion{2, 21, 0},
+			PSFormatJSON: true, PSJSONArray: false, PSAll: true, Wait: true}},
+		mytestStruct{version: "v2.24.5", expCapabilities: DCCapabilities{Version: DCVersion{2, 24, 5},
+			PSFormatJSON: true, PSJSONArray: false, PSAll: true, Wait: true}},
+		// a prefix check for "2" would treat this as docker-compose 1.x
+		mytestStruct{version: "3.1.0", expCapabilities: DCCapabilities{Version: DCVersion{3, 1, 0},
+			PSFormatJSON: true, PSJSONArray: false, PSAll: true, Wait: true}},
+		mytestStruct{version: "", expError: true, expCapabilities: DCCapabilities{
+			PSFormatJSON: false, PSJSONArray: false, PSAll: false, Wait: false}},
+	}
+	for _, v := range mytests {
+		capabilities, err := NewDCCapabilities(v.version)
+		assert.Equal(t, v.expError, err != nil, v.version)
+		assert.Equal(t, v.expCapabilities, capabilities, v.version)
+	}
+}
+
+func Test_ConstructDockerComposeCommandPs_Capabilities(t *testing.T) {
+	type mytestStruct struct {
+		version   string
+		expOutput string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{version: "1.29.2", expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 ps"},
+		mytestStruct{version: "2.17.2", expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 ps --format json --all"},
+		mytestStruct{version: "3.1.0", expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 ps --format json --all"},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", v.version)
+		config := getTestConfig()
+		config.DockerComposeFile = "/tmp/dummy.yml"
+		assert.Equal(t, v.expOutput, dc.ConstructDockerComposeCommandPs(config, "1234"), v.version)
+	}
+}
+
+// The outputs of: docker-compose ps (with the options depending on the capabilities), when
+// the containers abc and default (created with docker-compose run) exist.
+func Test_getDCContainers_Releases(t *testing.T) {
+	type mytestStruct struct {
+		version  string
+		psOutput string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{version: "1.29.2", psOutput: `       Name                     Command               State   Ports
+-------------------------------------------------------------------------
+testdojorunid_abc_1           /bin/sh -c while true; do  ...   Up
+testdojorunid_default_run_1   sh -c sleep 10                   Up
+`},
+		mytestStruct{version: "2.17.2", psOutput: `[{"ID":"2d5c5b0343d0","Name":"testdojorunid-abc-1","Command":"/bin/sh -c 'while true; do date; sleep 1; done'","Project":"testdojorunid","Service":"abc","State":"running","Health":"","ExitCode":0,"Publishers":[{"URL":"0.0.0.0","TargetPort":8080,"PublishedPort":8080,"Protocol":"tcp"}]},{"ID":"af4817fede41","Name":"testdojorunid-default-run-742bcbb0e4bc","Command":"sh -c 'sleep 10'","Project":"testdojorunid","Service":"default","State":"running","Health":"","ExitCode":0,"Publishers":null}]
+`},
+		mytestStruct{version: "2.24.5", psOutput: `{"Command":"\"/bin/sh -c 'while t…\"","CreatedAt":"2024-02-03 21:03:46 +0000 UTC","ExitCode":0,"Health":"","ID":"2d5c5b0343d0","Image":"alpine:3.19","Labels":"com.docker.compose.project=testdojorunid,com.docker.compose.service=abc","LocalVolumes":"0","Mounts":"","Name":"testdojorunid-abc-1","Names":"testdojorunid-abc-1","Networks":"testdojorunid_default","Ports":"","Project":"testdojorunid","Publishers":null,"RunningFor":"3 seconds ago","Service":"abc","Size":"0B","State":"running","Status":"Up 2 seconds"}
+{"Command":"\"sh -c 'sleep 10'\"","CreatedAt":"2024-02-03 21:03:47 +0000 UTC","ExitCode":0,"Health":"","ID":"af4817fede41","Image":"alpine:3.15","Labels":"com.docker.compose.project=testdojorunid,com.docker.compose.service=default","LocalVolumes":"0","Mounts":"","Name":"testdojorunid-default-run-742bcbb0e4bc","Names":"testdojorunid-default-run-742bcbb0e4bc","Networks":"testdojorunid_default","Ports":"","Project":"testdojorunid","Publishers":null,"RunningFor":"2 seconds ago","Service":"default","Size":"0B","State":"running","Status":"Up 1 second"}
+`},
+		mytestStruct{version: "2.31.0", psOutput: `{"Command":"\"/bin/sh -c 'while t…\"","CreatedAt":"2024-12-20 15:58:00 +1300 NZDT","ExitCode":0,"Health":"","ID":"2d5c5b0343d0","Image":"alpine:3.19","Labels":"com.docker.compose.project=testdojorunid,com.docker.compose.service=abc","LocalVolumes":"0","Mounts":"","Name":"testdojorunid-abc-1","Names":"testdojorunid-abc-1","Networks":"testdojorunid_default","Ports":"0.0.0.0:8080->8080/tcp","Project":"testdojorunid","Publishers":[{"URL":"0.0.0.0","TargetPort":8080,"PublishedPort":8080,"Protocol":"tcp"}],"RunningFor":"36 seconds ago","Service":"abc","Size":"0B","State":"running","Status":"Up 35 seconds"}
+{"Command":"\"sh -c 'sleep 10'\"","CreatedAt":"2024-12-20 15:58:01 +1300 NZDT","ExitCode":0,"Health":"","ID":"af4817fede41","Image":"alpine:3.15","Labels":"com.docker.compose.project=testdojorunid,com.docker.compose.service=default","LocalVolumes":"0","Mounts":"","Name":"testdojorunid-default-run-742bcbb0e4bc","Names":"testdojorunid-default-run-742bcbb0e4bc","Networks":"testdojorunid_default","Ports":"","Project":"testdojorunid","Publishers":[],"RunningFor":"35 seconds ago","Service":"default","Size":"0B","State":"running","Status":"Up 34 seconds"}
+`},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		capabilities, _ := NewDCCapabilities(v.version)
+		abcName := "testdojorunid-abc-1"
+		defaultName := "testdojorunid-default-run-742bcbb0e4bc"
+		if !capabilities.PSFormatJSON {
+			abcName = "testdojorunid_abc_1"
+			defaultName = "testdojorunid_default_run_1"
+		}
+		commandsReactions := make(map[string]interface{}, 0)
+		dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", v.version)
+		config := getTestConfig()
+		commandsReactions[dc.ConstructDockerComposeCommandPs(config, "testdojorunid")] = []string{v.psOutput, "", "0"}
+		commandsReactions[dockerInspectCmd(abcName)] = []string{"2d5c5b0343d0 /" + abcName + " running 0 testdojorunid abc", "", "0"}
+		commandsReactions[dockerInspectCmd(defaultName)] =
+			[]string{"af4817fede41 /" + defaultName + " running 0 testdojorunid default", "", "0"}
+		dc.ShellService = NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+
+		containers := dc.getDCContainers(config, "testdojorunid")
+		assert.Equal(t, 2, len(containers), v.version)
+		assert.Equal(t, []string{abcName, defaultName}, getContainersNames(containers), v.version)
+		assert.Equal(t, "abc", containers[0].Service, v.version)
+		assert.Equal(t, "default", containers[1].Service, v.version)
+		assert.Equal(t, "running", containers[1].Status, v.version)
+		assert.Equal(t, "af4817fede41", containers[1].ID, v.version)
+	}
+}
+
+func Test_parseDCPSOutPut_DCVersion2_JSONArray(t *testing.T) {
+	output, err := ParseDCPSOutPut_DCVersion2("[]\n", true)
+	assert.Equal(t, "", err)
+	assert.Equal(t, 0, len(output))
+
+	output, err = ParseDCPSOutPut_DCVersion2(`{"ID":"2d5c5b0343d0","State":"running"}`, true)
+	assert.Contains(t, err, "Error when decoding the JSON array response from docker-compose ps command")
+	assert.Equal(t, 0, len(output))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -15,11 +16,23 @@ type DockerComposeDriver struct {
 	FileService  FileServiceInterface
 	Logger       *Logger
 	// This channel is closed when the stop action is started
-	Stopping             chan bool
+	Stopping chan bool
+	// The command used to invoke docker-compose: "docker-compose" (the standalone binary),
+	// "docker compose" (the docker CLI plugin) or set by the user
+	DockerComposeCommand string
 	DockerComposeVersion string
+	// Capabilities are based on DockerComposeVersion
+	Capabilities DCCapabilities
+	// The delay before the first restart of a container, when its exit behavior is restart.
+	// The delay is doubled with each next restart of that container.
+	RestartBackoff time.Duration
+	Restarts       *ContainersRestarts
+	// Prints the containers logs live, when PrintLogs is stream
+	LogsPrinter      *ContainersLogsPrinter
+	ReportContainers *RunReportContainers
 }
 
-func NewDockerComposeDriver(shellService ShellServiceInterface, fs FileServiceInterface, logger *Logger, version string) DockerComposeDriver {
+func NewDockerComposeDriver(shellService ShellServiceInterface, fs FileServiceInterface, logger *Logger, command string, version string) DockerComposeDriver {
 	if shellService == nil {
 		panic(errors.New("shellService was nil"))
 	}
@@ -29,118 +42,112 @@ func NewDockerComposeDriver(shellService ShellServiceInterface, fs FileServiceIn
 	if logger == nil {
 		panic(errors.New("logger was nil"))
 	}
+	if command == "" {
+		panic(errors.New("command was empty"))
+	}
+	capabilities, err := NewDCCapabilities(version)
+	if err != nil {
+		logger.Log("warn", fmt.Sprintf("%s. Assuming the capabilities of docker-compose 1.x", err))
+	}
 	stopping := make(chan bool)
 	return DockerComposeDriver{
 		ShellService:         shellService,
 		FileService:          fs,
 		Logger:               logger,
 		Stopping:             stopping,
+		DockerComposeCommand: command,
 		DockerComposeVersion: version,
+		Capabilities:         capabilities,
+		RestartBackoff:       time.Second,
+		Restarts:             NewContainersRestarts(),
+		LogsPrinter:          NewContainersLogsPrinter(os.Stderr),
+		ReportContainers:     NewRunReportContainers(),
 	}
 }
 
-func GetDockerComposeVersion(shellService ShellServiceInterface) string {
-	cmd := "docker-compose version --short"
+func GetDockerComposeVersion(shellService ShellServiceInterface, command string) (string, error) {
+	cmd := fmt.Sprintf("%s version --short", command)
 	stdout, stderr, es, _ := shellService.RunGetOutput(cmd, true)
 	if es != 0 || stderr != "" || stdout == "" {
 		cmdInfo := cmdInfoToString(cmd, stdout, stderr, es)
-		panic(fmt.Errorf("Unexpected error: %s", cmdInfo))
+		return "", fmt.Errorf("Unexpected error: %s", cmdInfo)
 	}
 	stdout = strings.TrimSuffix(stdout, "\n")
-	return stdout
+	return stdout, nil
 }
 
-func isDCVersionLaterThan2(dcVersion string) bool {
-	if strings.HasPrefix(dcVersion, "2") || strings.HasPrefix(dcVersion, "v2") {
-		return true
+// Returns the command to invoke docker-compose with and its version.
+// If the user set the command (DockerComposeCommand), it is used. Otherwise, the standalone docker-compose binary
+// is preferred and the docker compose CLI plugin is used if the binary is not installed.
+func DetectDockerComposeCommand(shellService ShellServiceInterface, userCommand string) (string, string, error) {
+	if userCommand != "" {
+		version, err := GetDockerComposeVersion(shellService, userCommand)
+		if err != nil {
+			return "", "", fmt.Errorf("DockerComposeCommand: %s does not work. %s", userCommand, err)
+		}
+		return userCommand, version, nil
 	}
-	return false
+	for _, command := range []string{"docker-compose", "docker compose"} {
+		version, err := GetDockerComposeVersion(shellService, command)
+		if err == nil {
+			return command, version, nil
+		}
+	}
+	return "", "", fmt.Errorf("Neither docker-compose nor the docker compose plugin is installed. " +
+		"Please install one of them or set DOJO_DOCKER_COMPOSE_COMMAND")
 }
 
-func (dc DockerComposeDriver) parseDCFileVersion(contents string) (float64, error) {
-	firstLine := strings.Split(contents, "\n")[0]
-	if !strings.HasPrefix(firstLine, "version") {
-		return -1, nil
-	}
-	versionQuoted := strings.Split(firstLine, ":")[1]
-	versionQuoted = strings.Trim(versionQuoted, " ")
-	versionQuoted = strings.TrimSuffix(versionQuoted, "\n")
-	versionQuoted = strings.Trim(versionQuoted, "\"")
-	versionQuoted = strings.Trim(versionQuoted, "'")
-	version, err := strconv.ParseFloat(versionQuoted, 64)
+func (dc DockerComposeDriver) verifyDCFile(fileContents string, filePath string, defaultService string) (DCFile, error) {
+	dcFile, err := parseDCFile(fileContents, filePath)
 	if err != nil {
-		return 0, err
+		return DCFile{}, err
 	}
-	return version, nil
-}
-func (dc DockerComposeDriver) verifyDCFile(fileContents string, filePath string) (float64, error) {
-	version, err := dc.parseDCFileVersion(fileContents)
+	err = dcFile.Verify(defaultService)
 	if err != nil {
-		return 0, err
+		return DCFile{}, err
 	}
-	if version != -1 && (version < 2 || version >= 3) {
-		return 0, fmt.Errorf("docker-compose file: %s should contain version number >=2 and <3, current version: %v", filePath, version)
-	}
-	requiredStr := "default:"
-	if !strings.Contains(fileContents, requiredStr) {
-		return 0, fmt.Errorf("docker-compose file: %s does not contain: %s. Please add a default service", filePath, requiredStr)
-	}
-	return version, nil
+	return dcFile, nil
 }
 
 func (dc DockerComposeDriver) getDCGeneratedFilePath(dcfilePath string) string {
 	return dcfilePath + ".dojo"
 }
 
-func (dc DockerComposeDriver) generateDCFileContentsWithEnv(expContainers []string, config Config, envFile string,
-	envFileMultiLine string, envFileBashFunctions string) string {
-	contents := fmt.Sprintf(
-		`    volumes:
-      - %s:%s:ro
-      - %s:%s
-      - %s:/etc/dojo.d/variables/00-multiline-vars.sh
-      - %s:/etc/dojo.d/variables/01-bash-functions.sh
-`, config.IdentityDirOuter, "/dojo/identity", config.WorkDirOuter, config.WorkDirInner, envFileMultiLine, envFileBashFunctions)
+// Adds the environment variables files and the dojo volumes to the services in the dojo docker-compose file.
+func (dc DockerComposeDriver) addEnvToDCOverrideFile(overrideFile *DCOverrideFile, expContainers []string, config Config, envFile string,
+	envFileMultiLine string, envFileBashFunctions string) {
+	defaultService := overrideFile.Service(config.DockerComposeService)
+	defaultService.Volumes = append(defaultService.Volumes,
+		fmt.Sprintf("%s:%s:ro", config.IdentityDirOuter, "/dojo/identity"),
+		fmt.Sprintf("%s:%s", config.WorkDirOuter, config.WorkDirInner),
+		fmt.Sprintf("%s:/etc/dojo.d/variables/00-multiline-vars.sh", envFileMultiLine),
+		fmt.Sprintf("%s:/etc/dojo.d/variables/01-bash-functions.sh", envFileBashFunctions))
 	if os.Getenv("DISPLAY") != "" {
 		// DISPLAY is set, enable running in graphical mode (opinionated)
-		contents += "      - /tmp/.X11-unix:/tmp/.X11-unix\n"
+		defaultService.Volumes = append(defaultService.Volumes, "/tmp/.X11-unix:/tmp/.X11-unix")
 	}
-	contents += fmt.Sprintf(`    env_file:
-      - %s
-`, envFile)
+	defaultService.EnvFile = append(defaultService.EnvFile, envFile)
 
 	if config.PreserveEnvironmentToAllContainers == "true" {
 		// set the env_file for each container
 		for _, name := range expContainers {
-			if name == "default" {
+			if name == config.DockerComposeService {
 				// handled above
 				continue
 			}
-			contents += fmt.Sprintf(`  %s:
-    env_file:
-      - %s
-    volumes:
-      - %s:/etc/dojo.d/variables/00-multiline-vars.sh
-      - %s:/etc/dojo.d/variables/01-bash-functions.sh
-`, name, envFile, envFileMultiLine, envFileBashFunctions)
+			service := overrideFile.Service(name)
+			service.EnvFile = append(service.EnvFile, envFile)
+			service.Volumes = append(service.Volumes,
+				fmt.Sprintf("%s:/etc/dojo.d/variables/00-multiline-vars.sh", envFileMultiLine),
+				fmt.Sprintf("%s:/etc/dojo.d/variables/01-bash-functions.sh", envFileBashFunctions))
 		}
 	}
-	return contents
 }
 
-func (dc DockerComposeDriver) generateInitialDCFile(config Config, version float64) string {
-	if version == -1 {
-		// version was not set
-		return fmt.Sprintf(
-			`services:
-  default:
-    image: "%s"`, config.DockerImage)
-	}
-	return fmt.Sprintf(
-		`version: '%v'
-services:
-  default:
-    image: "%s"`, version, config.DockerImage)
+func (dc DockerComposeDriver) generateInitialDCFile(config Config, version string) *DCOverrideFile {
+	overrideFile := NewDCOverrideFile(version)
+	overrideFile.Service(config.DockerComposeService).Image = config.DockerImage
+	return overrideFile
 }
 
 func (dc DockerComposeDriver) ConstructDockerComposeCommandPart1(config Config, projectName string) string {
@@ -151,17 +158,16 @@ func (dc DockerComposeDriver) ConstructDockerComposeCommandPart1(config Config,
 		panic("config.DockerComposeFile was not set")
 	}
 	dcGenFile := dc.getDCGeneratedFilePath(config.DockerComposeFile)
-	cmd := fmt.Sprintf("docker-compose -f %s -f %s -p %s", config.DockerComposeFile, dcGenFile, projectName)
+	cmd := fmt.Sprintf("%s -f %s -f %s -p %s", dc.DockerComposeCommand, config.DockerComposeFile, dcGenFile, projectName)
 	return cmd
 }
 func (dc DockerComposeDriver) ConstructDockerComposeCommandRun(config Config, projectName string) string {
 	cmd := dc.ConstructDockerComposeCommandPart1(config, projectName)
-	cmd += " run --rm"
-	shellIsInteractive := dc.ShellService.CheckIfInteractive()
-	if !shellIsInteractive && config.RunCommand == "" {
-		// TODO: Support this case. Maybe use docker-compose up instead of docker-compose run? #17186
-		panic("Using driver: docker-compose with empty RunCommand when shell is not interactive is unsupported. It would hang the terminal")
+	cmd += " run"
+	if config.RemoveContainers == "true" {
+		cmd += " --rm"
 	}
+	shellIsInteractive := dc.ShellService.CheckIfInteractive()
 	if config.Interactive == "false" {
 		cmd += " -T"
 	} else if config.Interactive == "true" {
@@ -172,10 +178,17 @@ func (dc DockerComposeDriver) ConstructDockerComposeCommandRun(config Config, pr
 	if config.DockerComposeOptions != "" {
 		cmd += fmt.Sprintf(" %s", config.DockerComposeOptions)
 	}
-	cmd += " default"
+	cmd += fmt.Sprintf(" %s", config.DockerComposeService)
 
 	if config.RunCommand != "" {
 		cmd += fmt.Sprintf(" %s", config.RunCommand)
+	} else if !shellIsInteractive && config.Interactive != "true" {
+		// The command of the default service (e.g. CMD from its image) is run, e.g. on CI.
+		// docker-compose run always attaches stdin, even with -T. If stdin is never closed (which may
+		// happen on CI), a command reading from stdin (e.g. bash) would hang the terminal.
+		// Thus, do not let the container read from the stdin of dojo, unless the user set
+		// Interactive to true, e.g. to pipe the input: echo x | dojo -i=true
+		cmd += " < /dev/null"
 	}
 	return cmd
 }
@@ -187,10 +200,12 @@ func (dc DockerComposeDriver) ConstructDockerComposeCommandStop(config Config, p
 func (dc DockerComposeDriver) ConstructDockerComposeCommandPs(config Config, projectName string) string {
 	cmd := dc.ConstructDockerComposeCommandPart1(config, projectName)
 	cmd += " ps"
-	if isDCVersionLaterThan2(dc.DockerComposeVersion) {
+	if dc.Capabilities.PSFormatJSON {
+		cmd += " --format json"
+	}
+	if dc.Capabilities.PSAll {
 		// We need to all the option `--all` to include the output about the default container.
-		// We can use the json format now. It was unavailable in previous versions.
-		cmd += " --format json --all"
+		cmd += " --all"
 	}
 	return cmd
 }
@@ -254,92 +269,376 @@ func (dc DockerComposeDriver) getExpectedContainers(mergedConfig Config, runID s
 	return lines
 }
 
+// How often the containers are checked, when the docker events cannot be streamed
+const dcPollingInterval = time.Second
+
+// DockerEvent is a docker event printed by: docker events --format '{{json .}}'
+type DockerEvent struct {
+	Type   string
+	Action string
+	Actor  DockerEventActor
+}
+
+type DockerEventActor struct {
+	ID string
+	// e.g. name, exitCode and the container labels
+	Attributes map[string]string
+}
+
+func parseDockerEvent(line string) (*DockerEvent, error) {
+	var event DockerEvent
+	err := json.Unmarshal([]byte(line), &event)
+	if err != nil {
+		return nil, fmt.Errorf("Error when decoding a docker event: %s, error: %s", line, err.Error())
+	}
+	return &event, nil
+}
+
+// ContainerEvents is a stream of the docker events of the containers of a docker-compose project.
+// Lines is nil if the events cannot be streamed. Then, the containers are polled.
+type ContainerEvents struct {
+	Lines <-chan string
+}
+
+// Using --since, the events which happened after the subscription time, but before the docker events command
+// connected to the docker daemon, are not missed.
+func (dc DockerComposeDriver) ConstructDockerEventsCommand(projectName string, since int64) string {
+	return fmt.Sprintf("docker events --filter type=container --filter label=com.docker.compose.project=%s"+
+		" --filter event=start --filter event=die --filter event=health_status --format '{{json .}}' --since %v",
+		projectName, since)
+}
+
+// Subscribes to the events of the docker-compose project containers. This should be done before the containers
+// are started.
+func (dc DockerComposeDriver) streamContainerEvents(runID string) *ContainerEvents {
+	cmd := dc.ConstructDockerEventsCommand(runID, time.Now().Unix())
+	lines, err := dc.ShellService.RunStreamOutput(cmd, dc.Stopping)
+	if err != nil {
+		dc.Logger.Log("debug", fmt.Sprintf("Cannot stream docker events, polling the containers instead: %s", err.Error()))
+		return &ContainerEvents{}
+	}
+	return &ContainerEvents{Lines: lines}
+}
+
+// Stdout and stderr of the container are merged, so that the order of the lines is preserved.
+// With since set to 0, all the logs are printed.
+func (dc DockerComposeDriver) ConstructDockerLogsFollowCommand(containerName string, since int64) string {
+	if since == 0 {
+		return fmt.Sprintf("docker logs --follow %s 2>&1", containerName)
+	}
+	return fmt.Sprintf("docker logs --follow --since %v %s 2>&1", since, containerName)
+}
+
+// Prints the logs of the container live, prefixed with its service name, until the container stops or
+// the stop action is started. Does not block.
+func (dc DockerComposeDriver) followContainerLogs(container *ContainerInfo, since int64) {
+	cmd := dc.ConstructDockerLogsFollowCommand(container.Name, since)
+	lines, err := dc.ShellService.RunStreamOutput(cmd, dc.Stopping)
+	if err != nil {
+		dc.Logger.Log("warn", fmt.Sprintf("Cannot follow the logs of container: %s, error: %s", container.Name, err.Error()))
+		return
+	}
+	go func() {
+		for line := range lines {
+			dc.LogsPrinter.PrintLine(container.Service, line)
+		}
+	}()
+}
+
+// Blocks until a container event with one of the actions (e.g. die) is received and returns it.
+// Returns nil if the stop action was started or on timeout (a nil timeout channel means no timeout).
+// When the events stream ends, switches to polling and returns nil.
+// When polling, sleeps for dcPollingInterval and returns nil.
+func (dc DockerComposeDriver) waitForContainerEvent(events *ContainerEvents, timeout <-chan time.Time, actions ...string) *DockerEvent {
+	if events.Lines == nil {
+		time.Sleep(dcPollingInterval)
+		return nil
+	}
+	for {
+		select {
+		case <-dc.Stopping:
+			return nil
+		case <-timeout:
+			return nil
+		case line, ok := <-events.Lines:
+			if !ok {
+				dc.Logger.Log("debug", "The docker events stream ended, polling the containers instead")
+				events.Lines = nil
+				return nil
+			}
+			event, err := parseDockerEvent(line)
+			if err != nil {
+				dc.Logger.Log("debug", err.Error())
+				continue
+			}
+			for _, action := range actions {
+				// health_status events have actions like: "health_status: healthy"
+				if event.Action == action || strings.HasPrefix(event.Action, action+":") {
+					dc.Logger.Log("debug", fmt.Sprintf("Container: %s event: %s",
+						event.Actor.Attributes["name"], event.Action))
+					return event
+				}
+			}
+		}
+	}
+}
+
+// If wait is true, docker-compose waits until the containers are running or, if they have a healthcheck,
+// healthy, but at most config.HealthTimeout seconds
+func (dc DockerComposeDriver) ConstructDockerComposeCommandUp(config Config, projectName string, services []string,
+	wait bool) string {
+	cmd := dc.ConstructDockerComposeCommandPart1(config, projectName)
+	cmd += " up -d "
+	if wait {
+		cmd += fmt.Sprintf("--wait --wait-timeout %s ", config.HealthTimeout)
+	}
+	cmd += strings.Join(services, " ")
+	return cmd
+}
+
+// Returns the container status (e.g. running) and its health status: starting, healthy, unhealthy or
+// an empty string if the container has no healthcheck.
+func (dc DockerComposeDriver) getContainerHealth(containerName string) (string, string, error) {
+	cmd := fmt.Sprintf("docker inspect --format='{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}' %s",
+		containerName)
+	stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, true)
+	if exitStatus != 0 {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		return "", "", fmt.Errorf("Unexpected exit status:\n%s", cmdInfo)
+	}
+	fields := strings.Fields(stdout)
+	status := ""
+	health := ""
+	if len(fields) > 0 {
+		status = fields[0]
+	}
+	if len(fields) > 1 {
+		health = fields[1]
+	}
+	return status, health, nil
+}
+
+// Starts the containers of the other services than the default one, without attaching to them.
+// If wait is true, also waits until they are healthy, see: ConstructDockerComposeCommandUp.
+func (dc DockerComposeDriver) startContainers(mergedConfig Config, runID string, services []string, wait bool) error {
+	cmd := dc.ConstructDockerComposeCommandUp(mergedConfig, runID, services, wait)
+	dc.Logger.Log("info", fmt.Sprintf("Starting containers with command: \n%v", cmd))
+	exitStatus, _ := dc.ShellService.RunInteractive(cmd, true)
+	if exitStatus != 0 && wait {
+		return fmt.Errorf("Starting containers failed or they were not healthy after %s seconds, exit status: %v, command: %s",
+			mergedConfig.HealthTimeout, exitStatus, cmd)
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("Starting containers failed, exit status: %v, command: %s", exitStatus, cmd)
+	}
+	return nil
+}
+
+// Waits until all the containers of the other services than the default one, which have a healthcheck,
+// are healthy. The health is checked when a container health status changes or a container dies or,
+// if the events cannot be streamed, every dcPollingInterval.
+// Returns an error if any of them stops or if they are not healthy before the timeout.
+func (dc DockerComposeDriver) waitForHealthyContainers(mergedConfig Config, runID string,
+	timeout time.Duration, events *ContainerEvents) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if isChannelClosed(dc.Stopping) {
+			dc.Logger.Log("debug", fmt.Sprintf("Not waiting anymore for containers to be healthy %s", runID))
+			return nil
+		}
+		notHealthy := make([]string, 0)
+		for _, container := range dc.getDCContainers(mergedConfig, runID) {
+			if container.Service == mergedConfig.DockerComposeService {
+				continue
+			}
+			status, health, err := dc.getContainerHealth(container.Name)
+			if err != nil {
+				return err
+			}
+			if health == "" {
+				// no healthcheck
+				continue
+			}
+			if status != "running" {
+				return fmt.Errorf("Container: %s stopped before it was healthy, its status is: %s", container.Name, status)
+			}
+			if health != "healthy" {
+				notHealthy = append(notHealthy, fmt.Sprintf("%s (%s)", container.Name, health))
+			}
+		}
+		if len(notHealthy) == 0 {
+			dc.Logger.Log("info", "All the containers with a healthcheck are healthy")
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Timed out after %v waiting for the containers to be healthy: %s",
+				timeout, strings.Join(notHealthy, ", "))
+		}
+		dc.Logger.Log("debug", fmt.Sprintf("Waiting for the containers to be healthy: %s", strings.Join(notHealthy, ", ")))
+		dc.waitForContainerEvent(events, time.After(time.Until(deadline)), "health_status", "die")
+	}
+}
+
 // Run the docker-compose ps command until it returns some output - containers IDs and
 // then run docker inspect on each container. Return if all the containers are running.
+// The checks are repeated when a container starts or, if the events cannot be streamed, every dcPollingInterval.
 //
 // When docker-compose containers start, docker-compose ps may return not all the containers, because some of them
 // may be not created yet. Thus, we have to know the number of containers specified in docker-compose config file - expContainersCount.
-func (dc DockerComposeDriver) waitForContainersToBeRunning(mergedConfig Config, runID string, expContainersCount int) []string {
+func (dc DockerComposeDriver) waitForContainersToBeRunning(mergedConfig Config, runID string, expContainersCount int,
+	events *ContainerEvents) []*ContainerInfo {
 	dc.Logger.Log("debug", fmt.Sprintf("Start waiting for containers to be initally running, %s", runID))
 
 	for {
 		if isChannelClosed(dc.Stopping) {
 			dc.Logger.Log("debug", fmt.Sprintf("Not waiting anymore for containers %s", runID))
-			return []string{}
+			return []*ContainerInfo{}
 		}
-		containersNames := dc.getDCContainersNames(mergedConfig, runID)
-		if len(containersNames) == 0 {
+		containers := dc.getDCContainers(mergedConfig, runID)
+		if len(containers) == 0 {
 			dc.Logger.Log("debug", fmt.Sprintf("Containers not yet created: %s", runID))
-			time.Sleep(time.Second)
+			dc.waitForContainerEvent(events, nil, "start")
 			continue
-		} else if len(containersNames) != expContainersCount {
+		} else if len(containers) != expContainersCount {
 			dc.Logger.Log("debug", fmt.Sprintf(
-				"Not all the containers created: %s. Want: %v, have: %v", runID, expContainersCount, len(containersNames)))
-			time.Sleep(time.Second)
+				"Not all the containers created: %s. Want: %v, have: %v", runID, expContainersCount, len(containers)))
+			dc.waitForContainerEvent(events, nil, "start")
 			continue
 		} else {
+			containersNames := getContainersNames(containers)
 			dc.Logger.Log("debug",
 				fmt.Sprintf("Containers created. Waiting for them to be initially running: %v", containersNames))
 			allRunning := dc.checkAllContainersRunning(containersNames)
 			if allRunning {
 				dc.Logger.Log("debug", "All containers are running")
-				return containersNames
+				return containers
 			} else {
-				time.Sleep(time.Second)
+				dc.waitForContainerEvent(events, nil, "start")
 				continue
 			}
 		}
 	}
 }
 
-func (dc DockerComposeDriver) watchContainers(mergedConfig Config, runID string, expContainersCount int) {
-	if mergedConfig.ExitBehavior == "ignore" {
+// Checks the containers when any of them dies or, if the events cannot be streamed, every dcPollingInterval.
+// The containers, which exits are ignored, are not checked.
+func (dc DockerComposeDriver) watchContainers(mergedConfig Config, runID string, expContainersCount int, events *ContainerEvents) {
+	exitBehaviors, err := parseExitBehaviors(mergedConfig.ExitBehavior)
+	if err != nil {
+		panic(err)
+	}
+	if exitBehaviors.AllIgnored() {
 		return
 	}
 	dc.Logger.Log("debug", fmt.Sprintf(
 		"Start watching docker-compose containers %s in a forever loop, exitBehavior is: %s",
-		runID, mergedConfig.ExitBehavior))
+		runID, exitBehaviors))
 
-	names := dc.waitForContainersToBeRunning(mergedConfig, runID, expContainersCount)
+	allContainers := dc.waitForContainersToBeRunning(mergedConfig, runID, expContainersCount, events)
+	containers := make([]*ContainerInfo, 0)
+	for _, container := range allContainers {
+		// the default container is watched to know when to stop watching
+		if container.Service == mergedConfig.DockerComposeService || exitBehaviors.Get(container.Service) != "ignore" {
+			containers = append(containers, container)
+		}
+	}
 	for {
 		if isChannelClosed(dc.Stopping) {
 			dc.Logger.Log("debug", fmt.Sprintf("Stop watching docker-compose containers %s", runID))
 			return
 		}
 
-		for _, name := range names {
+		toCheck := containers
+		if events.Lines != nil {
+			event := dc.waitForContainerEvent(events, nil, "die")
+			if event == nil {
+				continue
+			}
+			toCheck = []*ContainerInfo{}
+			for _, container := range containers {
+				if container.Name == event.Actor.Attributes["name"] {
+					toCheck = append(toCheck, container)
+				}
+			}
+		}
+
+		for _, container := range toCheck {
 			if isChannelClosed(dc.Stopping) {
 				dc.Logger.Log("debug", fmt.Sprintf("Stop watching docker-compose containers %s", runID))
 				return
 			}
-			running := dc.checkContainerIsRunning(name)
+			// even after a die event, check the container, because it could have been already restarted
+			running := dc.checkContainerIsRunning(container.Name)
 			if !running {
-				if mergedConfig.ExitBehavior == "restart" {
-					dc.Logger.Log("info", fmt.Sprintf("Container: %s stopped by itself. Starting...", name))
-					cmd := fmt.Sprintf("docker start %s", name)
-					stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, false)
-					ci := cmdInfoToString(cmd, stdout, stderr, exitStatus)
-					dc.Logger.Log("info", fmt.Sprintf("Started: %s\n  %s", name, ci))
-				} else if mergedConfig.ExitBehavior == "abort" {
-					if strings.Contains(name, "_default_") || strings.Contains(name, "-default-") {
-						dc.Logger.Log("debug", "Stop watching containers. Default container stopped.")
-						return
-					}
-					dc.Logger.Log("info", fmt.Sprintf("Container: %s stopped by itself. Stopping the default container...", name))
-					defaultCont := dc.getDefaultContainerID(names)
-					if defaultCont == "" {
-						dc.Logger.Log("debug", "Stop watching containers. Default container already removed")
-						return
-					}
-					cmd := fmt.Sprintf("docker stop %s", defaultCont)
-					stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, false)
-					ci := cmdInfoToString(cmd, stdout, stderr, exitStatus)
-					dc.Logger.Log("info", fmt.Sprintf("Stopped: %s.\n%s", defaultCont, ci))
+				stopWatching := dc.handleContainerStopped(mergedConfig, exitBehaviors, containers, container)
+				if stopWatching {
+					return
 				}
 			}
 		}
-		time.Sleep(time.Second)
+		if events.Lines == nil {
+			time.Sleep(dcPollingInterval)
+		}
+	}
+}
+
+// Reacts to a container which stopped by itself, according to the exit behavior of its service.
+// With the exit behavior restart, the container is started after a backoff. After MaxRestarts restarts,
+// the exit behavior abort is used instead.
+// Returns true if the containers should not be watched anymore.
+func (dc DockerComposeDriver) handleContainerStopped(mergedConfig Config, exitBehaviors ExitBehaviors,
+	containers []*ContainerInfo, container *ContainerInfo) bool {
+	name := container.Name
+	if container.Service == mergedConfig.DockerComposeService {
+		dc.Logger.Log("debug", "Stop watching containers. Default container stopped.")
+		return true
+	}
+	exitBehavior := exitBehaviors.Get(container.Service)
+	if exitBehavior == "restart" {
+		maxRestarts, err := strconv.Atoi(mergedConfig.MaxRestarts)
+		if err != nil {
+			panic(err)
+		}
+		if maxRestarts > 0 && dc.Restarts.Get(name) >= maxRestarts {
+			dc.Logger.Log("info", fmt.Sprintf(
+				"Container: %s stopped by itself and it was already restarted %v times, which is the maximum. Not restarting it anymore.",
+				name, maxRestarts))
+			exitBehavior = "abort"
+		} else {
+			restart := dc.Restarts.Increment(name)
+			backoff := getRestartBackoff(dc.RestartBackoff, restart)
+			dc.Logger.Log("info", fmt.Sprintf("Container: %s stopped by itself. Starting it in %v (restart: %v)...",
+				name, backoff, restart))
+			select {
+			case <-dc.Stopping:
+				dc.Logger.Log("debug", fmt.Sprintf("Not starting: %s, containers are being stopped", name))
+				return true
+			case <-time.After(backoff):
+			}
+			startTime := time.Now().Unix()
+			cmd := fmt.Sprintf("docker start %s", name)
+			stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, false)
+			ci := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+			dc.Logger.Log("debug", fmt.Sprintf("Started: %s\n  %s", name, ci))
+			if mergedConfig.PrintLogs == "stream" && exitStatus == 0 {
+				// following the logs stopped, when the container stopped
+				dc.followContainerLogs(container, startTime)
+			}
+		}
+	}
+	if exitBehavior == "abort" {
+		dc.Logger.Log("info", fmt.Sprintf("Container: %s stopped by itself. Stopping the default container...", name))
+		defaultCont := dc.getDefaultContainerID(containers, mergedConfig.DockerComposeService)
+		if defaultCont == "" {
+			dc.Logger.Log("debug", "Stop watching containers. Default container already removed")
+			return true
+		}
+		cmd := fmt.Sprintf("docker stop %s", defaultCont)
+		stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, false)
+		ci := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		dc.Logger.Log("info", fmt.Sprintf("Stopped: %s.\n%s", defaultCont, ci))
 	}
+	return false
 }
 
 func safelyCloseChannel(ch chan bool) (justClosed bool) {
@@ -356,13 +655,14 @@ func safelyCloseChannel(ch chan bool) (justClosed bool) {
 	return true // <=> justClosed = true; return
 }
 
-func (dc DockerComposeDriver) getNonDefaultContainersInfos(containersNames []string) []*ContainerInfo {
+// Returns the current information about all the containers, which do not belong to the default service.
+func (dc DockerComposeDriver) getNonDefaultContainersInfos(containers []*ContainerInfo, defaultService string) []*ContainerInfo {
 	containerInfos := make([]*ContainerInfo, 0)
-	for _, containerName := range containersNames {
-		if strings.Contains(containerName, "_default_") || strings.Contains(containerName, "-default-") {
+	for _, container := range containers {
+		if container.Service == defaultService {
 			continue
 		} else {
-			containerInfo, err := getContainerInfo(dc.ShellService, containerName)
+			containerInfo, err := getContainerInfo(dc.ShellService, container.Name)
 			if err != nil {
 				panic(err)
 			}
@@ -372,10 +672,10 @@ func (dc DockerComposeDriver) getNonDefaultContainersInfos(containersNames []str
 	return containerInfos
 }
 
-func (dc DockerComposeDriver) getNonDefaultContainersLogs(containerInfos []*ContainerInfo) {
+func (dc DockerComposeDriver) getNonDefaultContainersLogs(containerInfos []*ContainerInfo, defaultService string) {
 	for _, containerInfo := range containerInfos {
 		containerName := containerInfo.Name
-		if strings.Contains(containerName, "_default_") || strings.Contains(containerName, "-default-") {
+		if containerInfo.Service == defaultService {
 			continue
 		} else {
 			cmd := fmt.Sprintf("docker logs %s", containerName)
@@ -401,7 +701,7 @@ func checkIfAnyContainerFailed(nonDefaultContainerInfos []*ContainerInfo, defaul
 }
 
 func (d DockerComposeDriver) PrintVersion() {
-	version_cmd := "docker-compose --version"
+	version_cmd := fmt.Sprintf("%s version", d.DockerComposeCommand)
 	stdout, stderr, exitStatus, _ := d.ShellService.RunGetOutput(version_cmd, true)
 	if exitStatus != 0 {
 		cmdInfo := cmdInfoToString(version_cmd, stdout, stderr, exitStatus)
@@ -411,29 +711,117 @@ func (d DockerComposeDriver) PrintVersion() {
 	}
 }
 
+// Parses the options, which can refer to docker-compose services, and verifies that the services
+// are in the docker-compose file (expServices).
+func getServicesOptions(mergedConfig Config, expServices []string) (ExitBehaviors, SidecarFailurePolicy, error) {
+	exitBehaviors, err := parseExitBehaviors(mergedConfig.ExitBehavior)
+	if err != nil {
+		return ExitBehaviors{}, SidecarFailurePolicy{}, err
+	}
+	err = exitBehaviors.verifyServices(expServices, mergedConfig.DockerComposeService)
+	if err != nil {
+		return ExitBehaviors{}, SidecarFailurePolicy{}, err
+	}
+	sidecarFailurePolicy, err := parseFailOnSidecarFailure(mergedConfig.FailOnSidecarFailure)
+	if err != nil {
+		return ExitBehaviors{}, SidecarFailurePolicy{}, err
+	}
+	err = sidecarFailurePolicy.verifyServices(expServices, mergedConfig.DockerComposeService)
+	if err != nil {
+		return ExitBehaviors{}, SidecarFailurePolicy{}, err
+	}
+	return exitBehaviors, sidecarFailurePolicy, nil
+}
+
 func (dc DockerComposeDriver) HandleRun(mergedConfig Config, runID string, envService EnvServiceInterface) int {
 	warnGeneral(dc.FileService, mergedConfig, envService, dc.Logger)
 	envFile, envFileMultiLine, envFileBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
 	saveEnvToFile(dc.FileService, envFile, envFileMultiLine, envFileBashFunctions,
 		mergedConfig.BlacklistVariables, envService.GetVariables())
-	dojoDCGeneratedFile, err := dc.handleDCFiles(mergedConfig)
+	dojoDCGeneratedFile, overrideFile, dcFile, err := dc.handleDCFiles(mergedConfig)
 	if err != nil {
 		return 1
 	}
 	expContainers := dc.getExpectedContainers(mergedConfig, runID)
-	additionalContents := dc.generateDCFileContentsWithEnv(expContainers, mergedConfig, envFile, envFileMultiLine, envFileBashFunctions)
-	dc.FileService.AppendContents(dojoDCGeneratedFile, additionalContents, "debug")
+	exitBehaviors, sidecarFailurePolicy, err := getServicesOptions(mergedConfig, expContainers)
+	if err != nil {
+		dc.Logger.Log("error", err.Error())
+		return 1
+	}
+	dc.addEnvToDCOverrideFile(overrideFile, expContainers, mergedConfig, envFile, envFileMultiLine, envFileBashFunctions)
+	dc.FileService.WriteToFile(dojoDCGeneratedFile, overrideFile.String(), "debug")
+	if mergedConfig.DockerBuildContext != "" {
+		err = buildImageIfMissing(dc.ShellService, dc.Logger, "docker", mergedConfig)
+	}
+	if err == nil {
+		err = dc.pullImages(mergedConfig, dcFile)
+	}
+	if err != nil {
+		dc.Logger.Log("error", err.Error())
+		return imageFailureExitStatus
+	}
 
 	cmd := dc.ConstructDockerComposeCommandRun(mergedConfig, runID)
+	if mergedConfig.RemoveContainers != "true" {
+		saveRunIDToDojoRC(dc.FileService, runID)
+	}
+	healthTimeout, _ := strconv.Atoi(mergedConfig.HealthTimeout)
+	otherServices := make([]string, 0)
+	for _, service := range expContainers {
+		if service != mergedConfig.DockerComposeService {
+			otherServices = append(otherServices, service)
+		}
+	}
+	waitForHealthy := healthTimeout > 0 && len(otherServices) > 0
+	// docker-compose waits itself, if it can, otherwise dojo inspects the containers
+	composeWaits := waitForHealthy && dc.Capabilities.Wait
+	// the other containers are started before the default one, so that their logs can be followed from the start
+	streamLogs := mergedConfig.PrintLogs == "stream" && len(otherServices) > 0
+	events := &ContainerEvents{}
+	if !exitBehaviors.AllIgnored() || (waitForHealthy && !composeWaits) {
+		events = dc.streamContainerEvents(runID)
+	}
+	if (waitForHealthy || streamLogs) && !isChannelClosed(dc.Stopping) {
+		err := dc.startContainers(mergedConfig, runID, otherServices, composeWaits)
+		if err == nil && streamLogs {
+			dc.LogsPrinter.SetServices(otherServices, dc.ShellService.CheckIfInteractive())
+			for _, container := range dc.getDCContainers(mergedConfig, runID) {
+				if container.Service != mergedConfig.DockerComposeService {
+					dc.followContainerLogs(container, 0)
+				}
+			}
+		}
+		if err == nil && waitForHealthy && !composeWaits {
+			err = dc.waitForHealthyContainers(mergedConfig, runID, time.Duration(healthTimeout)*time.Second, events)
+		}
+		if err != nil {
+			dc.Logger.Log("error", err.Error())
+			containers := dc.getDCContainers(mergedConfig, runID)
+			containersInfos := dc.getNonDefaultContainersInfos(containers, mergedConfig.DockerComposeService)
+			// when streaming, the logs were already printed
+			if mergedConfig.PrintLogs != "never" && mergedConfig.PrintLogs != "stream" {
+				dc.printNonDefaultContainersLogs(mergedConfig, runID, containersInfos)
+			}
+			dc.addToRunReport(containersInfos)
+			dc.stop(mergedConfig, runID, "")
+			if mergedConfig.LogsDir != "" {
+				dc.saveLogsBundle(mergedConfig, runID, containers)
+			}
+			return 1
+		}
+	}
 	if isChannelClosed(dc.Stopping) {
 		dc.Logger.Log("info", "Aborting containers start")
 		return 0
 	}
 
 	dc.Logger.Log("info", green(fmt.Sprintf("docker-compose run command will be:\n %v", cmd)))
-	go dc.watchContainers(mergedConfig, runID, len(expContainers))
+	go dc.watchContainers(mergedConfig, runID, len(expContainers), events)
 	exitStatus, _ := dc.ShellService.RunInteractive(cmd, true)
 	dc.Logger.Log("debug", fmt.Sprintf("Exit status from run command: %v", exitStatus))
+	if restartsReport := dc.Restarts.Report(); restartsReport != "" {
+		dc.Logger.Log("info", restartsReport)
+	}
 	// Here:
 	// * either "docker-compose run" finished by itself, we expect the default container to be stopped/removed. Let's stop the
 	//   other containers.
@@ -441,43 +829,134 @@ func (dc DockerComposeDriver) HandleRun(mergedConfig Config, runID string, envSe
 	// may be removed)
 
 	dc.Logger.Log("debug", fmt.Sprintf("Collecting information from non default containers"))
-	containersNames := dc.getDCContainersNames(mergedConfig, runID)
-	containersInfos := dc.getNonDefaultContainersInfos(containersNames)
+	containers := dc.getDCContainers(mergedConfig, runID)
+	containersInfos := dc.getNonDefaultContainersInfos(containers, mergedConfig.DockerComposeService)
 	anyContainerFailed := checkIfAnyContainerFailed(containersInfos, exitStatus)
 	if mergedConfig.PrintLogs == "always" || (mergedConfig.PrintLogs == "failure" && anyContainerFailed) {
-		dc.Logger.Log("debug", fmt.Sprintf("Getting non default containers logs"))
-		dc.getNonDefaultContainersLogs(containersInfos)
-		dc.Logger.Log("debug", fmt.Sprintf("Got logs from %s containers", fmt.Sprint(len(containersInfos))))
-		for _, v := range containersInfos {
-			containerInfo := v
-			status := containerInfo.Status
-			statusMsg := ""
-			if status == "running" {
-				statusMsg = fmt.Sprintf("which status is: %s", status)
-			} else if status == "exited" {
-				statusMsg = fmt.Sprintf("which exited with exitcode: %s", containerInfo.ExitCode)
-			} else {
-				statusMsg = fmt.Sprintf("which status is: %s, exitcode: %s", status, containerInfo.ExitCode)
-			}
+		dc.printNonDefaultContainersLogs(mergedConfig, runID, containersInfos)
+	}
 
-			if mergedConfig.PrintLogsTarget == "file" {
-				logsFilePath := "dojo-logs-" + containerInfo.Name + "-" + runID + ".txt"
-				dc.FileService.WriteToFile(logsFilePath, containerInfo.Logs, "debug")
-				dc.Logger.Log("info", fmt.Sprintf("The logs of container: %s, %s, were saved to file: %s",
-					containerInfo.Name, statusMsg, logsFilePath))
-			} else {
-				dc.Logger.Log("info", fmt.Sprintf("Here are logs of container: %s, %s:\n%s",
-					containerInfo.Name, statusMsg, containerInfo.Logs))
-			}
+	failedContainers := sidecarFailurePolicy.getFailedContainers(containersInfos)
+	dc.addToRunReport(containersInfos)
+	// the default container may be already removed, its exit code is the exit status of the run command
+	defaultContainer := RunReportContainer{Service: mergedConfig.DockerComposeService, Default: true,
+		Status: "exited", ExitCode: fmt.Sprint(exitStatus)}
+	for _, container := range containers {
+		if container.Service == mergedConfig.DockerComposeService {
+			defaultContainer.Name = container.Name
 		}
 	}
+	dc.ReportContainers.Add(defaultContainer)
 
 	dc.stop(mergedConfig, runID, "")
+	if mergedConfig.LogsDir != "" {
+		dc.saveLogsBundle(mergedConfig, runID, containers)
+	}
+	if exitStatus == 0 && len(failedContainers) > 0 {
+		failed := make([]string, 0)
+		for _, containerInfo := range failedContainers {
+			failed = append(failed, fmt.Sprintf("%s (service: %s, exit code: %s)",
+				containerInfo.Name, containerInfo.Service, containerInfo.ExitCode))
+		}
+		dc.Logger.Log("error", fmt.Sprintf(
+			"The default container succeeded, but these containers failed: %s. Exiting with status: %v",
+			strings.Join(failed, ", "), dcSidecarFailureExitStatus))
+		return dcSidecarFailureExitStatus
+	}
 	return exitStatus
 
 	// do not clean now, containers may be being stopped in other goroutines
 }
 
+// Prints the logs of the non-default containers or saves them to files, depending on the PrintLogsTarget
+func (dc DockerComposeDriver) printNonDefaultContainersLogs(mergedConfig Config, runID string, containersInfos []*ContainerInfo) {
+	dc.Logger.Log("debug", fmt.Sprintf("Getting non default containers logs"))
+	dc.getNonDefaultContainersLogs(containersInfos, mergedConfig.DockerComposeService)
+	dc.Logger.Log("debug", fmt.Sprintf("Got logs from %s containers", fmt.Sprint(len(containersInfos))))
+	for _, v := range containersInfos {
+		containerInfo := v
+		status := containerInfo.Status
+		statusMsg := ""
+		if status == "running" {
+			statusMsg = fmt.Sprintf("which status is: %s", status)
+		} else if status == "exited" {
+			statusMsg = fmt.Sprintf("which exited with exitcode: %s", containerInfo.ExitCode)
+		} else {
+			statusMsg = fmt.Sprintf("which status is: %s, exitcode: %s", status, containerInfo.ExitCode)
+		}
+
+		if mergedConfig.PrintLogsTarget == "file" {
+			logsFilePath := "dojo-logs-" + containerInfo.Name + "-" + runID + ".txt"
+			dc.FileService.WriteToFile(logsFilePath, containerInfo.Logs, "debug")
+			dc.Logger.Log("info", fmt.Sprintf("The logs of container: %s, %s, were saved to file: %s",
+				containerInfo.Name, statusMsg, logsFilePath))
+		} else {
+			dc.Logger.Log("info", fmt.Sprintf("Here are logs of container: %s, %s:\n%s",
+				containerInfo.Name, statusMsg, containerInfo.Logs))
+		}
+	}
+}
+
+func (dc DockerComposeDriver) addToRunReport(containersInfos []*ContainerInfo) {
+	for _, containerInfo := range containersInfos {
+		dc.ReportContainers.Add(RunReportContainer{Name: containerInfo.Name, Service: containerInfo.Service,
+			Status: containerInfo.Status, ExitCode: containerInfo.ExitCode})
+	}
+}
+
+func (dc DockerComposeDriver) FillRunReport(mergedConfig Config, report *RunReport) {
+	report.Image = mergedConfig.DockerImage
+	report.ImageDigest = getImageDigest(dc.ShellService, "docker", mergedConfig.DockerImage)
+	report.Containers = dc.ReportContainers.Get()
+}
+
+func getLogsBundleDir(mergedConfig Config, runID string) string {
+	return filepath.Join(mergedConfig.LogsDir, "dojo-logs-"+runID)
+}
+
+// Saves the logs bundle of the run to a directory in the LogsDir: the logs of each container, with timestamps,
+// the output of docker inspect of each container and the generated docker-compose file. If LogsArchive is true,
+// the directory is also packed into a tar.gz file. This should be done after the containers are stopped.
+func (dc DockerComposeDriver) saveLogsBundle(mergedConfig Config, runID string, containers []*ContainerInfo) {
+	bundleDir := getLogsBundleDir(mergedConfig, runID)
+	dc.FileService.CreateDir(bundleDir)
+	for _, container := range containers {
+		cmd := fmt.Sprintf("docker logs --timestamps %s", container.Name)
+		stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, true)
+		if exitStatus != 0 {
+			dc.Logger.Log("warn", fmt.Sprintf("Cannot save the logs of container: %s\n%s",
+				container.Name, cmdInfoToString(cmd, stdout, stderr, exitStatus)))
+		} else {
+			dc.FileService.WriteToFile(filepath.Join(bundleDir, container.Name+".log"),
+				mergeTimestampedLogs(stdout, stderr), "debug")
+		}
+
+		cmd = fmt.Sprintf("docker inspect %s", container.Name)
+		stdout, stderr, exitStatus, _ = dc.ShellService.RunGetOutput(cmd, true)
+		if exitStatus != 0 {
+			dc.Logger.Log("warn", fmt.Sprintf("Cannot save the docker inspect output of container: %s\n%s",
+				container.Name, cmdInfoToString(cmd, stdout, stderr, exitStatus)))
+		} else {
+			dc.FileService.WriteToFile(filepath.Join(bundleDir, container.Name+".inspect.json"), stdout, "debug")
+		}
+	}
+	dojoDCGeneratedFile := dc.getDCGeneratedFilePath(mergedConfig.DockerComposeFile)
+	dc.FileService.WriteToFile(filepath.Join(bundleDir, filepath.Base(dojoDCGeneratedFile)),
+		dc.FileService.ReadFile(dojoDCGeneratedFile), "debug")
+	dc.Logger.Log("info", fmt.Sprintf("The logs bundle was saved to directory: %s", bundleDir))
+
+	if mergedConfig.LogsArchive == "true" {
+		archive := bundleDir + ".tar.gz"
+		cmd := fmt.Sprintf("tar -czf %s -C %s %s", archive, mergedConfig.LogsDir, filepath.Base(bundleDir))
+		stdout, stderr, exitStatus, _ := dc.ShellService.RunGetOutput(cmd, true)
+		if exitStatus != 0 {
+			dc.Logger.Log("warn", fmt.Sprintf("Cannot pack the logs bundle\n%s", cmdInfoToString(cmd, stdout, stderr, exitStatus)))
+			return
+		}
+		dc.Logger.Log("info", fmt.Sprintf("The logs bundle was packed into file: %s", archive))
+	}
+}
+
 func (dc DockerComposeDriver) CleanAfterRun(mergedConfig Config, runID string) int {
 	if mergedConfig.RemoveContainers == "true" {
 		dc.Logger.Log("debug", "Cleaning, because RemoveContainers is set to true")
@@ -495,6 +974,8 @@ func (dc DockerComposeDriver) CleanAfterRun(mergedConfig Config, runID string) i
 		return exitStatus
 	} else {
 		dc.Logger.Log("debug", "Not cleaning, because RemoveContainers is not set to true")
+		dc.Logger.Log("info", fmt.Sprintf(
+			"The containers of project: %s were stopped, but not removed. Remove them with: dojo --action=clean", runID))
 		return 0
 	}
 }
@@ -526,17 +1007,72 @@ func (dc DockerComposeDriver) stop(mergedConfig Config, runID string, defaultCon
 	return exitStatus
 }
 
-func (dc DockerComposeDriver) handleDCFiles(mergedConfig Config) (string, error) {
+// Verifies the docker-compose file and writes the initial dojo docker-compose file.
+// Returns: the dojo docker-compose file path and contents, so that more contents can be added.
+func (dc DockerComposeDriver) handleDCFiles(mergedConfig Config) (string, *DCOverrideFile, DCFile, error) {
 	fileContents := dc.FileService.ReadDockerComposeFile(mergedConfig.DockerComposeFile)
-	version, err := dc.verifyDCFile(fileContents, mergedConfig.DockerComposeFile)
+	dcFile, err := dc.verifyDCFile(fileContents, mergedConfig.DockerComposeFile, mergedConfig.DockerComposeService)
 	if err != nil {
 		dc.Logger.Log("error", fmt.Sprintf("Docker-compose file %s is not correct: %s", mergedConfig.DockerComposeFile, err.Error()))
-		return "", err
+		return "", nil, DCFile{}, err
+	}
+	if mergedConfig.DockerImage == "" {
+		image, built := dcFile.ServiceImage(mergedConfig.DockerComposeService)
+		if image == "" && !built {
+			err = fmt.Errorf("Invalid configuration, DockerImage is unset and service %s has neither image nor build in: %s",
+				mergedConfig.DockerComposeService, mergedConfig.DockerComposeFile)
+			dc.Logger.Log("error", err.Error())
+			return "", nil, DCFile{}, err
+		}
+	}
+	overrideFile := dc.generateInitialDCFile(mergedConfig, dcFile.Version)
+	dojoDCFileName := dc.getDCGeneratedFilePath(mergedConfig.DockerComposeFile)
+	dc.FileService.WriteToFile(dojoDCFileName, overrideFile.String(), "debug")
+	return dojoDCFileName, overrideFile, dcFile, nil
+}
+
+// Returns the images to be pulled before a run: the image of the default service (DockerImage) and
+// the images of the other services. Skipped are: the services which are built (by dojo from the
+// DockerBuildContext or by docker-compose, which builds and tags their images) and the images
+// with variables, which only docker-compose can interpolate.
+func (dc DockerComposeDriver) getImagesToPull(mergedConfig Config, dcFile DCFile) []string {
+	images := make([]string, 0)
+	added := make(map[string]bool)
+	for _, service := range dcFile.ServicesNames {
+		image, built := dcFile.ServiceImage(service)
+		if service == mergedConfig.DockerComposeService {
+			if mergedConfig.DockerBuildContext != "" {
+				continue
+			}
+			if mergedConfig.DockerImage != "" {
+				// the image set in the dojo docker-compose file takes precedence
+				image, built = mergedConfig.DockerImage, false
+			}
+		}
+		if image == "" || built {
+			continue
+		}
+		if strings.Contains(image, "$") {
+			dc.Logger.Log("debug", fmt.Sprintf("Not pulling the image of service: %s, it contains variables: %s", service, image))
+			continue
+		}
+		if !added[image] {
+			images = append(images, image)
+			added[image] = true
+		}
+	}
+	return images
+}
+
+// Pulls the images of all the services according to the PullPolicy
+func (dc DockerComposeDriver) pullImages(mergedConfig Config, dcFile DCFile) error {
+	for _, image := range dc.getImagesToPull(mergedConfig, dcFile) {
+		err := pullImageWithPolicy(dc.ShellService, dc.Logger, "docker", image, mergedConfig.PullPolicy)
+		if err != nil {
+			return err
+		}
 	}
-	dojoDCFileContents := dc.generateInitialDCFile(mergedConfig, version)
-	dojoDCFileName := mergedConfig.DockerComposeFile + ".dojo"
-	dc.FileService.WriteToFile(dojoDCFileName, dojoDCFileContents, "debug")
-	return dojoDCFileName, nil
+	return nil
 }
 
 func (dc DockerComposeDriver) ConstructDockerComposeCommandPull(config Config, dojoGeneratedDCFile string) string {
@@ -547,13 +1083,31 @@ func (dc DockerComposeDriver) ConstructDockerComposeCommandPull(config Config, d
 }
 
 func (dc DockerComposeDriver) HandlePull(mergedConfig Config) int {
-	dojoDCGeneratedFile, err := dc.handleDCFiles(mergedConfig)
+	dojoDCGeneratedFile, _, dcFile, err := dc.handleDCFiles(mergedConfig)
 	if err != nil {
 		return 1
 	}
 	defer dc.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, dojoDCGeneratedFile)
 
 	cmd := dc.ConstructDockerComposeCommandPull(mergedConfig, dojoDCGeneratedFile)
+	if mergedConfig.DockerBuildContext != "" {
+		// the image of the default service is built, there is nothing to pull for it
+		err = buildImageIfMissing(dc.ShellService, dc.Logger, "docker", mergedConfig)
+		if err != nil {
+			dc.Logger.Log("error", err.Error())
+			return 1
+		}
+		otherServices := make([]string, 0)
+		for _, service := range dcFile.ServicesNames {
+			if service != mergedConfig.DockerComposeService {
+				otherServices = append(otherServices, service)
+			}
+		}
+		if len(otherServices) == 0 {
+			return 0
+		}
+		cmd += " " + strings.Join(otherServices, " ")
+	}
 	dc.Logger.Log("info", green(fmt.Sprintf("docker-compose pull command will be:\n %v", cmd)))
 	exitStatus, _ := dc.ShellService.RunInteractive(cmd, false)
 	dc.Logger.Log("debug", fmt.Sprintf("Exit status from pull command: %v", exitStatus))
@@ -568,13 +1122,13 @@ func (dc DockerComposeDriver) HandleSignal(mergedConfig Config, runID string) in
 		return 0
 	}
 
-	names := dc.getDCContainersNames(mergedConfig, runID)
-	if len(names) == 0 {
+	containers := dc.getDCContainers(mergedConfig, runID)
+	if len(containers) == 0 {
 		dc.Logger.Log("info", fmt.Sprintf("Stopping not needed, the containers were not created: %s", runID))
 		return 0
 	}
 
-	defaultContainerID := dc.getDefaultContainerID(names)
+	defaultContainerID := dc.getDefaultContainerID(containers, mergedConfig.DockerComposeService)
 	es := dc.stop(mergedConfig, runID, defaultContainerID)
 	dc.Logger.Log("info", "Stopping on signal finished")
 	return es
@@ -608,22 +1162,25 @@ func (dc DockerComposeDriver) kill(mergedConfig Config, runID string, defaultCon
 func (dc DockerComposeDriver) HandleMultipleSignal(mergedConfig Config, runID string) int {
 	dc.Logger.Log("info", "Stopping on multiple signals")
 
-	names := dc.getDCContainersNames(mergedConfig, runID)
-	if len(names) == 0 {
+	containers := dc.getDCContainers(mergedConfig, runID)
+	if len(containers) == 0 {
 		dc.Logger.Log("info", fmt.Sprintf("Stopping not needed, the containers were not created: %s", runID))
 		return 0
 	}
 
-	defaultContainerID := dc.getDefaultContainerID(names)
+	defaultContainerID := dc.getDefaultContainerID(containers, mergedConfig.DockerComposeService)
 	es := dc.kill(mergedConfig, runID, defaultContainerID)
 	dc.Logger.Log("info", "Stopping on multiple signals finished")
 	return es
 }
 
-func (dc DockerComposeDriver) getDefaultContainerID(containersNames []string) string {
-	for _, containerName := range containersNames {
-		if strings.Contains(containerName, "_default_") || strings.Contains(containerName, "-default-") {
-			contanerInfo, err := getContainerInfo(dc.ShellService, containerName)
+// Returns the ID of the container of the default service, found by the label: com.docker.compose.service.
+// Returns an empty string if the container was already removed or was not created yet, e.g. when
+// the other containers are started first to wait until they are healthy.
+func (dc DockerComposeDriver) getDefaultContainerID(containers []*ContainerInfo, defaultService string) string {
+	for _, container := range containers {
+		if container.Service == defaultService {
+			contanerInfo, err := getContainerInfo(dc.ShellService, container.Name)
 			if !contanerInfo.Exists {
 				return ""
 			}
@@ -634,7 +1191,8 @@ func (dc DockerComposeDriver) getDefaultContainerID(containersNames []string) st
 			return contanerInfo.ID
 		}
 	}
-	panic(fmt.Errorf("default container not found. Were the containers created?"))
+	dc.Logger.Log("debug", "Default container not found, it was not created yet")
+	return ""
 }
 
 // Example output of `docker-compose ps` command, when using docker-compose <2:
@@ -652,8 +1210,12 @@ func (dc DockerComposeDriver) getDefaultContainerID(containersNames []string) st
 //
 // The output for docker-compose >2 does not show the default container. In order to have it included, we need to run `ps --all`
 //
-// Returns: container names
-func (dc DockerComposeDriver) getDCContainersNames(mergedConfig Config, projectName string) []string {
+// The output for docker-compose <2 does not contain the labels, thus each container is inspected to get them.
+// The containers are told apart using the labels: com.docker.compose.project and com.docker.compose.service,
+// not using their names, because the names differ between docker-compose versions.
+//
+// Returns: the containers, with at least: Name, Project and Service set
+func (dc DockerComposeDriver) getDCContainers(mergedConfig Config, projectName string) []*ContainerInfo {
 	if projectName == "" {
 		panic("projectName was empty")
 	}
@@ -669,26 +1231,35 @@ func (dc DockerComposeDriver) getDCContainersNames(mergedConfig Config, projectN
 			// https://github.com/docker/compose/issues/10373
 			// and this makes the tests such as test_docker_compose_run_preserves_bash_functions flaky
 			dc.Logger.Log("error", fmt.Sprintf("Unexpected exit status:\n%s", cmdInfo))
-			return []string{}
+			return []*ContainerInfo{}
 		} else {
 			panic(fmt.Errorf("Unexpected exit status:\n%s", cmdInfo))
 		}
 	}
 
-	if isDCVersionLaterThan2(dc.DockerComposeVersion) {
-		jsonOutputArr, err := ParseDCPSOutPut_DCVersion2(stdout)
+	if dc.Capabilities.PSFormatJSON {
+		jsonOutputArr, err := ParseDCPSOutPut_DCVersion2(stdout, dc.Capabilities.PSJSONArray)
 		if err != "" {
 			// It seems that nothing in this function needs to panic.
 			// Even if there are errors, the main functionality could still work, but additional features
 			// (such as printing logs to file) would be affected.
 			dc.Logger.Log("error", err)
-			return []string{}
+			return []*ContainerInfo{}
 		}
-		containersNames := make([]string, 0)
+		containers := make([]*ContainerInfo, 0)
 		for _, container := range jsonOutputArr {
-			containersNames = append(containersNames, container.Name)
+			// the fields Project and Service are set by docker-compose from the labels
+			containers = append(containers, &ContainerInfo{
+				ID:       container.ID,
+				Name:     container.Name,
+				Status:   container.State,
+				ExitCode: fmt.Sprint(container.ExitCode),
+				Exists:   true,
+				Project:  container.Project,
+				Service:  container.Service,
+			})
 		}
-		return containersNames
+		return containers
 	}
 
 	// getting container names while using docker-compose <2
@@ -705,40 +1276,64 @@ func (dc DockerComposeDriver) getDCContainersNames(mergedConfig Config, projectN
 		// the last line of output is the one with -----;
 		// if using Colima, there is just 1 line of output
 		dc.Logger.Log("debug", "Containers were not yet created")
-		return []string{}
+		return []*ContainerInfo{}
 	}
 	containerLines := lines[2:]
-	containersNames := make([]string, 0)
+	containers := make([]*ContainerInfo, 0)
 	for _, line := range containerLines {
 		split := strings.Split(line, " ")
 		containerName := split[0]
-		containersNames = append(containersNames, containerName)
+		containerInfo, err := getContainerInfo(dc.ShellService, containerName)
+		if err != nil {
+			panic(err)
+		}
+		if !containerInfo.Exists {
+			dc.Logger.Log("debug", fmt.Sprintf("Container: %s was already removed", containerName))
+			continue
+		}
+		containers = append(containers, containerInfo)
 	}
-	return containersNames
+	return containers
+}
+
+func getContainersNames(containers []*ContainerInfo) []string {
+	names := make([]string, 0)
+	for _, container := range containers {
+		names = append(names, container.Name)
+	}
+	return names
+}
+
+type DC2PSPublisher struct {
+	URL           string `json:"URL"`
+	TargetPort    int    `json:"TargetPort"`
+	PublishedPort int    `json:"PublishedPort"`
+	Protocol      string `json:"Protocol"`
 }
 
 type DC2PSOutput struct {
-	Command    string   `json:"Command"`
-	CreatedAt  string   `json:"CreatedAt"`
-	ExitCode   int      `json:"ExitCode"`
-	Health     string   `json:"Health"`
-	ID         string   `json:"ID"`
-	Image      string   `json:"Image"`
-	Labels     string   `json:"Labels"`
-	Name       string   `json:"Name"`
-	Names      string   `json:"Names"`
-	Networks   string   `json:"Networks"`
-	Ports      string   `json:"Ports"`
-	Project    string   `json:"Project"`
-	Publishers []string `json:"Publishers"`
-	RunningFor string   `json:"RunningFor"`
-	Service    string   `json:"Service"`
-	Size       string   `json:"Size"`
-	State      string   `json:"State"`
-	Status     string   `json:"Status"`
+	Command    string           `json:"Command"`
+	CreatedAt  string           `json:"CreatedAt"`
+	ExitCode   int              `json:"ExitCode"`
+	Health     string           `json:"Health"`
+	ID         string           `json:"ID"`
+	Image      string           `json:"Image"`
+	Labels     string           `json:"Labels"`
+	Name       string           `json:"Name"`
+	Names      string           `json:"Names"`
+	Networks   string           `json:"Networks"`
+	Ports      string           `json:"Ports"`
+	Project    string           `json:"Project"`
+	Publishers []DC2PSPublisher `json:"Publishers"`
+	RunningFor string           `json:"RunningFor"`
+	Service    string           `json:"Service"`
+	Size       string           `json:"Size"`
+	State      string           `json:"State"`
+	Status     string           `json:"Status"`
 }
 
 // Parses the output of the `docker-compose ps --format json --all` command, into json.
+// The output is either one JSON object per line (docker-compose >=2.21) or a JSON array (jsonArray, docker-compose <2.21).
 //
 // Example of running the ps command, when using docker-compose: 2.24.5:
 // $ docker-compose -f ./test/test-files/itest-dc.yaml -f ./test/test-files/itest-dc.yaml.dojo -p testdojorunid ps --format json --all
@@ -748,27 +1343,42 @@ type DC2PSOutput struct {
 //
 // when using docker-compose: 2.31m the field with Publishers is set to []:
 // {"Command":"\"/bin/sh -c 'while t…\"","CreatedAt":"2024-12-20 15:58:00 +1300 NZDT","ExitCode":143,"Health":"","ID":"f543828473a7","Image":"alpine:3.19","Labels":"com.docker.compose.image=sha256:7a85bf5dc56c949be827f84f9185161265c58f589bb8b2a6b6bb6d3076c1be21,desktop.docker.io/binds/0/Source=/tmp/dojo-environment-multiline-dojo-test-files-2024-12-2015-58-00-6660523964295751458,desktop.docker.io/binds/0/SourceKind=hostFile,desktop.docker.io/binds/0/Target=/etc/dojo.d/variables/00-multiline-vars.sh,desktop.docker.io/binds/1/Target=/etc/dojo.d/variables/01-bash-functions.sh,com.docker.compose.oneoff=False,com.docker.compose.project.config_files=/Users/ava.czechowska/code/dojo/test/test-files/itest-dc.yaml,/Users/ava.czechowska/code/dojo/test/test-files/itest-dc.yaml.dojo,com.docker.compose.service=abc,desktop.docker.io/binds/1/SourceKind=hostFile,com.docker.compose.container-number=1,com.docker.compose.project.working_dir=/Users/ava.czechowska/code/dojo/test/test-files,com.docker.compose.config-hash=4439e404114c9d0f183d756084f3e0e80c56b731e1f1e14e6db1844134294969,com.docker.compose.depends_on=,com.docker.compose.project=dojo-test-files-2024-12-2015-58-00-6660523964295751458,com.docker.compose.version=2.31.0,desktop.docker.io/binds/1/Source=/tmp/dojo-environment-bash-functions-dojo-test-files-2024-12-2015-58-00-6660523964295751458","LocalVolumes":"0","Mounts":"/host_mnt/priv…,/host_mnt/priv…","Name":"dojo-test-files-2024-12-2015-58-00-6660523964295751458-abc-1","Names":"dojo-test-files-2024-12-2015-58-00-6660523964295751458-abc-1","Networks":"dojo-test-files-2024-12-2015-58-00-6660523964295751458_default","Ports":"","Project":"dojo-test-files-2024-12-2015-58-00-6660523964295751458","Publishers":[],"RunningFor":"36 seconds ago","Service":"abc","Size":"0B","State":"exited","Status":"Exited (143) 10 seconds ago"}
-func ParseDCPSOutPut_DCVersion2(output string) ([]DC2PSOutput, string) {
+func ParseDCPSOutPut_DCVersion2(output string, jsonArray bool) ([]DC2PSOutput, string) {
 	var output_as_jsons []DC2PSOutput
 
-	lines := strings.Split(output, "\n")
-	for _, line := range lines {
-		if line == "" {
-			continue
+	if jsonArray {
+		// docker-compose <2.21 prints all the containers as 1 JSON array, e.g.
+		// [{"ID":"2d5c5b0343d0","Name":"testdojorunid-abc-1",...},{"ID":"af4817fede41",...}]
+		trimmedOutput := strings.TrimSpace(output)
+		if trimmedOutput != "" {
+			err := json.Unmarshal([]byte(trimmedOutput), &output_as_jsons)
+			if err != nil {
+				return []DC2PSOutput{},
+					fmt.Errorf("Error when decoding the JSON array response from docker-compose ps command: %s; output %s", err, trimmedOutput).Error()
+			}
 		}
-		var one_output_as_json DC2PSOutput
-		err := json.Unmarshal([]byte(line), &one_output_as_json)
-		if err != nil {
-			return []DC2PSOutput{},
-				fmt.Errorf("Error when decoding the JSON response from docker-compose ps command: %s; line %s", err, line).Error()
+	} else {
+		lines := strings.Split(output, "\n")
+		for _, line := range lines {
+			if line == "" {
+				continue
+			}
+			var one_output_as_json DC2PSOutput
+			err := json.Unmarshal([]byte(line), &one_output_as_json)
+			if err != nil {
+				return []DC2PSOutput{},
+					fmt.Errorf("Error when decoding the JSON response from docker-compose ps command: %s; line %s", err, line).Error()
+			}
+			output_as_jsons = append(output_as_jsons, one_output_as_json)
 		}
+	}
+	for _, one_output_as_json := range output_as_jsons {
 		if one_output_as_json.State == "" {
 			// This means that something went wrong, e.g. docker-compose ps output is now different
 			// than expected.
 			return []DC2PSOutput{},
 				fmt.Errorf("Error when decoding the JSON response from docker-compose ps command: container State was an empty string. More details: %#v", one_output_as_json).Error()
 		}
-		output_as_jsons = append(output_as_jsons, one_output_as_json)
 	}
 
 	return output_as_jsons, ""
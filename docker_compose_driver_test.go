@@ -1,37 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
-func Test_parseDCFileVersion(t *testing.T) {
-	type mytests struct {
-		content        string
-		expectedOutput float64
-		expectedErrMsg string
-	}
-	mytestsObj := []mytests{
-		mytests{"version: '4.55'", 4.55, ""},
-		mytests{"version: \"4.55\"", 4.55, ""},
-		mytests{"", -1, ""},
-	}
-	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
-	for _, v := range mytestsObj {
-		actualVersion, err := dc.parseDCFileVersion(v.content)
-		assert.Equal(t, v.expectedOutput, actualVersion, v.content)
-		if v.expectedErrMsg == "" {
-			assert.Nil(t, err)
-		} else {
-			assert.Equal(t, v.expectedErrMsg, err.Error())
-		}
-	}
-}
-
 func Test_verifyDCFile(t *testing.T) {
 	type mytests struct {
 		content        string
@@ -51,23 +30,51 @@ services:
     links:
       - hdind:hdind
 `
-	contentsInvalidVersion := `version: '3'
+	contentsInvalidVersion := `version: '1'
 services:
   default:
     container_name: default
     links:
       - hdind:hdind
+`
+	contentsV3 := `version: '3.8'
+services:
+  default:
+    container_name: default
+`
+	contentsNoVersion := `services:
+  db:
+    image: postgres:11.2-alpine
+  default:
+    links:
+      - db:db
+`
+	contentsDefaultNotMapping := `services:
+  db:
+    image: postgres:11.2-alpine
+  default: alpine:3.19
+`
+	contentsDefaultInOtherService := `services:
+  db:
+    image: postgres:11.2-alpine
+    environment:
+      default: 1
 `
 	mytestsObj := []mytests{
 		mytests{contentsOK, ""},
-		mytests{contentsNoDefault, "does not contain: default:"},
-		mytests{contentsInvalidVersion, "should contain version number >=2 and <3, current version: 3"},
+		mytests{contentsV3, ""},
+		mytests{contentsNoVersion, ""},
+		mytests{contentsNoDefault, "filePath.yml:2: services do not contain: default. Please add a default service"},
+		mytests{contentsInvalidVersion, "filePath.yml:1: should contain version number >=2, current version: 1"},
+		mytests{contentsDefaultNotMapping, "filePath.yml:4: service default should be a mapping, got: scalar: alpine:3.19"},
+		mytests{contentsDefaultInOtherService, "filePath.yml:1: services do not contain: default"},
+		mytests{"version: '2'\n", "filePath.yml does not contain: services. Please add a default service"},
 	}
 	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
 	for _, v := range mytestsObj {
 		// do not test version, it is tested in other test
-		_, err := dc.verifyDCFile(v.content, "filePath.yml")
+		_, err := dc.verifyDCFile(v.content, "filePath.yml", "default")
 		if v.expectedErrMsg == "" {
 			assert.Equal(t, err, nil)
 		} else {
@@ -77,7 +84,17 @@ services:
 	}
 }
 
-func Test_generateDCFileContentsWithEnv(t *testing.T) {
+func Test_generateInitialDCFile(t *testing.T) {
+	logger := NewLogger("debug")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	overrideFile := dc.generateInitialDCFile(config, "3.10")
+	// the version is copied as written, 3.10 must not become 3.1
+	assert.Equal(t, "3.10", overrideFile.Version)
+	assert.Equal(t, map[string]*DCOverrideService{"default": &DCOverrideService{Image: "img:1.2.3"}}, overrideFile.Services)
+}
+
+func Test_addEnvToDCOverrideFile(t *testing.T) {
 	type mytests struct {
 		displaySet bool
 	}
@@ -87,7 +104,7 @@ func Test_generateDCFileContentsWithEnv(t *testing.T) {
 	}
 	logger := NewLogger("debug")
 	expectedServices := []string{"abc", "def", "default"}
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
 	for _, v := range mytestsObj {
 		config := getTestConfig()
 		setTestEnv()
@@ -96,55 +113,50 @@ func Test_generateDCFileContentsWithEnv(t *testing.T) {
 		} else {
 			setTestEnv()
 		}
-		contents := dc.generateDCFileContentsWithEnv(expectedServices, config,
+		overrideFile := dc.generateInitialDCFile(config, "")
+		dc.addEnvToDCOverrideFile(overrideFile, expectedServices, config,
 			"/tmp/env-file.txt", "/tmp/env-file-multiline.txt", "/tmp/env-file-bash-functions.txt")
 
+		expectedDefaultVolumes := []string{
+			"/tmp/myidentity:/dojo/identity:ro",
+			"/tmp/bla:/dojo/work",
+			"/tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh",
+			"/tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh",
+		}
 		if v.displaySet {
-			assert.Equal(t, `    volumes:
-      - /tmp/myidentity:/dojo/identity:ro
-      - /tmp/bla:/dojo/work
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-      - /tmp/.X11-unix:/tmp/.X11-unix
-    env_file:
-      - /tmp/env-file.txt
-  abc:
-    env_file:
-      - /tmp/env-file.txt
-    volumes:
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-  def:
-    env_file:
-      - /tmp/env-file.txt
-    volumes:
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-`, contents)
-		} else {
-			assert.Equal(t, `    volumes:
-      - /tmp/myidentity:/dojo/identity:ro
-      - /tmp/bla:/dojo/work
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-    env_file:
-      - /tmp/env-file.txt
-  abc:
-    env_file:
-      - /tmp/env-file.txt
-    volumes:
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-  def:
-    env_file:
-      - /tmp/env-file.txt
-    volumes:
-      - /tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh
-      - /tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh
-`, contents)
+			expectedDefaultVolumes = append(expectedDefaultVolumes, "/tmp/.X11-unix:/tmp/.X11-unix")
+		}
+		expectedOtherService := &DCOverrideService{
+			EnvFile: []string{"/tmp/env-file.txt"},
+			Volumes: []string{
+				"/tmp/env-file-multiline.txt:/etc/dojo.d/variables/00-multiline-vars.sh",
+				"/tmp/env-file-bash-functions.txt:/etc/dojo.d/variables/01-bash-functions.sh",
+			},
 		}
+		assert.Equal(t, map[string]*DCOverrideService{
+			"default": &DCOverrideService{
+				Image:   "img:1.2.3",
+				EnvFile: []string{"/tmp/env-file.txt"},
+				Volumes: expectedDefaultVolumes,
+			},
+			"abc": expectedOtherService,
+			"def": expectedOtherService,
+		}, overrideFile.Services)
 	}
+	setTestEnv()
+}
 
+func Test_addEnvToDCOverrideFile_NotPreserveEnvironment(t *testing.T) {
+	setTestEnv()
+	logger := NewLogger("debug")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.PreserveEnvironmentToAllContainers = "false"
+	overrideFile := dc.generateInitialDCFile(config, "")
+	dc.addEnvToDCOverrideFile(overrideFile, []string{"abc", "default"}, config,
+		"/tmp/env-file.txt", "/tmp/env-file-multiline.txt", "/tmp/env-file-bash-functions.txt")
+	assert.Equal(t, 1, len(overrideFile.Services))
+	assert.Equal(t, []string{"/tmp/env-file.txt"}, overrideFile.Services["default"].EnvFile)
 }
 
 func Test_ConstructDockerComposeCommandRun_Interactive(t *testing.T) {
@@ -182,51 +194,73 @@ func Test_ConstructDockerComposeCommandRun_Interactive(t *testing.T) {
 		} else {
 			ss = NewMockedShellServiceNotInteractive(logger)
 		}
-		dc := NewDockerComposeDriver(ss, NewMockedFileService(logger), logger, "")
+		dc := NewDockerComposeDriver(ss, NewMockedFileService(logger), logger, "docker-compose", "")
 		cmd := dc.ConstructDockerComposeCommandRun(config, "1234")
 		assert.Equal(t, v.expOutput, cmd, fmt.Sprintf("shellInteractive: %v, userConfig: %v", v.shellInteractive, v.userInteractiveConfig))
 	}
 }
-func Test_ConstructDockerComposeCommandRun_NotInteractive_NoCommand(t *testing.T) {
+func Test_ConstructDockerComposeCommandRun_NoCommand(t *testing.T) {
+	type mytestStruct struct {
+		shellInteractive      bool
+		userInteractiveConfig string
+		expOutput             string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{shellInteractive: true, userInteractiveConfig: "",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm --some-opt default"},
+		mytestStruct{shellInteractive: false, userInteractiveConfig: "",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt default < /dev/null"},
+		mytestStruct{shellInteractive: false, userInteractiveConfig: "false",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt default < /dev/null"},
+		// e.g. the input is piped: echo x | dojo -i=true
+		mytestStruct{shellInteractive: false, userInteractiveConfig: "true",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm --some-opt default"},
+		mytestStruct{shellInteractive: true, userInteractiveConfig: "false",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt default"},
+	}
 	setTestEnv()
-	config := getTestConfig()
-	config.RunCommand = ""
-	config.DockerComposeOptions = "--some-opt"
-	config.DockerComposeFile = "/tmp/dummy.yml"
-
-	defer func() {
-		if r := recover(); r != nil {
-			assert.Equal(t, "Using driver: docker-compose with empty RunCommand when shell is not interactive is unsupported. It would hang the terminal", r.(string))
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		config := getTestConfig()
+		config.Interactive = v.userInteractiveConfig
+		config.RunCommand = ""
+		config.DockerComposeOptions = "--some-opt"
+		config.DockerComposeFile = "/tmp/dummy.yml"
+		var ss ShellServiceInterface
+		if v.shellInteractive {
+			ss = NewMockedShellServiceInteractive(logger)
 		} else {
-			t.Fatalf("Expected panic")
+			ss = NewMockedShellServiceNotInteractive(logger)
 		}
-	}()
-	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
-	dc.ConstructDockerComposeCommandRun(config, "1234")
-	t.Fatalf("Expected panic")
-
+		dc := NewDockerComposeDriver(ss, NewMockedFileService(logger), logger, "docker-compose", "")
+		cmd := dc.ConstructDockerComposeCommandRun(config, "1234")
+		assert.Equal(t, v.expOutput, cmd, fmt.Sprintf("shellInteractive: %v, userConfig: %v", v.shellInteractive, v.userInteractiveConfig))
+	}
 }
 
 func Test_ConstructDockerComposeCommandRun(t *testing.T) {
 	type mytestStruct struct {
 		userCommandConfig string
+		service           string
 		expOutput         string
 	}
 	mytests := []mytestStruct{
-		mytestStruct{userCommandConfig: "bash",
+		mytestStruct{userCommandConfig: "bash", service: "default",
 			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt default bash"},
-		mytestStruct{userCommandConfig: "bash -c \"echo hello\"",
+		mytestStruct{userCommandConfig: "bash -c \"echo hello\"", service: "default",
 			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt default bash -c \"echo hello\""},
+		mytestStruct{userCommandConfig: "bash", service: "app",
+			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 run --rm -T --some-opt app bash"},
 	}
 	setTestEnv()
 	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
 	for _, v := range mytests {
 		config := getTestConfig()
 		config.RunCommand = v.userCommandConfig
 		config.DockerComposeOptions = "--some-opt"
 		config.DockerComposeFile = "/tmp/dummy.yml"
+		config.DockerComposeService = v.service
 		cmd := dc.ConstructDockerComposeCommandRun(config, "1234")
 		assert.Equal(t, v.expOutput, cmd, fmt.Sprintf("userCommandConfig: %v", v.userCommandConfig))
 	}
@@ -242,7 +276,7 @@ func Test_ConstructDockerComposeCommandStop(t *testing.T) {
 			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 stop"},
 	}
 	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
 	for _, v := range mytests {
 		config := getTestConfig()
 		config.RunCommand = v.userCommandConfig
@@ -263,7 +297,7 @@ func Test_ConstructDockerComposeCommandDown(t *testing.T) {
 			expOutput: "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 down"},
 	}
 	logger := NewLogger("debug")
-	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
 	for _, v := range mytests {
 		config := getTestConfig()
 		config.RunCommand = v.userCommandConfig
@@ -305,13 +339,14 @@ func TestDockerComposeDriver_HandleRun_Unit(t *testing.T) {
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] =
-		[]string{"container1 name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] =
-		[]string{"container1 name2 running 0", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] =
+		[]string{"container1 /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] =
+		[]string{"container1 /edudocker_def_1 running 0 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	config := getTestConfig()
 	config.Driver = "docker-compose"
@@ -326,7 +361,12 @@ three`)
 	assert.Equal(t, 4, len(fs.FilesWrittenTo))
 	assert.Equal(t, "ABC=123\n", fs.FilesWrittenTo["/tmp/dojo-environment-1234"])
 	assert.Equal(t, "export MULTI_LINE=$(echo b25lCnR3bwp0aHJlZQ== | base64 -d)\n", fs.FilesWrittenTo["/tmp/dojo-environment-multiline-1234"])
-	assert.Contains(t, fs.FilesWrittenTo["docker-compose.yml.dojo"], "version: '2.2'")
+	var overrideFile DCOverrideFile
+	err := yaml.Unmarshal([]byte(fs.FilesWrittenTo["docker-compose.yml.dojo"]), &overrideFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "2.2", overrideFile.Version)
+	assert.Equal(t, "img:1.2.3", overrideFile.Services["default"].Image)
+	assert.Equal(t, []string{"/tmp/dojo-environment-1234"}, overrideFile.Services["default"].EnvFile)
 
 	exitstatus = driver.CleanAfterRun(config, runID)
 	assert.Equal(t, 0, exitstatus)
@@ -338,6 +378,76 @@ three`)
 	assert.False(t, fileExists("/tmp/dojo-environment-1234"))
 }
 
+func TestDockerComposeDriver_getImagesToPull(t *testing.T) {
+	logger := NewLogger("debug")
+	driver := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	contents := `services:
+  default:
+    image: default-image:1
+  db:
+    image: postgres:16
+  cache:
+    image: postgres:16
+  app:
+    build: .
+    image: myapp:dev
+  stub:
+    image: ${STUB_IMAGE}
+  other:
+    init: true
+`
+	dcFile, err := parseDCFile(contents, "docker-compose.yml")
+	assert.Nil(t, err)
+	config := getTestConfig()
+	images := driver.getImagesToPull(config, dcFile)
+	assert.Equal(t, []string{"img:1.2.3", "postgres:16"}, images)
+
+	// the image is built by dojo
+	config.DockerImage = "dojo-build-image:0123456789ab"
+	config.DockerBuildContext = "/tmp/image"
+	images = driver.getImagesToPull(config, dcFile)
+	assert.Equal(t, []string{"postgres:16"}, images)
+
+	// the image is set in the docker-compose file
+	config.DockerImage = ""
+	config.DockerBuildContext = ""
+	images = driver.getImagesToPull(config, dcFile)
+	assert.Equal(t, []string{"default-image:1", "postgres:16"}, images)
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_NoImage(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	shellS := NewMockedShellServiceNotInteractive(logger)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.DockerImage = ""
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 1, exitstatus)
+	assert.Equal(t, 0, len(shellS.CommandsRun))
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_PullPolicyNever(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
+		[]string{"default", "", "0"}
+	commandsReactions["docker image inspect --format '{{.Id}}' img:1.2.3"] = []string{"", "Error: No such image: img:1.2.3", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.PullPolicy = "never"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, imageFailureExitStatus, exitstatus)
+	assert.False(t, elem_in_array(shellS.CommandsRun, "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 run"))
+}
+
 func TestDockerComposeDriver_HandleRun_Unit_PrintLogsFailure(t *testing.T) {
 	logger := NewLogger("debug")
 	fs := NewMockedFileService(logger)
@@ -347,13 +457,14 @@ func TestDockerComposeDriver_HandleRun_Unit_PrintLogsFailure(t *testing.T) {
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] =
-		[]string{"container1 name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] =
-		[]string{"container1 name1 running 0", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] =
+		[]string{"container1 /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] =
+		[]string{"container1 /edudocker_def_1 running 0 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	config := getTestConfig()
 	config.Driver = "docker-compose"
@@ -369,6 +480,79 @@ func TestDockerComposeDriver_HandleRun_Unit_PrintLogsFailure(t *testing.T) {
 	assert.Equal(t, 0, exitstatus)
 }
 
+func TestDockerComposeDriver_HandleRun_Unit_NoCommand(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	fakePSOutput := getFakeDockerComposePSStdout()
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
+		[]string{fakePSOutput, "", "0"}
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
+		[]string{"container1", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 0 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
+
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = ""
+	runID := "1234"
+	exitstatus := driver.HandleRun(config, runID, NewMockedEnvService())
+	assert.Equal(t, 0, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun,
+		"docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 run --rm -T default < /dev/null"))
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 stop"))
+
+	exitstatus = driver.CleanAfterRun(config, runID)
+	assert.Equal(t, 0, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 down"))
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_NotRemoveContainers(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	fakePSOutput := getFakeDockerComposePSStdout()
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
+		[]string{fakePSOutput, "", "0"}
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
+		[]string{"container1", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 0 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
+
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.RemoveContainers = "false"
+	runID := "1234"
+	exitstatus := driver.HandleRun(config, runID, NewMockedEnvService())
+	assert.Equal(t, 0, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun,
+		"docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 run -T default bla"))
+	assert.Equal(t, "1234", fs.FilesWrittenTo["/tmp//dojorc.txt"])
+	assert.Equal(t, "DOJO_RUN_ID=1234", fs.FilesWrittenTo["/tmp//dojorc"])
+
+	removalsCount := len(fs.FilesRemovals)
+	exitstatus = driver.CleanAfterRun(config, runID)
+	assert.Equal(t, 0, exitstatus)
+	assert.False(t, elem_in_array(shellS.CommandsRun, " down"))
+	assert.Equal(t, removalsCount, len(fs.FilesRemovals))
+
+	// the later cleanup, as done with: dojo --action=clean
+	config.RemoveContainers = "true"
+	exitstatus = driver.CleanAfterRun(config, runID)
+	assert.Equal(t, 0, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 down"))
+	assert.Contains(t, fs.FilesRemovals, "docker-compose.yml.dojo")
+}
+
 func TestDockerComposeDriver_HandleRun_Unit_PrintLogsAlways_TargetConsole(t *testing.T) {
 	logger := NewLogger("debug")
 	fs := NewMockedFileService(logger)
@@ -378,11 +562,12 @@ func TestDockerComposeDriver_HandleRun_Unit_PrintLogsAlways_TargetConsole(t *tes
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] = []string{"some_hash name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] = []string{"some_hash name2 running 127", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 127 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	config := getTestConfig()
 	config.Driver = "docker-compose"
@@ -406,13 +591,14 @@ func TestDockerComposeDriver_HandleRun_Unit_PrintLogsAlways_TargetFile(t *testin
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] = []string{"some_hash name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] = []string{"some_hash name2 running 127", "", "0"}
-	commandsReactions["docker logs name1"] = []string{"some-output", "", "0"}
-	commandsReactions["docker logs name2"] = []string{"some-output2", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 127 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
+	commandsReactions["docker logs edudocker_abc_1"] = []string{"some-output", "", "0"}
+	commandsReactions["docker logs edudocker_def_1"] = []string{"some-output2", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	config := getTestConfig()
 	config.Driver = "docker-compose"
@@ -424,8 +610,8 @@ func TestDockerComposeDriver_HandleRun_Unit_PrintLogsAlways_TargetFile(t *testin
 	exitstatus := driver.HandleRun(config, runID, envService)
 	assert.Equal(t, 0, exitstatus)
 	assert.True(t, elem_in_array(shellS.CommandsRun, "docker logs"))
-	assert.Contains(t, fs.FilesWrittenTo["dojo-logs-name1-1234.txt"], "stderr:\nstdout:\nsome-output")
-	assert.Contains(t, fs.FilesWrittenTo["dojo-logs-name2-1234.txt"], "stderr:\nstdout:\nsome-output2")
+	assert.Contains(t, fs.FilesWrittenTo["dojo-logs-edudocker_abc_1-1234.txt"], "stderr:\nstdout:\nsome-output")
+	assert.Contains(t, fs.FilesWrittenTo["dojo-logs-edudocker_def_1-1234.txt"], "stderr:\nstdout:\nsome-output2")
 
 	exitstatus = driver.CleanAfterRun(config, runID)
 	assert.Equal(t, 0, exitstatus)
@@ -441,11 +627,12 @@ func TestDockerComposeDriver_HandleRun_RealFileService(t *testing.T) {
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f test-docker-compose.yml -f test-docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] = []string{"some_hash name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] = []string{"some_hash name2 running 127", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 127 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	createDCFile(t, dcFilePath, fs)
 	config := getTestConfig()
@@ -463,6 +650,43 @@ func TestDockerComposeDriver_HandleRun_RealFileService(t *testing.T) {
 	fs.RemoveFile("/tmp/dojo-environment-1234", true)
 }
 
+// e.g. a signal is caught while waiting for the other containers to be healthy
+func TestDockerComposeDriver_HandleSignal_NoDefaultContainer(t *testing.T) {
+	type mytestStruct struct {
+		multipleSignal bool
+		expCmd         string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{multipleSignal: false, expCmd: "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 stop"},
+		mytestStruct{multipleSignal: true, expCmd: "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 kill"},
+	}
+	for _, v := range mytests {
+		logger := NewLogger("debug")
+		commandsReactions := make(map[string]interface{}, 0)
+		commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] = []string{
+			`Name                        Command               State   Ports
+------------------------------------------------------------------------
+edudocker_abc_1           /bin/sh -c while true; do  ...   Up
+`, "", "0"}
+		commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"id1 /edudocker_abc_1 running 0 1234 abc", "", "0"}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+		config := getTestConfig()
+		config.Driver = "docker-compose"
+		var exitstatus int
+		if v.multipleSignal {
+			exitstatus = driver.HandleMultipleSignal(config, "1234")
+		} else {
+			exitstatus = driver.HandleSignal(config, "1234")
+		}
+		assert.Equal(t, 0, exitstatus, v.multipleSignal)
+		assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: "+v.expCmd), v.multipleSignal)
+		assert.False(t, elem_in_array(shellS.CommandsRun, "docker stop "), v.multipleSignal)
+		assert.False(t, elem_in_array(shellS.CommandsRun, "docker kill "), v.multipleSignal)
+	}
+}
+
 func getFakeDockerComposePSStdout() string {
 	return `Name                        Command               State   Ports
 ------------------------------------------------------------------------
@@ -481,11 +705,12 @@ func TestDockerComposeDriver_HandleRun_RealEnvService(t *testing.T) {
 		[]string{fakePSOutput, "", "0"}
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{"container1", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] = []string{"some_hash name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] = []string{"some_hash name2 running 127", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"some_hash /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"some_hash /edudocker_def_1 running 127 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = []string{"some_hash3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	config := getTestConfig()
 	config.Driver = "docker-compose"
@@ -498,22 +723,60 @@ func TestDockerComposeDriver_HandleRun_RealEnvService(t *testing.T) {
 	assert.Equal(t, 0, exitstatus)
 }
 
-func Test_getDCContainersNames(t *testing.T) {
+func Test_getDCContainers(t *testing.T) {
 	logger := NewLogger("debug")
 	fs := NewMockedFileService(logger)
 	fakePSOutput := getFakeDockerComposePSStdout()
 	commandsReactions := make(map[string]interface{}, 0)
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
 		[]string{fakePSOutput, "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"id1 /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"", "Error: No such object: edudocker_def_1", "1"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] =
+		[]string{"id3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
+
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	containers := driver.getDCContainers(getTestConfig(), "1234")
+	// the container edudocker_def_1 was already removed
+	assert.Equal(t, 2, len(containers))
+	assert.Equal(t, &ContainerInfo{ID: "id1", Name: "edudocker_abc_1", Status: "running", ExitCode: "0", Exists: true,
+		Project: "edudocker", Service: "abc"}, containers[0])
+	assert.Equal(t, "edudocker_default_run_1", containers[1].Name)
+	assert.Equal(t, "default", containers[1].Service)
+}
+
+func Test_getDCContainers_DCVersion2(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	// the project name contains the name of the default service
+	fakePSOutput := `{"ExitCode":0,"ID":"2d5c5b0343d0","Name":"my-default-1-abc-1","Project":"my-default-1","Service":"abc","State":"running"}
+{"ExitCode":3,"ID":"af4817fede41","Name":"my-default-1-default-run-742bcbb0e4bc","Project":"my-default-1","Service":"default","State":"exited"}
+`
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p my-default-1 ps --format json --all"] =
+		[]string{fakePSOutput, "", "0"}
 
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "2.24.5")
+
+	containers := driver.getDCContainers(getTestConfig(), "my-default-1")
+	assert.Equal(t, 2, len(containers))
+	assert.Equal(t, &ContainerInfo{ID: "2d5c5b0343d0", Name: "my-default-1-abc-1", Status: "running", ExitCode: "0", Exists: true,
+		Project: "my-default-1", Service: "abc"}, containers[0])
+	assert.Equal(t, &ContainerInfo{ID: "af4817fede41", Name: "my-default-1-default-run-742bcbb0e4bc", Status: "exited", ExitCode: "3",
+		Exists: true, Project: "my-default-1", Service: "default"}, containers[1])
+	// all the needed information is in the ps output, no need to inspect the containers
+	assert.False(t, elem_in_array(shellS.CommandsRun, "docker inspect"))
+}
 
-	names := driver.getDCContainersNames(getTestConfig(), "1234")
-	assert.Equal(t, 3, len(names))
-	assert.Equal(t, "edudocker_abc_1", names[0])
-	assert.Equal(t, "edudocker_def_1", names[1])
-	assert.Equal(t, "edudocker_default_run_1", names[2])
+func getFakeDCContainers() []*ContainerInfo {
+	return []*ContainerInfo{
+		&ContainerInfo{Name: "default-abc-1", Project: "default", Service: "abc"},
+		&ContainerInfo{Name: "default-def-1", Project: "default", Service: "def"},
+		&ContainerInfo{Name: "default-default-run-1", Project: "default", Service: "default"},
+	}
 }
 
 func Test_getDefaultContainerID(t *testing.T) {
@@ -521,32 +784,57 @@ func Test_getDefaultContainerID(t *testing.T) {
 	fs := NewMockedFileService(logger)
 
 	commandsReactions := make(map[string]interface{}, 0)
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_default_run_1"] =
-		[]string{"dummy-id name1 running 000", "", "0"}
+	commandsReactions[dockerInspectCmd("default-default-run-1")] =
+		[]string{"dummy-id name1 running 000 default default", "", "0"}
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
-	names := []string{"edudocker_abc_1", "edudocker_def_1", "edudocker_default_run_1"}
-	id := driver.getDefaultContainerID(names)
+	id := driver.getDefaultContainerID(getFakeDCContainers(), "default")
 	assert.Equal(t, "dummy-id", id)
 }
 
+func Test_getDefaultContainerID_CustomService(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions[dockerInspectCmd("default-def-1")] =
+		[]string{"def-id name1 running 000 default def", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	id := driver.getDefaultContainerID(getFakeDCContainers(), "def")
+	assert.Equal(t, "def-id", id)
+}
+
+func Test_getNonDefaultContainersInfos(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions[dockerInspectCmd("default-abc-1")] = []string{"id1 /default-abc-1 exited 1 default abc", "", "0"}
+	commandsReactions[dockerInspectCmd("default-def-1")] = []string{"id2 /default-def-1 running 0 default def", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	infos := driver.getNonDefaultContainersInfos(getFakeDCContainers(), "default")
+	assert.Equal(t, 2, len(infos))
+	assert.Equal(t, "abc", infos[0].Service)
+	assert.Equal(t, "exited", infos[0].Status)
+	assert.Equal(t, "def", infos[1].Service)
+	assert.False(t, elem_in_array(shellS.CommandsRun, "default-default-run-1"))
+}
+
 func Test_getDefaultContainerID_notCreated(t *testing.T) {
 	logger := NewLogger("debug")
 	fs := NewMockedFileService(logger)
 
 	shellS := NewMockedShellServiceNotInteractive(logger)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
-
-	defer func() {
-		r := recover()
-		assert.Contains(t, r.(error).Error(), "default container not found. Were the containers created?")
-	}()
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
-	names := []string{}
-	id := driver.getDefaultContainerID(names)
+	containers := []*ContainerInfo{&ContainerInfo{Name: "edudocker_abc_1", Service: "abc"}}
+	id := driver.getDefaultContainerID(containers, "default")
 	assert.Equal(t, "", id)
-	t.Fatal("Expected panic")
 }
 
 func Test_checkContainerIsRunning(t *testing.T) {
@@ -554,9 +842,9 @@ func Test_checkContainerIsRunning(t *testing.T) {
 	fs := NewMockedFileService(logger)
 
 	commandsReactions := make(map[string]interface{}, 0)
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' id1"] = []string{"id1 name1 running 0", "", "0"}
+	commandsReactions[dockerInspectCmd("id1")] = []string{"id1 name1 running 0", "", "0"}
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 	running := driver.checkContainerIsRunning("id1")
 	assert.Equal(t, true, running)
@@ -569,12 +857,12 @@ func Test_waitForContainersToBeRunning(t *testing.T) {
 	commandsReactions := make(map[string]interface{}, 0)
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
 		[]string{fakePSOutput, "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_abc_1"] =
-		[]string{"id1 name1 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_def_1"] =
-		[]string{"id2 name2 running 0", "", "0"}
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' edudocker_default_run_1"] =
-		[]string{"id3 name3 running 0", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] =
+		[]string{"id1 /edudocker_abc_1 running 0 edudocker abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] =
+		[]string{"id2 /edudocker_def_1 running 0 edudocker def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] =
+		[]string{"id3 /edudocker_default_run_1 running 0 edudocker default", "", "0"}
 	fakeContainers := `abc
 cde
 efd
@@ -582,10 +870,549 @@ efd
 	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 		[]string{fakeContainers, "", "0"}
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+
+	containers := driver.waitForContainersToBeRunning(getTestConfig(), "1234", 3, &ContainerEvents{})
+	assert.Equal(t, []string{"edudocker_abc_1", "edudocker_def_1", "edudocker_default_run_1"}, getContainersNames(containers))
+	assert.Equal(t, "default", containers[2].Service)
+}
+
+// Returns a docker event as printed by: docker events --format '{{json .}}'
+func getFakeDockerEvent(action string, name string, service string) string {
+	return fmt.Sprintf(`{"status":"%s","id":"hash_%s","from":"alpine:3.19","Type":"container","Action":"%s",`+
+		`"Actor":{"ID":"hash_%s","Attributes":{"com.docker.compose.project":"1234","com.docker.compose.service":"%s",`+
+		`"exitCode":"1","image":"alpine:3.19","name":"%s"}},"scope":"local","time":1707000000,"timeNano":1707000000000000000}`,
+		action, name, action, name, service, name)
+}
+
+func Test_ConstructDockerEventsCommand(t *testing.T) {
+	logger := NewLogger("debug")
+	driver := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "2.24.5")
+	assert.Equal(t, "docker events --filter type=container --filter label=com.docker.compose.project=1234"+
+		" --filter event=start --filter event=die --filter event=health_status --format '{{json .}}' --since 1707000000",
+		driver.ConstructDockerEventsCommand("1234", 1707000000))
+}
+
+func Test_parseDockerEvent(t *testing.T) {
+	event, err := parseDockerEvent(getFakeDockerEvent("die", "edudocker_abc_1", "abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, "container", event.Type)
+	assert.Equal(t, "die", event.Action)
+	assert.Equal(t, "hash_edudocker_abc_1", event.Actor.ID)
+	assert.Equal(t, "edudocker_abc_1", event.Actor.Attributes["name"])
+	assert.Equal(t, "1", event.Actor.Attributes["exitCode"])
+
+	_, err = parseDockerEvent("not json")
+	assert.Contains(t, err.Error(), "Error when decoding a docker event: not json")
+}
+
+func Test_waitForContainerEvent(t *testing.T) {
+	logger := NewLogger("debug")
+	driver := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	lines := make(chan string, 4)
+	lines <- getFakeDockerEvent("start", "edudocker_abc_1", "abc")
+	lines <- "not json"
+	lines <- strings.Replace(getFakeDockerEvent("health_status", "edudocker_abc_1", "abc"), `"Action":"health_status"`, `"Action":"health_status: healthy"`, 1)
+	lines <- getFakeDockerEvent("die", "edudocker_abc_1", "abc")
+	close(lines)
+	events := &ContainerEvents{Lines: lines}
+
+	event := driver.waitForContainerEvent(events, nil, "health_status", "die")
+	assert.Equal(t, "health_status: healthy", event.Action)
+	event = driver.waitForContainerEvent(events, nil, "health_status", "die")
+	assert.Equal(t, "die", event.Action)
+	// the stream ended
+	event = driver.waitForContainerEvent(events, nil, "die")
+	assert.Nil(t, event)
+	assert.Nil(t, events.Lines)
+}
+
+func getWatchContainersCommandsReactions() map[string]interface{} {
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
+		[]string{getFakeDockerComposePSStdout(), "", "0"}
+	// inspected by: getDCContainers, checkAllContainersRunning and then when watching
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = [][]string{
+		[]string{"id1 /edudocker_abc_1 running 0 1234 abc", "", "0"},
+		[]string{"id1 /edudocker_abc_1 running 0 1234 abc", "", "0"},
+		[]string{"id1 /edudocker_abc_1 exited 1 1234 abc", "", "0"},
+	}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"id2 /edudocker_def_1 running 0 1234 def", "", "0"}
+	// inspected by: getDCContainers, checkAllContainersRunning, getDefaultContainerID and then when watching
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] = [][]string{
+		[]string{"id3 /edudocker_default_run_1 running 0 1234 default", "", "0"},
+		[]string{"id3 /edudocker_default_run_1 running 0 1234 default", "", "0"},
+		[]string{"id3 /edudocker_default_run_1 running 0 1234 default", "", "0"},
+		[]string{"id3 /edudocker_default_run_1 exited 1 1234 default", "", "0"},
+	}
+	return commandsReactions
+}
+
+func countElemsInArray(arr []string, str string) int {
+	count := 0
+	for _, a := range arr {
+		if strings.Contains(a, str) {
+			count++
+		}
+	}
+	return count
+}
+
+func Test_watchContainers_Events_Abort(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getWatchContainersCommandsReactions()
+	commandsReactions["docker events"] = []string{
+		getFakeDockerEvent("start", "edudocker_abc_1", "abc") + "\n" +
+			getFakeDockerEvent("start", "edudocker_def_1", "def") + "\n" +
+			getFakeDockerEvent("start", "edudocker_default_run_1", "default") + "\n" +
+			getFakeDockerEvent("die", "edudocker_abc_1", "abc") + "\n" +
+			getFakeDockerEvent("die", "edudocker_default_run_1", "default") + "\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.ExitBehavior = "abort"
+
+	events := driver.streamContainerEvents("1234")
+	assert.NotNil(t, events.Lines)
+	// returns when the default container stopped
+	driver.watchContainers(config, "1234", 3, events)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker stop id3"))
+	// the running containers are not polled
+	assert.Equal(t, 2, countElemsInArray(shellS.CommandsRun, dockerInspectCmd("edudocker_def_1")))
+}
+
+func Test_watchContainers_Events_Restart(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getWatchContainersCommandsReactions()
+	commandsReactions["docker events"] = []string{getFakeDockerEvent("die", "edudocker_abc_1", "abc") + "\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.ExitBehavior = "restart"
+	driver.RestartBackoff = time.Millisecond
+
+	events := driver.streamContainerEvents("1234")
+	go driver.watchContainers(config, "1234", 3, events)
+	started := false
+	for i := 0; i < 50 && !started; i++ {
+		time.Sleep(100 * time.Millisecond)
+		shellS.Mutex.Lock()
+		started = elem_in_array(shellS.CommandsRun, "Pretending to run: docker start edudocker_abc_1")
+		shellS.Mutex.Unlock()
+	}
+	safelyCloseChannel(driver.Stopping)
+	assert.True(t, started)
+}
+
+func Test_watchContainers_Events_PerServiceExitBehavior(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getWatchContainersCommandsReactions()
+	commandsReactions["docker events"] = []string{
+		getFakeDockerEvent("die", "edudocker_abc_1", "abc") + "\n" +
+			getFakeDockerEvent("die", "edudocker_default_run_1", "default") + "\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.ExitBehavior = "abc=ignore,def=abort"
+
+	events := driver.streamContainerEvents("1234")
+	// returns when the default container stopped
+	driver.watchContainers(config, "1234", 3, events)
+	assert.False(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker stop"))
+	assert.False(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker start"))
+	// abc is inspected only by: getDCContainers and checkAllContainersRunning
+	assert.Equal(t, 2, countElemsInArray(shellS.CommandsRun, dockerInspectCmd("edudocker_abc_1")))
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_ExitBehaviorUnknownService(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.ExitBehavior = "restart,db=abort"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 1, exitstatus)
+	assert.False(t, elem_in_array(shellS.CommandsRun, " up "))
+	assert.False(t, elem_in_array(shellS.CommandsRun, " run "))
+}
+
+func Test_watchContainers_Events_MaxRestarts(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getWatchContainersCommandsReactions()
+	abcDied := getFakeDockerEvent("die", "edudocker_abc_1", "abc") + "\n"
+	commandsReactions["docker events"] = []string{abcDied + abcDied + abcDied +
+		getFakeDockerEvent("die", "edudocker_default_run_1", "default") + "\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	driver.RestartBackoff = time.Millisecond
+	config := getTestConfig()
+	config.ExitBehavior = "restart"
+	config.MaxRestarts = "2"
+
+	events := driver.streamContainerEvents("1234")
+	// returns when the default container stopped
+	driver.watchContainers(config, "1234", 3, events)
+	assert.Equal(t, 2, countElemsInArray(shellS.CommandsRun, "Pretending to run: docker start edudocker_abc_1"))
+	// after the maximum restarts, the exit behavior is abort
+	assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker stop id3"))
+	assert.Equal(t, 2, driver.Restarts.Get("edudocker_abc_1"))
+	assert.Equal(t, "Containers restarted by dojo:\n  edudocker_abc_1: 2 times", driver.Restarts.Report())
+}
+
+func Test_watchContainers_EventsUnavailable_Polling(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getWatchContainersCommandsReactions()
+	commandsReactions["docker events"] = []string{"", "Cannot connect to the Docker daemon", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.ExitBehavior = "abort"
+
+	events := driver.streamContainerEvents("1234")
+	assert.Nil(t, events.Lines)
+	driver.watchContainers(config, "1234", 3, events)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker stop id3"))
+	assert.Equal(t, 3, countElemsInArray(shellS.CommandsRun, dockerInspectCmd("edudocker_def_1")))
+}
+
+func Test_ConstructDockerComposeCommandUp(t *testing.T) {
+	logger := NewLogger("debug")
+	driver := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.DockerComposeFile = "/tmp/dummy.yml"
+	assert.Equal(t, "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 up -d abc def",
+		driver.ConstructDockerComposeCommandUp(config, "1234", []string{"abc", "def"}, false))
+	config.HealthTimeout = "30"
+	assert.Equal(t, "docker-compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234 up -d --wait --wait-timeout 30 abc def",
+		driver.ConstructDockerComposeCommandUp(config, "1234", []string{"abc", "def"}, true))
+}
+
+// Returns the command run by getContainerHealth
+func dockerHealthInspectCmd(containerName string) string {
+	return "docker inspect --format='{{.State.Status}} {{if .State.Health}}{{.State.Health.Status}}{{end}}' " + containerName
+}
+
+func Test_getContainerHealth(t *testing.T) {
+	type mytestStruct struct {
+		output    string
+		expStatus string
+		expHealth string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{output: "running healthy\n", expStatus: "running", expHealth: "healthy"},
+		mytestStruct{output: "running starting\n", expStatus: "running", expHealth: "starting"},
+		mytestStruct{output: "exited unhealthy\n", expStatus: "exited", expHealth: "unhealthy"},
+		mytestStruct{output: "running \n", expStatus: "running", expHealth: ""},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		commandsReactions := make(map[string]interface{}, 0)
+		commandsReactions[dockerHealthInspectCmd("abc")] = []string{v.output, "", "0"}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+		status, health, err := driver.getContainerHealth("abc")
+		assert.Nil(t, err, v.output)
+		assert.Equal(t, v.expStatus, status, v.output)
+		assert.Equal(t, v.expHealth, health, v.output)
+	}
+}
+
+func getHealthCommandsReactions() map[string]interface{} {
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 ps"] =
+		[]string{getFakeDockerComposePSStdout(), "", "0"}
+	commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
+		[]string{"abc\ndef\ndefault\n", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"id1 /edudocker_abc_1 running 0 1234 abc", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_def_1")] = []string{"id2 /edudocker_def_1 running 0 1234 def", "", "0"}
+	commandsReactions[dockerInspectCmd("edudocker_default_run_1")] =
+		[]string{"id3 /edudocker_default_run_1 running 0 1234 default", "", "0"}
+	// def has no healthcheck
+	commandsReactions[dockerHealthInspectCmd("edudocker_def_1")] = []string{"running \n", "", "0"}
+	return commandsReactions
+}
+
+func Test_startContainers(t *testing.T) {
+	logger := NewLogger("debug")
+	shellS := NewMockedShellServiceNotInteractive(logger)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	err := driver.startContainers(getTestConfig(), "1234", []string{"abc", "def"}, false)
+	assert.Nil(t, err)
+	assert.True(t, elem_in_array(shellS.CommandsRun,
+		"Pretending to run: docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 up -d abc def"))
+}
+
+func Test_waitForHealthyContainers(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions[dockerHealthInspectCmd("edudocker_abc_1")] = [][]string{
+		[]string{"running starting\n", "", "0"},
+		[]string{"running healthy\n", "", "0"},
+	}
+	healthyEvent := strings.Replace(getFakeDockerEvent("health_status", "edudocker_abc_1", "abc"),
+		`"Action":"health_status"`, `"Action":"health_status: healthy"`, 1)
+	commandsReactions["docker events"] = []string{healthyEvent + "\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	events := driver.streamContainerEvents("1234")
+	err := driver.waitForHealthyContainers(getTestConfig(), "1234", 10*time.Second, events)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, countElemsInArray(shellS.CommandsRun, dockerHealthInspectCmd("edudocker_abc_1")))
+	// the default container health is not checked
+	assert.False(t, elem_in_array(shellS.CommandsRun, dockerHealthInspectCmd("edudocker_default_run_1")))
+}
+
+func Test_waitForHealthyContainers_Timeout(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions[dockerHealthInspectCmd("edudocker_abc_1")] = []string{"running starting\n", "", "0"}
+	commandsReactions["docker events"] = []string{"", "", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	events := driver.streamContainerEvents("1234")
+	err := driver.waitForHealthyContainers(getTestConfig(), "1234", time.Second, events)
+	assert.NotNil(t, err)
+	assert.Equal(t, "Timed out after 1s waiting for the containers to be healthy: edudocker_abc_1 (starting)", err.Error())
+}
+
+func Test_waitForHealthyContainers_Stopped(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions[dockerHealthInspectCmd("edudocker_abc_1")] = []string{"exited unhealthy\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	err := driver.waitForHealthyContainers(getTestConfig(), "1234", time.Minute, &ContainerEvents{})
+	assert.NotNil(t, err)
+	assert.Equal(t, "Container: edudocker_abc_1 stopped before it was healthy, its status is: exited", err.Error())
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_NotHealthy(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions[dockerHealthInspectCmd("edudocker_abc_1")] = []string{"running unhealthy\n", "", "0"}
+	commandsReactions["docker events"] = []string{"", "", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.HealthTimeout = "1"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 1, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker logs edudocker_abc_1"))
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker logs edudocker_def_1"))
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 stop"))
+	assert.False(t, elem_in_array(shellS.CommandsRun, " run "))
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_ComposeWaits(t *testing.T) {
+	type mytestStruct struct {
+		upExitStatus  string
+		expExitStatus int
+	}
+	mytests := []mytestStruct{
+		mytestStruct{upExitStatus: "0", expExitStatus: 0},
+		mytestStruct{upExitStatus: "1", expExitStatus: 1},
+	}
+	upCmd := "docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 up -d --wait --wait-timeout 30 abc def"
+	for _, v := range mytests {
+		logger := NewLogger("debug")
+		commandsReactions := getHealthCommandsReactions()
+		commandsReactions[upCmd] = []string{"", "", v.upExitStatus}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+		driver.Capabilities.Wait = true
+
+		config := getTestConfig()
+		config.Driver = "docker-compose"
+		config.RunCommand = "bla"
+		config.ExitBehavior = "ignore"
+		config.HealthTimeout = "30"
+		exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+		assert.Equal(t, v.expExitStatus, exitstatus, v.upExitStatus)
+		assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: "+upCmd), v.upExitStatus)
+		// docker-compose waits, the containers are not inspected by dojo
+		assert.False(t, elem_in_array(shellS.CommandsRun, dockerHealthInspectCmd("edudocker_abc_1")), v.upExitStatus)
+		assert.Equal(t, v.expExitStatus == 0, elem_in_array(shellS.CommandsRun, " run "), v.upExitStatus)
+	}
+}
+
+func Test_ConstructDockerLogsFollowCommand(t *testing.T) {
+	logger := NewLogger("debug")
+	driver := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	assert.Equal(t, "docker logs --follow abc 2>&1", driver.ConstructDockerLogsFollowCommand("abc", 0))
+	assert.Equal(t, "docker logs --follow --since 1700000000 abc 2>&1", driver.ConstructDockerLogsFollowCommand("abc", 1700000000))
+}
+
+func Test_followContainerLogs(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker logs --follow edudocker_abc_1"] = []string{"abc started\nabc ready\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+	var output bytes.Buffer
+	driver.LogsPrinter.Writer = &output
+	driver.LogsPrinter.SetServices([]string{"abc", "db"}, false)
+
+	driver.followContainerLogs(&ContainerInfo{Name: "edudocker_abc_1", Service: "abc"}, 0)
+	expOutput := "\033[36mabc |\033[0m abc started\n\033[36mabc |\033[0m abc ready\n"
+	assert.Eventually(t, func() bool {
+		driver.LogsPrinter.mutex.Lock()
+		defer driver.LogsPrinter.mutex.Unlock()
+		return output.String() == expOutput
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_StreamLogs(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions["docker logs --follow edudocker_abc_1"] = []string{"abc started\nabc ready\n", "", "0"}
+	commandsReactions["docker logs --follow edudocker_def_1"] = []string{"def started\n", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.ExitBehavior = "ignore"
+	config.HealthTimeout = "0"
+	config.PrintLogs = "stream"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 0, exitstatus)
+	assert.True(t, elem_in_array(shellS.CommandsRun,
+		"Pretending to run: docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 up -d abc def"))
+	assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker logs --follow edudocker_abc_1 2>&1"))
+	assert.True(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker logs --follow edudocker_def_1 2>&1"))
+	// the default container logs are not followed
+	assert.False(t, elem_in_array(shellS.CommandsRun, "docker logs --follow edudocker_default_run_1"))
+	// the logs are not printed again after the run
+	assert.False(t, elem_in_array(shellS.CommandsRun, "Pretending to run: docker logs edudocker_abc_1"))
+}
+
+func Test_saveLogsBundle(t *testing.T) {
+	type mytestStruct struct {
+		logsArchive string
+		expArchive  bool
+	}
+	mytests := []mytestStruct{
+		mytestStruct{logsArchive: "false", expArchive: false},
+		mytestStruct{logsArchive: "true", expArchive: true},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		commandsReactions := make(map[string]interface{}, 0)
+		commandsReactions["docker logs --timestamps edudocker_abc_1"] = []string{
+			"2024-02-03T21:03:46.000000000Z abc started\n", "2024-02-03T21:03:47.000000000Z abc failed\n", "0"}
+		commandsReactions["docker inspect edudocker_abc_1"] = []string{"[{\"Id\": \"id1\"}]\n", "", "0"}
+		commandsReactions["docker logs --timestamps edudocker_default_run_1"] = []string{"", "Error: No such container", "1"}
+		commandsReactions["docker inspect edudocker_default_run_1"] = []string{"[]\n", "Error: No such object", "1"}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		fileS := NewMockedFileService(logger)
+		fileS.FilesWrittenTo["docker-compose.yml.dojo"] = "services: {}\n"
+		driver := NewDockerComposeDriver(shellS, fileS, logger, "docker-compose", "")
+
+		config := getTestConfig()
+		config.LogsDir = "/tmp/logs"
+		config.LogsArchive = v.logsArchive
+		containers := []*ContainerInfo{
+			&ContainerInfo{Name: "edudocker_abc_1", Service: "abc"},
+			&ContainerInfo{Name: "edudocker_default_run_1", Service: "default"},
+		}
+		driver.saveLogsBundle(config, "1234", containers)
+		assert.Equal(t, []string{"/tmp/logs/dojo-logs-1234"}, fileS.DirsCreated, v.logsArchive)
+		assert.Equal(t, "2024-02-03T21:03:46.000000000Z stdout | abc started\n2024-02-03T21:03:47.000000000Z stderr | abc failed\n",
+			fileS.FilesWrittenTo["/tmp/logs/dojo-logs-1234/edudocker_abc_1.log"], v.logsArchive)
+		assert.Equal(t, "[{\"Id\": \"id1\"}]\n", fileS.FilesWrittenTo["/tmp/logs/dojo-logs-1234/edudocker_abc_1.inspect.json"], v.logsArchive)
+		assert.Equal(t, "services: {}\n", fileS.FilesWrittenTo["/tmp/logs/dojo-logs-1234/docker-compose.yml.dojo"], v.logsArchive)
+		// the default container was already removed
+		_, exists := fileS.FilesWrittenTo["/tmp/logs/dojo-logs-1234/edudocker_default_run_1.log"]
+		assert.False(t, exists, v.logsArchive)
+		assert.Equal(t, v.expArchive, elem_in_array(shellS.CommandsRun,
+			"tar -czf /tmp/logs/dojo-logs-1234.tar.gz -C /tmp/logs dojo-logs-1234"), v.logsArchive)
+	}
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_LogsDir(t *testing.T) {
+	logger := NewLogger("debug")
+	shellS := NewMockedShellServiceNotInteractive2(logger, getHealthCommandsReactions())
+	fileS := NewMockedFileService(logger)
+	driver := NewDockerComposeDriver(shellS, fileS, logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.ExitBehavior = "ignore"
+	config.HealthTimeout = "0"
+	config.LogsDir = "/tmp/logs"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 0, exitstatus)
+	assert.Equal(t, []string{"/tmp/logs/dojo-logs-1234"}, fileS.DirsCreated)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "docker logs --timestamps edudocker_abc_1"))
+	assert.Contains(t, fileS.FilesWrittenTo, "/tmp/logs/dojo-logs-1234/docker-compose.yml.dojo")
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_RunReport(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := getHealthCommandsReactions()
+	commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"id1 /edudocker_abc_1 exited 1 1234 abc", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
+
+	config := getTestConfig()
+	config.Driver = "docker-compose"
+	config.RunCommand = "bla"
+	config.ExitBehavior = "ignore"
+	config.HealthTimeout = "0"
+	exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+	assert.Equal(t, 0, exitstatus)
+
+	report := RunReport{}
+	driver.FillRunReport(config, &report)
+	assert.Equal(t, []RunReportContainer{
+		RunReportContainer{Name: "edudocker_abc_1", Service: "abc", Status: "exited", ExitCode: "1"},
+		RunReportContainer{Name: "edudocker_def_1", Service: "def", Status: "running", ExitCode: "0"},
+		RunReportContainer{Name: "edudocker_default_run_1", Service: "default", Default: true, Status: "exited", ExitCode: "0"},
+	}, report.Containers)
+}
+
+func TestDockerComposeDriver_HandleRun_Unit_FailOnSidecarFailure(t *testing.T) {
+	type mytestStruct struct {
+		failOnSidecarFailure string
+		expExitStatus        int
+	}
+	mytests := []mytestStruct{
+		mytestStruct{failOnSidecarFailure: "false", expExitStatus: 0},
+		mytestStruct{failOnSidecarFailure: "true", expExitStatus: 4},
+		mytestStruct{failOnSidecarFailure: "abc", expExitStatus: 4},
+		mytestStruct{failOnSidecarFailure: "def", expExitStatus: 0},
+		// invalid configuration
+		mytestStruct{failOnSidecarFailure: "stub", expExitStatus: 1},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		commandsReactions := getHealthCommandsReactions()
+		commandsReactions[dockerInspectCmd("edudocker_abc_1")] = []string{"id1 /edudocker_abc_1 exited 1 1234 abc", "", "0"}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		driver := NewDockerComposeDriver(shellS, NewMockedFileService(logger), logger, "docker-compose", "")
 
-	ids := driver.waitForContainersToBeRunning(getTestConfig(), "1234", 3)
-	assert.Equal(t, []string{"edudocker_abc_1", "edudocker_def_1", "edudocker_default_run_1"}, ids)
+		config := getTestConfig()
+		config.Driver = "docker-compose"
+		config.RunCommand = "bla"
+		config.ExitBehavior = "ignore"
+		config.HealthTimeout = "0"
+		config.FailOnSidecarFailure = v.failOnSidecarFailure
+		exitstatus := driver.HandleRun(config, "1234", NewMockedEnvService())
+		assert.Equal(t, v.expExitStatus, exitstatus, v.failOnSidecarFailure)
+	}
 }
 
 func Test_getExpectedContainers(t *testing.T) {
@@ -614,7 +1441,7 @@ default`
 		commandsReactions["docker-compose -f docker-compose.yml -f docker-compose.yml.dojo -p 1234 config --services"] =
 			[]string{tt.fakeOutput, "", fmt.Sprintf("%v", tt.fakeExitStatus)}
 		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-		driver := NewDockerComposeDriver(shellS, fs, logger, "")
+		driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
 
 		if tt.expectedError == "" {
 			containers := driver.getExpectedContainers(getTestConfig(), "1234")
@@ -677,7 +1504,8 @@ func Test_checkIfAnyContainerFailed_nonDefFailed(t *testing.T) {
 func Test_getNonDefaultContainersLogs(t *testing.T) {
 	nonDefContInfos := make([]*ContainerInfo, 0)
 	cont1Info := &ContainerInfo{
-		Name: "name1",
+		Name:    "name1",
+		Service: "abc",
 	}
 	nonDefContInfos = append(nonDefContInfos, cont1Info)
 	commandsReactions := make(map[string]interface{}, 0)
@@ -687,14 +1515,14 @@ func Test_getNonDefaultContainersLogs(t *testing.T) {
 	logger := NewLogger("debug")
 	fs := NewMockedFileService(logger)
 	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
-	driver := NewDockerComposeDriver(shellS, fs, logger, "")
-	driver.getNonDefaultContainersLogs(nonDefContInfos)
+	driver := NewDockerComposeDriver(shellS, fs, logger, "docker-compose", "")
+	driver.getNonDefaultContainersLogs(nonDefContInfos, "default")
 	assert.Equal(t, nonDefContInfos[0].Logs, "stderr:\nstdout:\n123")
 }
 
 func Test_parseDCPSOutPut_DCVersion2_whenOutputNonEmpty(t *testing.T) {
 	dc_ps_output := "{\"Command\":\"\\\"/bin/sh -c 'while t…\\\"\",\"CreatedAt\":\"2024-02-03 21:03:46 +0000 UTC\",\"ExitCode\":0,\"Health\":\"\",\"ID\":\"2d5c5b0343d0\",\"Image\":\"alpine:3.19\",\"Labels\":\"com.docker.compose.depends_on=,com.docker.compose.image=sha256:05455a08881ea9cf0e752bc48e61bbd71a34c029bb13df01e40e3e70e0d007bd,com.docker.compose.version=2.24.5,com.docker.compose.service=abc,com.docker.compose.config-hash=270e27422cb1e6a4c1713ae22a3ffca0e8aa50ec0f06fe493fa4f83a17bd29e9,com.docker.compose.container-number=1,com.docker.compose.oneoff=False,com.docker.compose.project=testdojorunid,com.docker.compose.project.config_files=/dojo/work/test/test-files/itest-dc.yaml,/dojo/work/test/test-files/itest-dc.yaml.dojo,com.docker.compose.project.working_dir=/dojo/work/test/test-files\",\"LocalVolumes\":\"0\",\"Mounts\":\"/tmp/test-dojo…,/tmp/test-dojo…\",\"Name\":\"testdojorunid-abc-1\",\"Names\":\"testdojorunid-abc-1\",\"Networks\":\"testdojorunid_default\",\"Ports\":\"\",\"Project\":\"testdojorunid\",\"Publishers\":null,\"RunningFor\":\"3 seconds ago\",\"Service\":\"abc\",\"Size\":\"0B\",\"State\":\"running\",\"Status\":\"Up 2 seconds\"}\n{\"Command\":\"\\\"/bin/sh -c 'while t…\\\"\",\"CreatedAt\":\"2024-02-03 21:03:46 +0000 UTC\",\"ExitCode\":0,\"Health\":\"\",\"ID\":\"b2ed210567c3\",\"Image\":\"alpine:3.19\",\"Labels\":\"com.docker.compose.project=testdojorunid,com.docker.compose.project.config_files=/dojo/work/test/test-files/itest-dc.yaml,/dojo/work/test/test-files/itest-dc.yaml.dojo,com.docker.compose.project.working_dir=/dojo/work/test/test-files,com.docker.compose.depends_on=,com.docker.compose.container-number=1,com.docker.compose.image=sha256:05455a08881ea9cf0e752bc48e61bbd71a34c029bb13df01e40e3e70e0d007bd,com.docker.compose.oneoff=False,com.docker.compose.service=def,com.docker.compose.version=2.24.5,com.docker.compose.config-hash=270e27422cb1e6a4c1713ae22a3ffca0e8aa50ec0f06fe493fa4f83a17bd29e9\",\"LocalVolumes\":\"0\",\"Mounts\":\"/tmp/test-dojo…,/tmp/test-dojo…\",\"Name\":\"testdojorunid-def-1\",\"Names\":\"testdojorunid-def-1\",\"Networks\":\"testdojorunid_default\",\"Ports\":\"\",\"Project\":\"testdojorunid\",\"Publishers\":null,\"RunningFor\":\"3 seconds ago\",\"Service\":\"def\",\"Size\":\"0B\",\"State\":\"running\",\"Status\":\"Up 2 seconds\"}\n{\"Command\":\"\\\"sh -c 'sleep 10'\\\"\",\"CreatedAt\":\"2024-02-03 21:03:47 +0000 UTC\",\"ExitCode\":0,\"Health\":\"\",\"ID\":\"af4817fede41\",\"Image\":\"alpine:3.15\",\"Labels\":\"com.docker.compose.version=2.24.5,com.docker.compose.container-number=1,com.docker.compose.depends_on=abc:service_started:true,def:service_started:true,com.docker.compose.oneoff=True,com.docker.compose.project=testdojorunid,com.docker.compose.slug=742bcbb0e4bc05b21928a8d17be4ea9bb12a6775fd40692dd59c74a460279eb8,com.docker.compose.config-hash=462afacb4521d13580c2096c7b00b98970f07fe841e408c4c5a95a4a46839eaa,com.docker.compose.image=sha256:32b91e3161c8fc2e3baf2732a594305ca5093c82ff4e0c9f6ebbd2a879468e1d,com.docker.compose.project.config_files=/dojo/work/test/test-files/itest-dc.yaml,/dojo/work/test/test-files/itest-dc.yaml.dojo,com.docker.compose.project.working_dir=/dojo/work/test/test-files,com.docker.compose.service=default\",\"LocalVolumes\":\"0\",\"Mounts\":\"/home/dojo,/dojo/work,/tmp/test-dojo…,/tmp/test-dojo…,/tmp/.X11-unix,/tmp/dojo-ites…\",\"Name\":\"testdojorunid-default-run-742bcbb0e4bc\",\"Names\":\"testdojorunid-default-run-742bcbb0e4bc\",\"Networks\":\"testdojorunid_default\",\"Ports\":\"\",\"Project\":\"testdojorunid\",\"Publishers\":null,\"RunningFor\":\"2 seconds ago\",\"Service\":\"default\",\"Size\":\"0B\",\"State\":\"running\",\"Status\":\"Up 1 second\"}\n"
-	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output)
+	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output, false)
 	assert.Equal(t, "", err)
 	assert.Equal(t, 3, len(output_as_json))
 	assert.Equal(t, "2024-02-03 21:03:46 +0000 UTC", output_as_json[0].CreatedAt)
@@ -703,34 +1531,116 @@ func Test_parseDCPSOutPut_DCVersion2_whenOutputNonEmpty(t *testing.T) {
 
 func Test_parseDCPSOutPut_DCVersion2_whenOutputEmpty(t *testing.T) {
 	dc_ps_output := ""
-	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output)
+	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output, false)
 	assert.Equal(t, "", err)
 	assert.Equal(t, 0, len(output_as_json))
 }
 
 func Test_parseDCPSOutPut_DCVersion2_whenOutputNonEmptyButInvalid(t *testing.T) {
 	dc_ps_output := "{\"Command123\":\"\\\"/bin/sh -c 'while t…\\\"\"}"
-	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output)
+	output_as_json, err := ParseDCPSOutPut_DCVersion2(dc_ps_output, false)
 	assert.Contains(t, err, "State was an empty string")
 	assert.Equal(t, 0, len(output_as_json))
 }
 
-func Test_isDCVersionLaterThan2_v2WithoutV(t *testing.T) {
-	assert.Equal(t, true, isDCVersionLaterThan2("2.24.5"))
-}
-
-func Test_isDCVersionLaterThan2_v2WithV(t *testing.T) {
-	assert.Equal(t, true, isDCVersionLaterThan2("v2.24.5"))
+func Test_verifyDCFile_CustomService(t *testing.T) {
+	contents := `services:
+  app:
+    init: true
+  db:
+    image: postgres:11.2-alpine
+`
+	logger := NewLogger("debug")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	_, err := dc.verifyDCFile(contents, "filePath.yml", "app")
+	assert.Nil(t, err)
+	_, err = dc.verifyDCFile(contents, "filePath.yml", "default")
+	assert.Equal(t, "docker-compose file: filePath.yml:1: services do not contain: default. Please add a default service", err.Error())
 }
 
-func Test_isDCVersionLaterThan2_v1WithoutV(t *testing.T) {
-	assert.Equal(t, false, isDCVersionLaterThan2("1.24.5"))
+func Test_addEnvToDCOverrideFile_CustomService(t *testing.T) {
+	setTestEnv()
+	logger := NewLogger("debug")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker-compose", "")
+	config := getTestConfig()
+	config.DockerComposeService = "app"
+	overrideFile := dc.generateInitialDCFile(config, "")
+	dc.addEnvToDCOverrideFile(overrideFile, []string{"app", "db"}, config,
+		"/tmp/env-file.txt", "/tmp/env-file-multiline.txt", "/tmp/env-file-bash-functions.txt")
+	assert.Equal(t, 2, len(overrideFile.Services))
+	assert.Equal(t, "img:1.2.3", overrideFile.Services["app"].Image)
+	assert.Contains(t, overrideFile.Services["app"].Volumes, "/tmp/bla:/dojo/work")
+	assert.Equal(t, "", overrideFile.Services["db"].Image)
+	assert.NotContains(t, overrideFile.Services["db"].Volumes, "/tmp/bla:/dojo/work")
 }
 
-func Test_isDCVersionLaterThan2_v1WithV(t *testing.T) {
-	assert.Equal(t, false, isDCVersionLaterThan2("v1.24.5"))
+func Test_DetectDockerComposeCommand(t *testing.T) {
+	type mytestStruct struct {
+		userCommand      string
+		commandsReaction map[string]interface{}
+		expCommand       string
+		expVersion       string
+		expErrorMsg      string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{
+			commandsReaction: map[string]interface{}{
+				"docker-compose version --short": []string{"1.29.2\n", "", "0"},
+				"docker compose version --short": []string{"2.24.5\n", "", "0"},
+			},
+			expCommand: "docker-compose", expVersion: "1.29.2"},
+		mytestStruct{
+			commandsReaction: map[string]interface{}{
+				"docker-compose version --short": []string{"", "bash: docker-compose: command not found", "127"},
+				"docker compose version --short": []string{"2.24.5\n", "", "0"},
+			},
+			expCommand: "docker compose", expVersion: "2.24.5"},
+		mytestStruct{
+			commandsReaction: map[string]interface{}{
+				"docker-compose version --short": []string{"", "bash: docker-compose: command not found", "127"},
+				"docker compose version --short": []string{"", "docker: 'compose' is not a docker command.", "1"},
+			},
+			expErrorMsg: "Neither docker-compose nor the docker compose plugin is installed. Please install one of them or set DOJO_DOCKER_COMPOSE_COMMAND"},
+		mytestStruct{userCommand: "docker compose",
+			commandsReaction: map[string]interface{}{
+				"docker-compose version --short": []string{"1.29.2\n", "", "0"},
+				"docker compose version --short": []string{"2.31.0\n", "", "0"},
+			},
+			expCommand: "docker compose", expVersion: "2.31.0"},
+		mytestStruct{userCommand: "podman-compose",
+			commandsReaction: map[string]interface{}{
+				"podman-compose version --short": []string{"", "bash: podman-compose: command not found", "127"},
+			},
+			expErrorMsg: "DockerComposeCommand: podman-compose does not work. Unexpected error: Command: podman-compose version --short"},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		shellS := NewMockedShellServiceNotInteractive2(logger, v.commandsReaction)
+		command, version, err := DetectDockerComposeCommand(shellS, v.userCommand)
+		if v.expErrorMsg != "" {
+			assert.NotNil(t, err, v.userCommand)
+			assert.Contains(t, err.Error(), v.expErrorMsg)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, v.expCommand, command)
+		assert.Equal(t, v.expVersion, version)
+	}
 }
 
-func Test_isDCVersionLaterThan2_empty(t *testing.T) {
-	assert.Equal(t, false, isDCVersionLaterThan2(""))
+func Test_DockerComposeCommands_Plugin(t *testing.T) {
+	setTestEnv()
+	logger := NewLogger("debug")
+	dc := NewDockerComposeDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "docker compose", "2.24.5")
+	config := getTestConfig()
+	config.DockerComposeFile = "/tmp/dummy.yml"
+	config.RunCommand = "bla"
+	part1 := "docker compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p 1234"
+	assert.Equal(t, part1+" run --rm -T default bla", dc.ConstructDockerComposeCommandRun(config, "1234"))
+	assert.Equal(t, part1+" ps --format json --all", dc.ConstructDockerComposeCommandPs(config, "1234"))
+	assert.Equal(t, part1+" stop", dc.ConstructDockerComposeCommandStop(config, "1234"))
+	assert.Equal(t, part1+" kill", dc.ConstructDockerComposeCommandKill(config, "1234"))
+	assert.Equal(t, part1+" down", dc.ConstructDockerComposeCommandDown(config, "1234"))
+	assert.Equal(t, "docker compose -f /tmp/dummy.yml -f /tmp/dummy.yml.dojo -p dojo pull",
+		dc.ConstructDockerComposeCommandPull(config, "/tmp/dummy.yml.dojo"))
 }
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ExitBehaviors define how to react when a container (not the default one) exits, per docker-compose service.
+// They are parsed from the ExitBehavior option, e.g. "db=abort,mock=restart,migrate=ignore". A value without
+// a service name is used for the services not listed, e.g. "restart,db=abort". Default: abort.
+type ExitBehaviors struct {
+	Default    string
+	PerService map[string]string
+}
+
+func isExitBehaviorSupported(behavior string) bool {
+	return behavior == "abort" || behavior == "ignore" || behavior == "restart"
+}
+
+func parseExitBehaviors(value string) (ExitBehaviors, error) {
+	exitBehaviors := ExitBehaviors{
+		Default:    "abort",
+		PerService: make(map[string]string),
+	}
+	defaultSet := false
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if !strings.Contains(item, "=") {
+			if !isExitBehaviorSupported(item) {
+				return ExitBehaviors{}, fmt.Errorf(
+					"Invalid configuration, ExitBehavior supported values are: abort, ignore, restart. It was set to: %s", value)
+			}
+			if defaultSet {
+				return ExitBehaviors{}, fmt.Errorf(
+					"Invalid configuration, ExitBehavior must contain at most one value without a service name. It was set to: %s", value)
+			}
+			exitBehaviors.Default = item
+			defaultSet = true
+			continue
+		}
+		kv := strings.SplitN(item, "=", 2)
+		service := strings.TrimSpace(kv[0])
+		behavior := strings.TrimSpace(kv[1])
+		if !dcServiceNameRegexp.MatchString(service) {
+			return ExitBehaviors{}, fmt.Errorf(
+				"Invalid configuration, ExitBehavior contains an invalid service name: %q. It was set to: %s", service, value)
+		}
+		if !isExitBehaviorSupported(behavior) {
+			return ExitBehaviors{}, fmt.Errorf(
+				"Invalid configuration, ExitBehavior for service: %s supported values are: abort, ignore, restart. It was set to: %s",
+				service, behavior)
+		}
+		if _, exists := exitBehaviors.PerService[service]; exists {
+			return ExitBehaviors{}, fmt.Errorf("Invalid configuration, ExitBehavior is set twice for service: %s", service)
+		}
+		exitBehaviors.PerService[service] = behavior
+	}
+	return exitBehaviors, nil
+}
+
+// Returns the exit behavior of the service
+func (e ExitBehaviors) Get(service string) string {
+	if behavior, exists := e.PerService[service]; exists {
+		return behavior
+	}
+	return e.Default
+}
+
+// Returns true if the exits of all the containers are ignored, so that there is no need to watch them
+func (e ExitBehaviors) AllIgnored() bool {
+	if e.Default != "ignore" {
+		return false
+	}
+	for _, behavior := range e.PerService {
+		if behavior != "ignore" {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns an error if an exit behavior is set for a service, which is not in the docker-compose file
+// (expServices), or for the default service, which is not watched.
+func (e ExitBehaviors) verifyServices(expServices []string, defaultService string) error {
+	services := make([]string, 0)
+	for service := range e.PerService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		if service == defaultService {
+			return fmt.Errorf(
+				"Invalid configuration, ExitBehavior cannot be set for the default service: %s, it applies only to the other services",
+				service)
+		}
+		found := false
+		for _, expService := range expServices {
+			if service == expService {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf(
+				"Invalid configuration, ExitBehavior is set for service: %s, which is not in the docker-compose file. The services are: %s",
+				service, strings.Join(expServices, ", "))
+		}
+	}
+	return nil
+}
+
+func (e ExitBehaviors) String() string {
+	services := make([]string, 0)
+	for service := range e.PerService {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	str := e.Default
+	for _, service := range services {
+		str += fmt.Sprintf(",%s=%s", service, e.PerService[service])
+	}
+	return str
+}
+
+// The delay before a container restart is doubled with each restart of that container, up to this value
+const dcRestartBackoffMax = 30 * time.Second
+
+// Returns the delay before the restart number: restart (starting from 1) of a container
+func getRestartBackoff(initial time.Duration, restart int) time.Duration {
+	backoff := initial
+	for i := 1; i < restart; i++ {
+		backoff *= 2
+		if backoff >= dcRestartBackoffMax {
+			return dcRestartBackoffMax
+		}
+	}
+	return backoff
+}
+
+// ContainersRestarts counts how many times each container was restarted by dojo.
+// It is shared between the goroutine watching the containers and the one handling the run.
+type ContainersRestarts struct {
+	mutex  *sync.Mutex
+	counts map[string]int
+}
+
+func NewContainersRestarts() *ContainersRestarts {
+	return &ContainersRestarts{
+		mutex:  &sync.Mutex{},
+		counts: make(map[string]int),
+	}
+}
+
+func (r *ContainersRestarts) Get(containerName string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.counts[containerName]
+}
+
+// Returns the number of restarts, including this one
+func (r *ContainersRestarts) Increment(containerName string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.counts[containerName]++
+	return r.counts[containerName]
+}
+
+// Returns how many restarts each container needed or an empty string if no container was restarted
+func (r *ContainersRestarts) Report() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if len(r.counts) == 0 {
+		return ""
+	}
+	names := make([]string, 0)
+	for name := range r.counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	report := "Containers restarted by dojo:"
+	for _, name := range names {
+		report += fmt.Sprintf("\n  %s: %v times", name, r.counts[name])
+	}
+	return report
+}
+
+// The exit status of dojo, when the default container succeeded, but a container it should fail on failed
+const dcSidecarFailureExitStatus = 4
+
+// SidecarFailurePolicy is parsed from the FailOnSidecarFailure option: true (fail on a failure of a container
+// of any service, but the default one), false or a list of services, split by commas.
+type SidecarFailurePolicy struct {
+	All      bool
+	Services []string
+}
+
+func parseFailOnSidecarFailure(value string) (SidecarFailurePolicy, error) {
+	if value == "true" {
+		return SidecarFailurePolicy{All: true}, nil
+	}
+	if value == "false" {
+		return SidecarFailurePolicy{}, nil
+	}
+	services := make([]string, 0)
+	for _, service := range strings.Split(value, ",") {
+		service = strings.TrimSpace(service)
+		if !dcServiceNameRegexp.MatchString(service) {
+			return SidecarFailurePolicy{}, fmt.Errorf(
+				"Invalid configuration, FailOnSidecarFailure supported values are: true, false or a list of services, split by commas. It was set to: %s",
+				value)
+		}
+		services = append(services, service)
+	}
+	return SidecarFailurePolicy{Services: services}, nil
+}
+
+// Returns true if a failure of a container of the service should fail the run
+func (p SidecarFailurePolicy) Applies(service string) bool {
+	if p.All {
+		return true
+	}
+	for _, s := range p.Services {
+		if s == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns an error if a listed service is not in the docker-compose file (expServices) or it is the default service
+func (p SidecarFailurePolicy) verifyServices(expServices []string, defaultService string) error {
+	for _, service := range p.Services {
+		if service == defaultService {
+			return fmt.Errorf(
+				"Invalid configuration, FailOnSidecarFailure cannot list the default service: %s, its exit status is always used", service)
+		}
+		found := false
+		for _, expService := range expServices {
+			if service == expService {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf(
+				"Invalid configuration, FailOnSidecarFailure lists service: %s, which is not in the docker-compose file. The services are: %s",
+				service, strings.Join(expServices, ", "))
+		}
+	}
+	return nil
+}
+
+// Returns the containers, which failed (exited with a non-zero exit code) and the policy applies to their services
+func (p SidecarFailurePolicy) getFailedContainers(containerInfos []*ContainerInfo) []*ContainerInfo {
+	failed := make([]*ContainerInfo, 0)
+	for _, containerInfo := range containerInfos {
+		if containerInfo.ExitCode != "0" && p.Applies(containerInfo.Service) {
+			failed = append(failed, containerInfo)
+		}
+	}
+	return failed
+}
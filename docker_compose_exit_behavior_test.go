@@ -0,0 +1,189 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_parseExitBehaviors(t *testing.T) {
+	type mytestStruct struct {
+		value       string
+		expDefault  string
+		expServices map[string]string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{value: "abort", expDefault: "abort", expServices: map[string]string{}},
+		mytestStruct{value: "restart", expDefault: "restart", expServices: map[string]string{}},
+		mytestStruct{value: "db=abort,mock=restart,migrate=ignore", expDefault: "abort",
+			expServices: map[string]string{"db": "abort", "mock": "restart", "migrate": "ignore"}},
+		mytestStruct{value: "ignore, db=abort", expDefault: "ignore", expServices: map[string]string{"db": "abort"}},
+		mytestStruct{value: "db = restart ,ignore", expDefault: "ignore", expServices: map[string]string{"db": "restart"}},
+		mytestStruct{value: "stop",
+			expErrorMsg: "Invalid configuration, ExitBehavior supported values are: abort, ignore, restart. It was set to: stop"},
+		mytestStruct{value: "",
+			expErrorMsg: "Invalid configuration, ExitBehavior supported values are: abort, ignore, restart. It was set to: "},
+		mytestStruct{value: "abort,restart",
+			expErrorMsg: "Invalid configuration, ExitBehavior must contain at most one value without a service name. It was set to: abort,restart"},
+		mytestStruct{value: "db=stop",
+			expErrorMsg: "Invalid configuration, ExitBehavior for service: db supported values are: abort, ignore, restart. It was set to: stop"},
+		mytestStruct{value: "db=abort,db=restart",
+			expErrorMsg: "Invalid configuration, ExitBehavior is set twice for service: db"},
+		mytestStruct{value: "my db=abort",
+			expErrorMsg: "Invalid configuration, ExitBehavior contains an invalid service name: \"my db\". It was set to: my db=abort"},
+	}
+	for _, v := range mytests {
+		exitBehaviors, err := parseExitBehaviors(v.value)
+		if v.expErrorMsg != "" {
+			assert.NotNil(t, err, v.value)
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.value)
+			continue
+		}
+		assert.Nil(t, err, v.value)
+		assert.Equal(t, v.expDefault, exitBehaviors.Default, v.value)
+		assert.Equal(t, v.expServices, exitBehaviors.PerService, v.value)
+	}
+}
+
+func Test_ExitBehaviors_Get(t *testing.T) {
+	exitBehaviors, err := parseExitBehaviors("restart,db=abort,migrate=ignore")
+	assert.Nil(t, err)
+	assert.Equal(t, "abort", exitBehaviors.Get("db"))
+	assert.Equal(t, "ignore", exitBehaviors.Get("migrate"))
+	assert.Equal(t, "restart", exitBehaviors.Get("mock"))
+	assert.Equal(t, "restart,db=abort,migrate=ignore", exitBehaviors.String())
+}
+
+func Test_ExitBehaviors_AllIgnored(t *testing.T) {
+	type mytestStruct struct {
+		value string
+		exp   bool
+	}
+	mytests := []mytestStruct{
+		mytestStruct{value: "ignore", exp: true},
+		mytestStruct{value: "ignore,migrate=ignore", exp: true},
+		mytestStruct{value: "ignore,db=abort", exp: false},
+		mytestStruct{value: "migrate=ignore", exp: false},
+		mytestStruct{value: "abort", exp: false},
+	}
+	for _, v := range mytests {
+		exitBehaviors, err := parseExitBehaviors(v.value)
+		assert.Nil(t, err, v.value)
+		assert.Equal(t, v.exp, exitBehaviors.AllIgnored(), v.value)
+	}
+}
+
+func Test_ExitBehaviors_verifyServices(t *testing.T) {
+	type mytestStruct struct {
+		value       string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{value: "abort"},
+		mytestStruct{value: "db=abort,mock=restart,migrate=ignore"},
+		mytestStruct{value: "db=abort,cache=restart",
+			expErrorMsg: "Invalid configuration, ExitBehavior is set for service: cache, which is not in the docker-compose file. The services are: db, mock, migrate, default"},
+		mytestStruct{value: "default=restart",
+			expErrorMsg: "Invalid configuration, ExitBehavior cannot be set for the default service: default, it applies only to the other services"},
+	}
+	for _, v := range mytests {
+		exitBehaviors, err := parseExitBehaviors(v.value)
+		assert.Nil(t, err, v.value)
+		err = exitBehaviors.verifyServices([]string{"db", "mock", "migrate", "default"}, "default")
+		if v.expErrorMsg != "" {
+			assert.NotNil(t, err, v.value)
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.value)
+		} else {
+			assert.Nil(t, err, v.value)
+		}
+	}
+}
+
+func Test_getRestartBackoff(t *testing.T) {
+	type mytestStruct struct {
+		restart    int
+		expBackoff time.Duration
+	}
+	mytests := []mytestStruct{
+		mytestStruct{restart: 1, expBackoff: time.Second},
+		mytestStruct{restart: 2, expBackoff: 2 * time.Second},
+		mytestStruct{restart: 3, expBackoff: 4 * time.Second},
+		mytestStruct{restart: 5, expBackoff: 16 * time.Second},
+		mytestStruct{restart: 6, expBackoff: 30 * time.Second},
+		mytestStruct{restart: 100, expBackoff: 30 * time.Second},
+	}
+	for _, v := range mytests {
+		assert.Equal(t, v.expBackoff, getRestartBackoff(time.Second, v.restart), v.restart)
+	}
+}
+
+func Test_ContainersRestarts(t *testing.T) {
+	restarts := NewContainersRestarts()
+	assert.Equal(t, "", restarts.Report())
+	assert.Equal(t, 0, restarts.Get("p-mock-1"))
+	assert.Equal(t, 1, restarts.Increment("p-mock-1"))
+	assert.Equal(t, 2, restarts.Increment("p-mock-1"))
+	assert.Equal(t, 1, restarts.Increment("p-db-1"))
+	assert.Equal(t, 2, restarts.Get("p-mock-1"))
+	assert.Equal(t, "Containers restarted by dojo:\n  p-db-1: 1 times\n  p-mock-1: 2 times", restarts.Report())
+}
+
+func Test_parseFailOnSidecarFailure(t *testing.T) {
+	type mytestStruct struct {
+		value       string
+		expPolicy   SidecarFailurePolicy
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{value: "true", expPolicy: SidecarFailurePolicy{All: true}},
+		mytestStruct{value: "false", expPolicy: SidecarFailurePolicy{}},
+		mytestStruct{value: "stub", expPolicy: SidecarFailurePolicy{Services: []string{"stub"}}},
+		mytestStruct{value: "stub, db", expPolicy: SidecarFailurePolicy{Services: []string{"stub", "db"}}},
+		mytestStruct{value: "",
+			expErrorMsg: "Invalid configuration, FailOnSidecarFailure supported values are: true, false or a list of services, split by commas. It was set to: "},
+		mytestStruct{value: "stub,,db",
+			expErrorMsg: "Invalid configuration, FailOnSidecarFailure supported values are: true, false or a list of services, split by commas. It was set to: stub,,db"},
+	}
+	for _, v := range mytests {
+		policy, err := parseFailOnSidecarFailure(v.value)
+		if v.expErrorMsg != "" {
+			assert.NotNil(t, err, v.value)
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.value)
+			continue
+		}
+		assert.Nil(t, err, v.value)
+		assert.Equal(t, v.expPolicy, policy, v.value)
+	}
+}
+
+func Test_SidecarFailurePolicy_verifyServices(t *testing.T) {
+	expServices := []string{"stub", "db", "default"}
+	policy, _ := parseFailOnSidecarFailure("stub,db")
+	assert.Nil(t, policy.verifyServices(expServices, "default"))
+	policy, _ = parseFailOnSidecarFailure("true")
+	assert.Nil(t, policy.verifyServices(expServices, "default"))
+
+	policy, _ = parseFailOnSidecarFailure("stub,cache")
+	err := policy.verifyServices(expServices, "default")
+	assert.Equal(t, "Invalid configuration, FailOnSidecarFailure lists service: cache, which is not in the docker-compose file. The services are: stub, db, default",
+		err.Error())
+	policy, _ = parseFailOnSidecarFailure("default")
+	err = policy.verifyServices(expServices, "default")
+	assert.Equal(t, "Invalid configuration, FailOnSidecarFailure cannot list the default service: default, its exit status is always used",
+		err.Error())
+}
+
+func Test_SidecarFailurePolicy_getFailedContainers(t *testing.T) {
+	containerInfos := []*ContainerInfo{
+		&ContainerInfo{Name: "p-stub-1", Service: "stub", Status: "exited", ExitCode: "1"},
+		&ContainerInfo{Name: "p-db-1", Service: "db", Status: "running", ExitCode: "0"},
+		&ContainerInfo{Name: "p-migrate-1", Service: "migrate", Status: "exited", ExitCode: "2"},
+	}
+	policy, _ := parseFailOnSidecarFailure("true")
+	assert.Equal(t, []string{"p-stub-1", "p-migrate-1"}, getContainersNames(policy.getFailedContainers(containerInfos)))
+	policy, _ = parseFailOnSidecarFailure("stub,db")
+	assert.Equal(t, []string{"p-stub-1"}, getContainersNames(policy.getFailedContainers(containerInfos)))
+	policy, _ = parseFailOnSidecarFailure("false")
+	assert.Equal(t, 0, len(policy.getFailedContainers(containerInfos)))
+}
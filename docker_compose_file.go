@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DCFile is a parsed docker-compose file. Supported are the versioned file formats (2.x and 3.x)
+// and the unversioned Compose Specification.
+type DCFile struct {
+	FilePath string
+	// Version is the value of the top-level version key, as written in the file. Empty if not set.
+	Version string
+	// ServicesNames are in the same order as in the file
+	ServicesNames []string
+	versionNode   *yaml.Node
+	servicesKey   *yaml.Node
+	services      map[string]*yaml.Node
+	// the nodes of the services keys, used to point at the line of a service
+	servicesKeys map[string]*yaml.Node
+}
+
+var yamlErrorLineRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+func (f DCFile) errorAt(line int, format string, a ...interface{}) error {
+	return fmt.Errorf("docker-compose file: %s:%v: %s", f.FilePath, line, fmt.Sprintf(format, a...))
+}
+
+func yamlKindToString(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "sequence"
+	case yaml.ScalarNode:
+		if node.Tag == "!!null" {
+			return "null"
+		}
+		return fmt.Sprintf("scalar: %s", node.Value)
+	case yaml.AliasNode:
+		return "alias"
+	default:
+		return "document"
+	}
+}
+
+// Returns the value node of the key in the mapping node and the key node itself. Both are nil if not found.
+func yamlMappingGet(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1], mapping.Content[i]
+		}
+	}
+	return nil, nil
+}
+
+// Parses the docker-compose file contents. Returns an error (with a line number when possible)
+// if the contents are not valid YAML or if the top-level structure is not as expected.
+func parseDCFile(contents string, filePath string) (DCFile, error) {
+	dcFile := DCFile{
+		FilePath:     filePath,
+		services:     make(map[string]*yaml.Node),
+		servicesKeys: make(map[string]*yaml.Node),
+	}
+	var document yaml.Node
+	err := yaml.Unmarshal([]byte(contents), &document)
+	if err != nil {
+		matches := yamlErrorLineRegexp.FindStringSubmatch(err.Error())
+		if matches != nil {
+			line, _ := strconv.Atoi(matches[1])
+			return DCFile{}, dcFile.errorAt(line, "invalid YAML: %s", matches[2])
+		}
+		return DCFile{}, fmt.Errorf("docker-compose file: %s: invalid YAML: %s", filePath, strings.TrimPrefix(err.Error(), "yaml: "))
+	}
+	if len(document.Content) == 0 {
+		return DCFile{}, fmt.Errorf("docker-compose file: %s is empty", filePath)
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return DCFile{}, dcFile.errorAt(root.Line, "expected a mapping at the top level, got: %s", yamlKindToString(root))
+	}
+
+	versionNode, _ := yamlMappingGet(root, "version")
+	if versionNode != nil {
+		if versionNode.Kind != yaml.ScalarNode {
+			return DCFile{}, dcFile.errorAt(versionNode.Line, "version should be a string, got: %s", yamlKindToString(versionNode))
+		}
+		dcFile.Version = versionNode.Value
+		dcFile.versionNode = versionNode
+	}
+
+	servicesNode, servicesKey := yamlMappingGet(root, "services")
+	if servicesNode == nil {
+		return dcFile, nil
+	}
+	if servicesNode.Kind != yaml.MappingNode {
+		return DCFile{}, dcFile.errorAt(servicesKey.Line, "services should be a mapping, got: %s", yamlKindToString(servicesNode))
+	}
+	dcFile.servicesKey = servicesKey
+	for i := 0; i+1 < len(servicesNode.Content); i += 2 {
+		key := servicesNode.Content[i]
+		if previous, exists := dcFile.servicesKeys[key.Value]; exists {
+			return DCFile{}, dcFile.errorAt(key.Line, "service %s is defined more than once, first at line: %v", key.Value, previous.Line)
+		}
+		dcFile.ServicesNames = append(dcFile.ServicesNames, key.Value)
+		dcFile.services[key.Value] = servicesNode.Content[i+1]
+		dcFile.servicesKeys[key.Value] = key
+	}
+	return dcFile, nil
+}
+
+// Returns the version as a number, or -1 if the version is not set.
+func (f DCFile) VersionNumber() (float64, error) {
+	if f.versionNode == nil {
+		return -1, nil
+	}
+	version, err := strconv.ParseFloat(f.Version, 64)
+	if err != nil {
+		return 0, f.errorAt(f.versionNode.Line, "version should be a number, current version: %s", f.Version)
+	}
+	return version, nil
+}
+
+// Returns the image of the service and whether the service is built (has the build key).
+// The image is empty if not set.
+func (f DCFile) ServiceImage(name string) (string, bool) {
+	service, exists := f.services[name]
+	if !exists || service.Kind != yaml.MappingNode {
+		return "", false
+	}
+	buildNode, _ := yamlMappingGet(service, "build")
+	imageNode, _ := yamlMappingGet(service, "image")
+	if imageNode == nil || imageNode.Kind != yaml.ScalarNode {
+		return "", buildNode != nil
+	}
+	return imageNode.Value, buildNode != nil
+}
+
+func (f DCFile) HasService(name string) bool {
+	_, exists := f.services[name]
+	return exists
+}
+
+// Verifies that the file can be used by dojo: the version (if set) is >= 2 and
+// there is the default service (by default named: default), which is a mapping.
+func (f DCFile) Verify(defaultService string) error {
+	version, err := f.VersionNumber()
+	if err != nil {
+		return err
+	}
+	if version != -1 && version < 2 {
+		return f.errorAt(f.versionNode.Line, "should contain version number >=2, current version: %s", f.Version)
+	}
+	if f.servicesKey == nil {
+		return fmt.Errorf("docker-compose file: %s does not contain: services. Please add a default service", f.FilePath)
+	}
+	if !f.HasService(defaultService) {
+		return f.errorAt(f.servicesKey.Line, "services do not contain: %s. Please add a default service", defaultService)
+	}
+	service := f.services[defaultService]
+	if service.Kind != yaml.MappingNode {
+		return f.errorAt(f.servicesKeys[defaultService].Line, "service %s should be a mapping, got: %s", defaultService, yamlKindToString(service))
+	}
+	return nil
+}
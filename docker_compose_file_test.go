@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_parseDCFile_Version(t *testing.T) {
+	type mytests struct {
+		content        string
+		expectedOutput float64
+		expectedErrMsg string
+	}
+	mytestsObj := []mytests{
+		mytests{"version: '4.55'", 4.55, ""},
+		mytests{"version: \"4.55\"", 4.55, ""},
+		mytests{"version: 3", 3, ""},
+		mytests{"# comment\nservices: {}\nversion: '3.8'\n", 3.8, ""},
+		mytests{"services: {}", -1, ""},
+		mytests{"version: 'abc'", 0, "docker-compose file: filePath.yml:1: version should be a number, current version: abc"},
+	}
+	for _, v := range mytestsObj {
+		dcFile, err := parseDCFile(v.content, "filePath.yml")
+		assert.Nil(t, err, v.content)
+		actualVersion, err := dcFile.VersionNumber()
+		assert.Equal(t, v.expectedOutput, actualVersion, v.content)
+		if v.expectedErrMsg == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.Equal(t, v.expectedErrMsg, err.Error())
+		}
+	}
+}
+
+func Test_parseDCFile(t *testing.T) {
+	contents := `version: '3.8'
+services:
+  default:
+    links:
+      - db:db
+  db:
+    image: postgres:11.2-alpine
+`
+	dcFile, err := parseDCFile(contents, "docker-compose.yml")
+	assert.Nil(t, err)
+	assert.Equal(t, "3.8", dcFile.Version)
+	assert.Equal(t, []string{"default", "db"}, dcFile.ServicesNames)
+	assert.True(t, dcFile.HasService("db"))
+	assert.False(t, dcFile.HasService("links"))
+}
+
+func Test_parseDCFile_Errors(t *testing.T) {
+	type mytests struct {
+		content        string
+		expectedErrMsg string
+	}
+	mytestsObj := []mytests{
+		mytests{"", "docker-compose file: filePath.yml is empty"},
+		mytests{"- a\n- b\n", "docker-compose file: filePath.yml:1: expected a mapping at the top level, got: sequence"},
+		mytests{"services:\n  default:\n    image: alpine: 3.19\n",
+			"docker-compose file: filePath.yml:3: invalid YAML: mapping values are not allowed in this context"},
+		mytests{"services:\n  - default\n", "docker-compose file: filePath.yml:1: services should be a mapping, got: sequence"},
+		mytests{"version:\n  - 3\n", "docker-compose file: filePath.yml:2: version should be a string, got: sequence"},
+		mytests{"services:\n  default:\n    init: true\n  default:\n    init: false\n",
+			"docker-compose file: filePath.yml:4: service default is defined more than once, first at line: 2"},
+	}
+	for _, v := range mytestsObj {
+		_, err := parseDCFile(v.content, "filePath.yml")
+		assert.NotNil(t, err, v.content)
+		if err != nil {
+			assert.Equal(t, v.expectedErrMsg, err.Error(), v.content)
+		}
+	}
+}
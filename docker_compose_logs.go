@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The colours of the services prefixes, the same as docker-compose uses
+var dcServiceColors = []string{"\033[36m", "\033[33m", "\033[32m", "\033[35m", "\033[34m",
+	"\033[96m", "\033[93m", "\033[92m", "\033[95m", "\033[94m"}
+
+// ContainersLogsPrinter prints the lines of the containers logs, each prefixed with a colour-coded service name,
+// e.g. "db    | ready". It is shared between the goroutines following the logs, so that the lines are
+// never interleaved.
+type ContainersLogsPrinter struct {
+	Writer  io.Writer
+	mutex   *sync.Mutex
+	width   int
+	colors  map[string]string
+	lineEnd string
+}
+
+func NewContainersLogsPrinter(writer io.Writer) *ContainersLogsPrinter {
+	return &ContainersLogsPrinter{
+		Writer:  writer,
+		mutex:   &sync.Mutex{},
+		colors:  make(map[string]string),
+		lineEnd: "\n",
+	}
+}
+
+// Sets the services, which logs will be printed, so that their prefixes are aligned and have different colours.
+// When the default container is attached to a terminal, docker-compose puts the terminal into the raw mode,
+// so the lines must end with "\r\n" (rawTerminal).
+func (p *ContainersLogsPrinter) SetServices(services []string, rawTerminal bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.width = 0
+	for i, service := range services {
+		if len(service) > p.width {
+			p.width = len(service)
+		}
+		p.colors[service] = dcServiceColors[i%len(dcServiceColors)]
+	}
+	p.lineEnd = "\n"
+	if rawTerminal {
+		p.lineEnd = "\r\n"
+	}
+}
+
+func (p *ContainersLogsPrinter) PrintLine(service string, line string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	prefix := fmt.Sprintf("%-*s |", p.width, service)
+	if color, exists := p.colors[service]; exists {
+		prefix = color + prefix + "\033[0m"
+	}
+	fmt.Fprintf(p.Writer, "%s %s%s", prefix, line, p.lineEnd)
+}
+
+type timestampedLogLine struct {
+	time      time.Time
+	timestamp string
+	stream    string
+	text      string
+}
+
+func parseTimestampedLogLines(output string, stream string) []timestampedLogLine {
+	lines := make([]timestampedLogLine, 0)
+	var previous time.Time
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		logLine := timestampedLogLine{time: previous, stream: stream, text: line}
+		fields := strings.SplitN(line, " ", 2)
+		if parsed, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+			logLine.time = parsed
+			logLine.timestamp = fields[0]
+			logLine.text = ""
+			if len(fields) == 2 {
+				logLine.text = fields[1]
+			}
+			previous = parsed
+		}
+		lines = append(lines, logLine)
+	}
+	return lines
+}
+
+// Merges the stdout and stderr of the command: "docker logs --timestamps", so that the lines are ordered
+// by their timestamps. Each line is marked with its stream, e.g.
+// "2024-02-03T21:03:46.123456789Z stderr | error: connection refused".
+// A line without a timestamp keeps the time of the previous line of the same stream.
+func mergeTimestampedLogs(stdout string, stderr string) string {
+	lines := append(parseTimestampedLogLines(stdout, "stdout"), parseTimestampedLogLines(stderr, "stderr")...)
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].time.Before(lines[j].time)
+	})
+	var merged strings.Builder
+	for _, line := range lines {
+		if line.timestamp != "" {
+			merged.WriteString(line.timestamp + " ")
+		}
+		merged.WriteString(fmt.Sprintf("%s | %s\n", line.stream, line.text))
+	}
+	return merged.String()
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_ContainersLogsPrinter_PrintLine(t *testing.T) {
+	type mytestStruct struct {
+		service     string
+		line        string
+		rawTerminal bool
+		expOutput   string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{service: "db", line: "ready", expOutput: "\033[36mdb    |\033[0m ready\n"},
+		mytestStruct{service: "redis", line: "ready", expOutput: "\033[33mredis |\033[0m ready\n"},
+		mytestStruct{service: "db", line: "ready", rawTerminal: true, expOutput: "\033[36mdb    |\033[0m ready\r\n"},
+		// a service which was not set
+		mytestStruct{service: "other", line: "ready", expOutput: "other | ready\n"},
+	}
+	for _, v := range mytests {
+		var output bytes.Buffer
+		printer := NewContainersLogsPrinter(&output)
+		printer.SetServices([]string{"db", "redis"}, v.rawTerminal)
+		printer.PrintLine(v.service, v.line)
+		assert.Equal(t, v.expOutput, output.String(), v.service)
+	}
+}
+
+func Test_mergeTimestampedLogs(t *testing.T) {
+	type mytestStruct struct {
+		stdout    string
+		stderr    string
+		expOutput string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{stdout: "", stderr: "", expOutput: ""},
+		mytestStruct{
+			stdout: "2024-02-03T21:03:46.000000001Z starting\n2024-02-03T21:03:48.000000000Z started\n",
+			stderr: "2024-02-03T21:03:47.000000000Z warning: no config\n",
+			expOutput: "2024-02-03T21:03:46.000000001Z stdout | starting\n" +
+				"2024-02-03T21:03:47.000000000Z stderr | warning: no config\n" +
+				"2024-02-03T21:03:48.000000000Z stdout | started\n"},
+		// the same timestamps keep stdout first
+		mytestStruct{
+			stdout: "2024-02-03T21:03:46.000000000Z out\n",
+			stderr: "2024-02-03T21:03:46.000000000Z err\n",
+			expOutput: "2024-02-03T21:03:46.000000000Z stdout | out\n" +
+				"2024-02-03T21:03:46.000000000Z stderr | err\n"},
+		// a line without a timestamp keeps the time of the previous line
+		mytestStruct{
+			stdout: "2024-02-03T21:03:48.000000000Z late\n",
+			stderr: "2024-02-03T21:03:46.000000000Z error:\n  at main.go:7\n",
+			expOutput: "2024-02-03T21:03:46.000000000Z stderr | error:\n" +
+				"stderr |   at main.go:7\n" +
+				"2024-02-03T21:03:48.000000000Z stdout | late\n"},
+	}
+	for _, v := range mytests {
+		assert.Equal(t, v.expOutput, mergeTimestampedLogs(v.stdout, v.stderr), v.stdout)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+)
+
+// DCOverrideFile is the docker-compose file generated by dojo (<docker-compose file>.dojo). It is passed
+// to docker-compose after the user's docker-compose file, so docker-compose merges both files:
+// the image is replaced, while the volumes, env_file and labels are merged with the ones set by the user.
+type DCOverrideFile struct {
+	// Version must be the same as in the user's docker-compose file, empty if not set there
+	Version  string                        `yaml:"version,omitempty"`
+	Services map[string]*DCOverrideService `yaml:"services"`
+}
+
+type DCOverrideService struct {
+	Image   string            `yaml:"image,omitempty"`
+	Volumes []string          `yaml:"volumes,omitempty"`
+	EnvFile []string          `yaml:"env_file,omitempty"`
+	Labels  map[string]string `yaml:"labels,omitempty"`
+}
+
+func NewDCOverrideFile(version string) *DCOverrideFile {
+	return &DCOverrideFile{
+		Version:  version,
+		Services: make(map[string]*DCOverrideService),
+	}
+}
+
+// Returns the service with the given name, adds it first if it does not exist yet.
+func (o *DCOverrideFile) Service(name string) *DCOverrideService {
+	service, exists := o.Services[name]
+	if !exists {
+		service = &DCOverrideService{}
+		o.Services[name] = service
+	}
+	return service
+}
+
+// Returns the YAML contents of the file.
+func (o *DCOverrideFile) String() string {
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	err := encoder.Encode(o)
+	if err != nil {
+		panic(fmt.Errorf("Unexpected error when generating docker-compose file: %s", err))
+	}
+	encoder.Close()
+	return buffer.String()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+	"testing"
+)
+
+func TestDCOverrideFile_String(t *testing.T) {
+	overrideFile := NewDCOverrideFile("3.10")
+	overrideFile.Service("default").Image = "img:1.2.3"
+	overrideFile.Service("default").Volumes = []string{"/tmp/bla:/dojo/work"}
+	overrideFile.Service("abc").EnvFile = []string{"/tmp/env-file.txt"}
+	assert.Equal(t, `version: "3.10"
+services:
+  abc:
+    env_file:
+      - /tmp/env-file.txt
+  default:
+    image: img:1.2.3
+    volumes:
+      - /tmp/bla:/dojo/work
+`, overrideFile.String())
+}
+
+func TestDCOverrideFile_String_NoVersion(t *testing.T) {
+	overrideFile := NewDCOverrideFile("")
+	overrideFile.Service("default").Image = "img:1.2.3"
+	assert.Equal(t, "services:\n  default:\n    image: img:1.2.3\n", overrideFile.String())
+}
+
+// Service names and values which have a special meaning in YAML must be quoted
+func TestDCOverrideFile_String_Quoting(t *testing.T) {
+	overrideFile := NewDCOverrideFile("2")
+	overrideFile.Service("on").EnvFile = []string{"/tmp/my env: file"}
+	overrideFile.Service("123").Labels = map[string]string{"dojo.test": "true"}
+	contents := overrideFile.String()
+	assert.Contains(t, contents, "\"on\":")
+	assert.Contains(t, contents, "\"123\":")
+	var parsed DCOverrideFile
+	err := yaml.Unmarshal([]byte(contents), &parsed)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", parsed.Version)
+	assert.Equal(t, []string{"/tmp/my env: file"}, parsed.Services["on"].EnvFile)
+	assert.Equal(t, map[string]string{"dojo.test": "true"}, parsed.Services["123"].Labels)
+}
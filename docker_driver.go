@@ -10,6 +10,7 @@ type DockerDriver struct {
 	ShellService ShellServiceInterface
 	FileService  FileServiceInterface
 	Logger *Logger
+	ReportContainers *RunReportContainers
 }
 
 func NewDockerDriver(shellService ShellServiceInterface, fs FileServiceInterface, logger *Logger) DockerDriver {
@@ -26,6 +27,7 @@ func NewDockerDriver(shellService ShellServiceInterface, fs FileServiceInterface
 		ShellService: shellService,
 		FileService: fs,
 		Logger: logger,
+		ReportContainers: NewRunReportContainers(),
 	}
 }
 
@@ -67,110 +69,32 @@ func (d DockerDriver) ConstructDockerRunCmd(config Config, envFilePath string, e
 }
 
 func (d DockerDriver) PrintVersion() {
-	version_cmd := "docker --version"
-	stdout, stderr, exitStatus, _ := d.ShellService.RunGetOutput(version_cmd, true)
-	if exitStatus != 0 {
-		cmdInfo := cmdInfoToString(version_cmd, stdout, stderr, exitStatus)
-		d.Logger.Log("debug", cmdInfo)
-	} else {
-		d.Logger.Log("info", stdout)
-	}
+	printBinaryVersion(d.ShellService, d.Logger, "docker")
 }
 
 func (d DockerDriver) HandleRun(mergedConfig Config, runID string, envService EnvServiceInterface) int {
-	warnGeneral(d.FileService, mergedConfig, envService, d.Logger)
-	envFile, envFileMultiLine, envFileBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
-	saveEnvToFile(d.FileService, envFile, envFileMultiLine, envFileBashFunctions,
-		mergedConfig.BlacklistVariables, envService.GetVariables())
-
-	cmd := d.ConstructDockerRunCmd(mergedConfig, envFile, envFileMultiLine, envFileBashFunctions, runID)
-	d.Logger.Log("info", green(fmt.Sprintf("docker command will be:\n %v", cmd)))
-
-	if mergedConfig.RemoveContainers != "true" {
-		// Removing docker container is impractical without additional steps here. We'd have to
-		// parse output of dojo in order to get container name. Thus, save the container name to a file.
-		currentDirectory := d.FileService.GetCurrentDir()
-		rcFile := fmt.Sprintf("%s/dojorc.txt", currentDirectory)
-		d.FileService.RemoveFile(rcFile, true)
-		d.FileService.WriteToFile(rcFile, runID, "info")
-
-		rcFile2 := fmt.Sprintf("%s/dojorc", currentDirectory)
-		d.FileService.RemoveFile(rcFile2, true)
-		d.FileService.WriteToFile(rcFile2, fmt.Sprintf("DOJO_RUN_ID=%s",runID), "info")
-	}
-	exitStatus, _ := d.ShellService.RunInteractive(cmd, true)
-	d.Logger.Log("debug", fmt.Sprintf("Exit status from run command: %v", exitStatus))
-	return exitStatus
-
-	// do not clean now, container may be being stopped in other goroutines
+	return runContainer(d.ShellService, d.FileService, d.Logger, d.ReportContainers, "docker", d.ConstructDockerRunCmd,
+		mergedConfig, runID, envService)
 }
 
 func (d DockerDriver) HandlePull(mergedConfig Config) int {
-	cmd := fmt.Sprintf("docker pull %s", mergedConfig.DockerImage)
-	d.Logger.Log("info", green(fmt.Sprintf("docker pull command will be:\n %v", cmd)))
-	exitStatus, _ := d.ShellService.RunInteractive(cmd, false)
-	d.Logger.Log("debug", fmt.Sprintf("Exit status from pull command: %v", exitStatus))
-	return exitStatus
+	return pullOrBuildImage(d.ShellService, d.Logger, "docker", mergedConfig)
 }
 
-// Stop the container if it is not removed.
 func (d DockerDriver) HandleSignal(mergedConfig Config, runID string) int {
-	d.Logger.Log("info", "Stopping on signal")
-	containerInfo, err := getContainerInfo(d.ShellService, runID)
-	if err != nil {
-		d.Logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
-		panic(err)
-	}
-	if !containerInfo.Exists {
-		d.Logger.Log("info", "Container already removed or not created at all, will not react on this signal")
-		return 0
-	}
-	cmd := fmt.Sprintf("docker stop %s", runID)
-	d.Logger.Log("info", fmt.Sprintf("Stopping container with command: \n%v", cmd))
-	exitStatus, _ := d.ShellService.RunInteractive(cmd, false)
-	d.Logger.Log("debug", fmt.Sprintf("Exit status from command: %s, %v", cmd, exitStatus))
-	d.Logger.Log("info", "Stopping on signal finished")
-	return exitStatus
+	return stopContainer(d.ShellService, d.Logger, "docker", getContainerInfo, runID)
 }
 
-// Kill the container if it is not removed.
 func (d DockerDriver) HandleMultipleSignal(mergedConfig Config, runID string) int {
-	d.Logger.Log("info", "Stopping on multiple signals")
-	containerInfo, err := getContainerInfo(d.ShellService, runID)
-	if err != nil {
-		d.Logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
-		panic(err)
-	}
-	if !containerInfo.Exists {
-		d.Logger.Log("info", "Container already removed or not created at all, will not react on this signal")
-		return 0
-	}
-	cmd := fmt.Sprintf("docker kill %s", runID)
-	d.Logger.Log("info", fmt.Sprintf("Stopping container with command: \n%v", cmd))
-	stdout, stderr, exitStatus, _ := d.ShellService.RunGetOutput(cmd, true)
-	if exitStatus != 0 {
-		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
-		d.Logger.Log("debug", cmdInfo)
-	} else {
-		d.Logger.Log("debug", "docker kill was successful")
-	}
-	d.Logger.Log("info", "Stopping on multiple signals finished")
-	return exitStatus
+	return killContainer(d.ShellService, d.Logger, "docker", getContainerInfo, runID)
 }
 
-func (d DockerDriver) CleanAfterRun(mergedConfig Config, runID string) int {
-	if mergedConfig.RemoveContainers == "true" {
-		d.Logger.Log("debug", "Cleaning, because RemoveContainers is set to true")
-		envFile, envFileMultiLine, envFilePathBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
-		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFile)
-		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFileMultiLine)
-		d.FileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFilePathBashFunctions)
-
-		// no need to remove the container, if it was started with "docker run --rm", it was already removed
+func (d DockerDriver) FillRunReport(mergedConfig Config, report *RunReport) {
+	report.Image = mergedConfig.DockerImage
+	report.ImageDigest = getImageDigest(d.ShellService, "docker", mergedConfig.DockerImage)
+	report.Containers = d.ReportContainers.Get()
+}
 
-		return 0
-	} else {
-		d.Logger.Log("debug", "Not cleaning, because RemoveContainers is not set to true")
-		return 0
-	}
+func (d DockerDriver) CleanAfterRun(mergedConfig Config, runID string) int {
+	return cleanAfterContainerRun(d.FileService, d.Logger, mergedConfig, runID)
 }
@@ -148,6 +148,41 @@ three`)
 	assert.Equal(t, "/tmp/dojo-environment-bash-functions-testrunid", fs.FilesRemovals[0])
 }
 
+func TestDockerDriver_HandleRun_Unit_PullPolicyNever(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker image inspect --format '{{.Id}}' img:1.2.3"] = []string{"", "Error: No such image: img:1.2.3", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	d := NewDockerDriver(shellS, fs, logger)
+	config := getTestConfig()
+	config.PullPolicy = "never"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, imageFailureExitStatus, es)
+	assert.Equal(t, []string{"Pretending to run: docker image inspect --format '{{.Id}}' img:1.2.3"}, shellS.CommandsRun)
+}
+
+func TestDockerDriver_HandleRun_Unit_BuildImage(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker image inspect --format '{{.Id}}' dojo-build-image:0123456789ab"] =
+		[]string{"", "Error: No such image: dojo-build-image:0123456789ab", "1"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	d := NewDockerDriver(shellS, fs, logger)
+	config := getTestConfig()
+	config.DockerImage = "dojo-build-image:0123456789ab"
+	config.DockerBuildContext = "/tmp/image"
+	config.PullPolicy = "always"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, 3, len(shellS.CommandsRun))
+	assert.Equal(t, "Pretending to run: docker image inspect --format '{{.Id}}' dojo-build-image:0123456789ab", shellS.CommandsRun[0])
+	assert.Equal(t, "Pretending to run: docker build -t dojo-build-image:0123456789ab -f /tmp/image/Dockerfile /tmp/image", shellS.CommandsRun[1])
+	assert.Contains(t, shellS.CommandsRun[2], "Pretending to run: docker run")
+	assert.Contains(t, shellS.CommandsRun[2], "dojo-build-image:0123456789ab")
+}
+
 func fileExists(filePath string) bool {
 	_, err := os.Lstat(filePath)
 	if err != nil {
@@ -192,4 +227,22 @@ func TestDockerDriver_HandlePull_Unit(t *testing.T) {
 	config := getTestConfig()
 	es := d.HandlePull(config)
 	assert.Equal(t, 0, es)
-}
\ No newline at end of file
+}
+func TestDockerDriver_FillRunReport(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["docker image inspect --format '{{range .RepoDigests}}{{.}} {{end}}' img:1.2.3"] =
+		[]string{"img@sha256:abcd \n", "", "0"}
+	d := NewDockerDriver(NewMockedShellServiceNotInteractive2(logger, commandsReactions), NewMockedFileService(logger), logger)
+	config := getTestConfig()
+	config.RunCommand = ""
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+
+	report := RunReport{}
+	d.FillRunReport(config, &report)
+	assert.Equal(t, "img:1.2.3", report.Image)
+	assert.Equal(t, "img@sha256:abcd", report.ImageDigest)
+	assert.Equal(t, []RunReportContainer{
+		RunReportContainer{Name: "testrunid", Default: true, Status: "exited", ExitCode: "0"}}, report.Containers)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dockerignorePattern is a line of a .dockerignore file
+type dockerignorePattern struct {
+	regexp *regexp.Regexp
+	// true if the pattern starts with "!": the matching files are not ignored
+	exclusion bool
+}
+
+// DockerIgnore decides which files of the build context are not sent to docker, like docker build does
+// with the .dockerignore file in the root of the build context
+type DockerIgnore struct {
+	patterns      []dockerignorePattern
+	hasExclusions bool
+}
+
+// Reads the .dockerignore file in the build context. If there is no such file, nothing is ignored.
+func readDockerIgnore(contextDir string) (DockerIgnore, error) {
+	dockerIgnore := DockerIgnore{}
+	file, err := os.Open(filepath.Join(contextDir, ".dockerignore"))
+	if os.IsNotExist(err) {
+		return dockerIgnore, nil
+	}
+	if err != nil {
+		return dockerIgnore, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		err = dockerIgnore.addPattern(scanner.Text())
+		if err != nil {
+			return dockerIgnore, fmt.Errorf("%s:%v: %s", file.Name(), lineNumber, err)
+		}
+	}
+	return dockerIgnore, scanner.Err()
+}
+
+// Adds a line of a .dockerignore file. It is an error if the pattern is invalid, e.g. "[z-a]".
+func (d *DockerIgnore) addPattern(line string) error {
+	pattern := strings.TrimSpace(line)
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+	exclusion := strings.HasPrefix(pattern, "!")
+	if exclusion {
+		pattern = strings.TrimSpace(pattern[1:])
+	}
+	pattern = strings.TrimPrefix(filepath.ToSlash(filepath.Clean(pattern)), "/")
+	patternRegexp, err := regexp.Compile(dockerignorePatternToRegexp(pattern))
+	if err != nil {
+		return fmt.Errorf("invalid pattern: %s: %s", pattern, err)
+	}
+	d.patterns = append(d.patterns, dockerignorePattern{
+		regexp:    patternRegexp,
+		exclusion: exclusion,
+	})
+	d.hasExclusions = d.hasExclusions || exclusion
+	return nil
+}
+
+// Translates the pattern into a regexp: "**" matches any number of directories, "*" any characters but "/",
+// "?" one character but "/", "[...]" a character class and "\" escapes the next character
+func dockerignorePatternToRegexp(pattern string) string {
+	var result strings.Builder
+	result.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch char := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			result.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			result.WriteString(".*")
+			i++
+		case char == '*':
+			result.WriteString("[^/]*")
+		case char == '?':
+			result.WriteString("[^/]")
+		case char == '[' && strings.Contains(pattern[i:], "]"):
+			end := i + strings.Index(pattern[i:], "]")
+			class := pattern[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			result.WriteString("[" + class + "]")
+			i = end
+		case char == '\\' && i+1 < len(pattern):
+			result.WriteString(regexp.QuoteMeta(string(pattern[i+1])))
+			i++
+		default:
+			result.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	result.WriteString("$")
+	return result.String()
+}
+
+// Returns true if the file (a path relative to the build context, with "/") is ignored. The last pattern,
+// which matches the file or any of its parent directories, decides.
+func (d DockerIgnore) IsIgnored(path string) bool {
+	ignored := false
+	for _, pattern := range d.patterns {
+		if pattern.matchesPathOrParent(path) {
+			ignored = !pattern.exclusion
+		}
+	}
+	return ignored
+}
+
+// Returns true if the whole directory can be skipped: it is ignored and no exclusion may bring back its files
+func (d DockerIgnore) IsDirIgnored(path string) bool {
+	return !d.hasExclusions && d.IsIgnored(path)
+}
+
+func (p dockerignorePattern) matchesPathOrParent(path string) bool {
+	for {
+		if p.regexp.MatchString(path) {
+			return true
+		}
+		slash := strings.LastIndex(path, "/")
+		if slash == -1 {
+			return false
+		}
+		path = path[:slash]
+	}
+}
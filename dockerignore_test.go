@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_DockerIgnore_IsIgnored(t *testing.T) {
+	type mytestStruct struct {
+		path            string
+		expectedIgnored bool
+	}
+	dockerIgnore := DockerIgnore{}
+	for _, line := range []string{
+		"# a comment", "", ".git", "/node_modules", "*.log", "!important.log", "docs/**/*.md", "build?", "tmp[0-9]",
+	} {
+		assert.Nil(t, dockerIgnore.addPattern(line), line)
+	}
+	mytests := []mytestStruct{
+		mytestStruct{path: ".git", expectedIgnored: true},
+		mytestStruct{path: ".git/config", expectedIgnored: true},
+		mytestStruct{path: "node_modules/a/index.js", expectedIgnored: true},
+		mytestStruct{path: "src/node_modules/index.js", expectedIgnored: false},
+		mytestStruct{path: "error.log", expectedIgnored: true},
+		mytestStruct{path: "logs/error.log", expectedIgnored: false},
+		mytestStruct{path: "important.log", expectedIgnored: false},
+		mytestStruct{path: "docs/README.md", expectedIgnored: true},
+		mytestStruct{path: "docs/api/v1/index.md", expectedIgnored: true},
+		mytestStruct{path: "docs/index.html", expectedIgnored: false},
+		mytestStruct{path: "build1/out", expectedIgnored: true},
+		mytestStruct{path: "build/out", expectedIgnored: false},
+		mytestStruct{path: "tmp1", expectedIgnored: true},
+		mytestStruct{path: "tmpa", expectedIgnored: false},
+		mytestStruct{path: "Dockerfile", expectedIgnored: false},
+	}
+	for _, v := range mytests {
+		assert.Equal(t, v.expectedIgnored, dockerIgnore.IsIgnored(v.path), v.path)
+	}
+	// an exclusion may bring back a file from an ignored directory
+	assert.False(t, dockerIgnore.IsDirIgnored(".git"))
+	dockerIgnore = DockerIgnore{}
+	assert.Nil(t, dockerIgnore.addPattern(".git"))
+	assert.True(t, dockerIgnore.IsDirIgnored(".git"))
+}
+
+func Test_readDockerIgnore(t *testing.T) {
+	type mytestStruct struct {
+		contents    string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{contents: "# comment\n*.log\n!important.log\n"},
+		mytestStruct{contents: "*.log\ntmp[z-a]\n",
+			expErrorMsg: ".dockerignore:2: invalid pattern: tmp[z-a]: error parsing regexp: invalid character class range: `z-a`"},
+		mytestStruct{contents: "[]\n",
+			expErrorMsg: ".dockerignore:1: invalid pattern: []: error parsing regexp: missing closing ]: `[]$`"},
+	}
+	for _, v := range mytests {
+		dir := writeConfigFiles(t, map[string]string{".dockerignore": v.contents})
+		dockerIgnore, err := readDockerIgnore(dir)
+		if v.expErrorMsg != "" {
+			assert.Equal(t, filepath.Join(dir, v.expErrorMsg), err.Error(), v.contents)
+		} else {
+			assert.Nil(t, err, v.contents)
+			assert.True(t, dockerIgnore.IsIgnored("error.log"))
+		}
+		os.RemoveAll(dir)
+	}
+
+	// no .dockerignore file: nothing is ignored
+	dir := writeConfigFiles(t, map[string]string{})
+	defer os.RemoveAll(dir)
+	dockerIgnore, err := readDockerIgnore(dir)
+	assert.Nil(t, err)
+	assert.False(t, dockerIgnore.IsIgnored("error.log"))
+}
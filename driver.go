@@ -1,6 +1,9 @@
 package main
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type DojoDriverInterface interface {
 	PrintVersion()
@@ -9,6 +12,8 @@ type DojoDriverInterface interface {
 	HandlePull(mergedConfig Config) int
 	HandleSignal(mergedConfig Config, runID string) int
 	HandleMultipleSignal(mergedConfig Config, runID string) int
+	// sets the information about the image and the containers of the run
+	FillRunReport(mergedConfig Config, report *RunReport)
 }
 
 func warnGeneral(fileService FileServiceInterface, config Config, envService EnvServiceInterface, logger *Logger) {
@@ -29,3 +34,203 @@ func warnGeneral(fileService FileServiceInterface, config Config, envService Env
 		logger.Log("warn", "Current user is root, which is not recommended")
 	}
 }
+
+// Removing a container is impractical without additional steps after dojo finished. We'd have to
+// parse output of dojo in order to get the container name. Thus, save the run ID to files.
+func saveRunIDToDojoRC(fileService FileServiceInterface, runID string) {
+	currentDirectory := fileService.GetCurrentDir()
+	rcFile := fmt.Sprintf("%s/dojorc.txt", currentDirectory)
+	fileService.RemoveFile(rcFile, true)
+	fileService.WriteToFile(rcFile, runID, "info")
+
+	rcFile2 := fmt.Sprintf("%s/dojorc", currentDirectory)
+	fileService.RemoveFile(rcFile2, true)
+	fileService.WriteToFile(rcFile2, fmt.Sprintf("DOJO_RUN_ID=%s", runID), "info")
+}
+
+// Returns the run ID saved by saveRunIDToDojoRC, so that the kept containers can be removed later.
+func readRunIDFromDojoRC(fileService FileServiceInterface) (string, error) {
+	rcFile := fmt.Sprintf("%s/dojorc.txt", fileService.GetCurrentDir())
+	if !fileService.FileExists(rcFile) {
+		return "", fmt.Errorf("%s does not exist. It is saved by dojo when RemoveContainers is set to false", rcFile)
+	}
+	runID := strings.TrimSpace(fileService.ReadFile(rcFile))
+	if runID == "" {
+		return "", fmt.Errorf("%s is empty", rcFile)
+	}
+	return runID, nil
+}
+
+func removeDojoRC(fileService FileServiceInterface) {
+	currentDirectory := fileService.GetCurrentDir()
+	fileService.RemoveFile(fmt.Sprintf("%s/dojorc.txt", currentDirectory), true)
+	fileService.RemoveFile(fmt.Sprintf("%s/dojorc", currentDirectory), true)
+}
+
+// The exit status of dojo, when the image could not be pulled or built, or it is not present locally and
+// the pull policy is: never. This way, image failures are told apart from the run command failures.
+const imageFailureExitStatus = 5
+
+// Returns the error to report, when the image is not present locally and the pull policy is: never
+func imageNotPresentError(image string) error {
+	return fmt.Errorf("Image: %s is not present locally and the pull policy is: never. "+
+		"Pull it with: dojo --action=pull or set DOJO_PULL_POLICY to: missing or always", image)
+}
+
+// Builds the image, if it is built from the build context, or pulls it according to the pull policy.
+// The binary is: docker or podman.
+func prepareImage(shellService ShellServiceInterface, logger *Logger, binary string, config Config) error {
+	if config.DockerBuildContext != "" {
+		return buildImageIfMissing(shellService, logger, binary, config)
+	}
+	return pullImageWithPolicy(shellService, logger, binary, config.DockerImage, config.PullPolicy)
+}
+
+// Pulls the image according to the pull policy: always - before each run, missing - only if the image is not
+// present locally, never - returns an error if the image is not present locally. The binary is: docker or podman.
+func pullImageWithPolicy(shellService ShellServiceInterface, logger *Logger, binary string, image string, policy string) error {
+	if policy != "always" {
+		cmd := fmt.Sprintf("%s image inspect --format '{{.Id}}' %s", binary, image)
+		_, _, exitStatus, _ := shellService.RunGetOutput(cmd, true)
+		if exitStatus == 0 {
+			logger.Log("debug", fmt.Sprintf("Image: %s is present locally, not pulling it (pull policy: %s)", image, policy))
+			return nil
+		}
+		if policy == "never" {
+			return imageNotPresentError(image)
+		}
+	}
+	cmd := fmt.Sprintf("%s pull %s", binary, image)
+	logger.Log("info", fmt.Sprintf("Pulling image (pull policy: %s) with command: \n%v", policy, cmd))
+	exitStatus, _ := shellService.RunInteractive(cmd, false)
+	if exitStatus != 0 {
+		return fmt.Errorf("Pulling image: %s failed, exit status: %v, command: %s", image, exitStatus, cmd)
+	}
+	return nil
+}
+
+// Returns the information about the container: getContainerInfo for docker, getPodmanContainerInfo for podman
+type containerInfoFunc func(shellService ShellServiceInterface, containerNameOrID string) (*ContainerInfo, error)
+
+// Returns the command, which runs the container, e.g. DockerDriver.ConstructDockerRunCmd
+type runCmdFunc func(config Config, envFilePath string, envFileMultiLine string, envFileBashFunctions string, containerName string) string
+
+// The functions below implement the drivers, which run a single container with a CLI: docker or podman.
+// The binary is: docker or podman.
+
+func printBinaryVersion(shellService ShellServiceInterface, logger *Logger, binary string) {
+	versionCmd := fmt.Sprintf("%s --version", binary)
+	stdout, stderr, exitStatus, _ := shellService.RunGetOutput(versionCmd, true)
+	if exitStatus != 0 {
+		cmdInfo := cmdInfoToString(versionCmd, stdout, stderr, exitStatus)
+		logger.Log("debug", cmdInfo)
+	} else {
+		logger.Log("info", stdout)
+	}
+}
+
+func runContainer(shellService ShellServiceInterface, fileService FileServiceInterface, logger *Logger,
+	reportContainers *RunReportContainers, binary string, constructRunCmd runCmdFunc,
+	mergedConfig Config, runID string, envService EnvServiceInterface) int {
+	warnGeneral(fileService, mergedConfig, envService, logger)
+	envFile, envFileMultiLine, envFileBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
+	saveEnvToFile(fileService, envFile, envFileMultiLine, envFileBashFunctions,
+		mergedConfig.BlacklistVariables, envService.GetVariables())
+
+	cmd := constructRunCmd(mergedConfig, envFile, envFileMultiLine, envFileBashFunctions, runID)
+	logger.Log("info", green(fmt.Sprintf("%s command will be:\n %v", binary, cmd)))
+
+	err := prepareImage(shellService, logger, binary, mergedConfig)
+	if err != nil {
+		logger.Log("error", err.Error())
+		return imageFailureExitStatus
+	}
+	if mergedConfig.RemoveContainers != "true" {
+		saveRunIDToDojoRC(fileService, runID)
+	}
+	exitStatus, _ := shellService.RunInteractive(cmd, true)
+	logger.Log("debug", fmt.Sprintf("Exit status from run command: %v", exitStatus))
+	reportContainers.Add(RunReportContainer{Name: runID, Default: true, Status: "exited", ExitCode: fmt.Sprint(exitStatus)})
+	return exitStatus
+
+	// do not clean now, container may be being stopped in other goroutines
+}
+
+func pullOrBuildImage(shellService ShellServiceInterface, logger *Logger, binary string, mergedConfig Config) int {
+	if mergedConfig.DockerBuildContext != "" {
+		// there is nothing to pull, the image is built
+		err := buildImageIfMissing(shellService, logger, binary, mergedConfig)
+		if err != nil {
+			logger.Log("error", err.Error())
+			return 1
+		}
+		return 0
+	}
+	cmd := fmt.Sprintf("%s pull %s", binary, mergedConfig.DockerImage)
+	logger.Log("info", green(fmt.Sprintf("%s pull command will be:\n %v", binary, cmd)))
+	exitStatus, _ := shellService.RunInteractive(cmd, false)
+	logger.Log("debug", fmt.Sprintf("Exit status from pull command: %v", exitStatus))
+	return exitStatus
+}
+
+// Stop the container if it is not removed.
+func stopContainer(shellService ShellServiceInterface, logger *Logger, binary string, getInfo containerInfoFunc, runID string) int {
+	logger.Log("info", "Stopping on signal")
+	containerInfo, err := getInfo(shellService, runID)
+	if err != nil {
+		logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
+		panic(err)
+	}
+	if !containerInfo.Exists {
+		logger.Log("info", "Container already removed or not created at all, will not react on this signal")
+		return 0
+	}
+	cmd := fmt.Sprintf("%s stop %s", binary, runID)
+	logger.Log("info", fmt.Sprintf("Stopping container with command: \n%v", cmd))
+	exitStatus, _ := shellService.RunInteractive(cmd, false)
+	logger.Log("debug", fmt.Sprintf("Exit status from command: %s, %v", cmd, exitStatus))
+	logger.Log("info", "Stopping on signal finished")
+	return exitStatus
+}
+
+// Kill the container if it is not removed.
+func killContainer(shellService ShellServiceInterface, logger *Logger, binary string, getInfo containerInfoFunc, runID string) int {
+	logger.Log("info", "Stopping on multiple signals")
+	containerInfo, err := getInfo(shellService, runID)
+	if err != nil {
+		logger.Log("info", fmt.Sprintf("Not cleaning. Unexpected error.\n%s", err))
+		panic(err)
+	}
+	if !containerInfo.Exists {
+		logger.Log("info", "Container already removed or not created at all, will not react on this signal")
+		return 0
+	}
+	cmd := fmt.Sprintf("%s kill %s", binary, runID)
+	logger.Log("info", fmt.Sprintf("Stopping container with command: \n%v", cmd))
+	stdout, stderr, exitStatus, _ := shellService.RunGetOutput(cmd, true)
+	if exitStatus != 0 {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		logger.Log("debug", cmdInfo)
+	} else {
+		logger.Log("debug", fmt.Sprintf("%s kill was successful", binary))
+	}
+	logger.Log("info", "Stopping on multiple signals finished")
+	return exitStatus
+}
+
+func cleanAfterContainerRun(fileService FileServiceInterface, logger *Logger, mergedConfig Config, runID string) int {
+	if mergedConfig.RemoveContainers == "true" {
+		logger.Log("debug", "Cleaning, because RemoveContainers is set to true")
+		envFile, envFileMultiLine, envFilePathBashFunctions := getEnvFilePaths(runID, mergedConfig.Test)
+		fileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFile)
+		fileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFileMultiLine)
+		fileService.RemoveGeneratedFile(mergedConfig.RemoveContainers, envFilePathBashFunctions)
+
+		// no need to remove the container, if it was started with "docker run --rm" or "podman run --rm", it was already removed
+
+		return 0
+	} else {
+		logger.Log("debug", "Not cleaning, because RemoveContainers is not set to true")
+		return 0
+	}
+}
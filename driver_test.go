@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func Test_readRunIDFromDojoRC(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	saveRunIDToDojoRC(fs, "dojo-myproject-2025-01-01")
+
+	runID, err := readRunIDFromDojoRC(fs)
+	assert.Nil(t, err)
+	assert.Equal(t, "dojo-myproject-2025-01-01", runID)
+
+	removeDojoRC(fs)
+	assert.Contains(t, fs.FilesRemovals, "/tmp//dojorc.txt")
+	assert.Contains(t, fs.FilesRemovals, "/tmp//dojorc")
+}
+
+func Test_readRunIDFromDojoRC_empty(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+
+	_, err := readRunIDFromDojoRC(fs)
+	assert.Equal(t, "/tmp//dojorc.txt is empty", err.Error())
+}
+
+func Test_pullImageWithPolicy(t *testing.T) {
+	type mytestStruct struct {
+		policy        string
+		imagePresent  bool
+		expectedCmds  []string
+		expectedError string
+	}
+	inspectCmd := "docker image inspect --format '{{.Id}}' img:1.2.3"
+	pullCmd := "docker pull img:1.2.3"
+	mytests := []mytestStruct{
+		{policy: "always", imagePresent: true, expectedCmds: []string{pullCmd}},
+		{policy: "always", imagePresent: false, expectedCmds: []string{pullCmd}},
+		{policy: "missing", imagePresent: true, expectedCmds: []string{inspectCmd}},
+		{policy: "missing", imagePresent: false, expectedCmds: []string{inspectCmd, pullCmd}},
+		{policy: "never", imagePresent: true, expectedCmds: []string{inspectCmd}},
+		{policy: "never", imagePresent: false, expectedCmds: []string{inspectCmd},
+			expectedError: "Image: img:1.2.3 is not present locally and the pull policy is: never. " +
+				"Pull it with: dojo --action=pull or set DOJO_PULL_POLICY to: missing or always"},
+	}
+	for _, v := range mytests {
+		logger := NewLogger("debug")
+		commandsReactions := make(map[string]interface{}, 0)
+		if !v.imagePresent {
+			commandsReactions[inspectCmd] = []string{"", "Error: No such image: img:1.2.3", "1"}
+		}
+		shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		err := pullImageWithPolicy(shell, logger, "docker", "img:1.2.3", v.policy)
+		if v.expectedError != "" {
+			assert.Equal(t, v.expectedError, err.Error(), v.policy)
+		} else {
+			assert.Nil(t, err, v.policy)
+		}
+		expectedCmds := make([]string, 0)
+		for _, cmd := range v.expectedCmds {
+			expectedCmds = append(expectedCmds, "Pretending to run: "+cmd)
+		}
+		assert.Equal(t, expectedCmds, shell.CommandsRun, v.policy, v.imagePresent)
+	}
+}
@@ -164,13 +164,23 @@ func bashFunctionsVariablesToString(variables []EnvironmentVariable) string {
 
 func singleLineVariablesToString(variables []EnvironmentVariable) string {
 	singleLineVariablesStr := ""
+	for _, e := range singleLineVariables(variables) {
+		singleLineVariablesStr += e
+		singleLineVariablesStr += "\n"
+	}
+	return singleLineVariablesStr
+}
+
+// Returns the variables which can be set directly in a docker container (without the dojo image scripts),
+// each element is of format: VariableName=VariableValue
+func singleLineVariables(variables []EnvironmentVariable) []string {
+	singleLineVariables := make([]string, 0)
 	for _, e := range variables {
 		if !e.MultiLine && !e.BashFunctionVariable {
-			singleLineVariablesStr += e.String()
-			singleLineVariablesStr += "\n"
+			singleLineVariables = append(singleLineVariables, e.String())
 		}
 	}
-	return singleLineVariablesStr
+	return singleLineVariables
 }
 
 // This function constructs such a string for each environment variable,
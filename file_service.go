@@ -19,6 +19,8 @@ type FileServiceInterface interface {
 	RemoveGeneratedFile(removeContainers string, filePath string)
 	RemoveGeneratedFileIgnoreError(removeContainers string, filePath string, ignoreNoSuchFileError bool)
 	FileExists(filePath string) bool
+	// creates the directory and its parents, if they do not exist
+	CreateDir(dirPath string)
 }
 
 type FileService struct {
@@ -79,6 +81,17 @@ func (f FileService) FileExists(filePath string) bool {
 	return true
 }
 
+func (f FileService) CreateDir(dirPath string) {
+	if dirPath == "" {
+		panic("dirPath was empty")
+	}
+	err := os.MkdirAll(dirPath, 0755)
+	if err != nil {
+		panic(err)
+	}
+	f.Logger.Log("debug", fmt.Sprintf("Created directory: %s", dirPath))
+}
+
 func (f FileService) ReadDockerComposeFile(filePath string) string {
 	if filePath == "" {
 		panic("filePath was empty")
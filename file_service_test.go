@@ -7,6 +7,7 @@ import (
 type MockedFileService struct {
 	FilesWrittenTo map[string]string
 	FilesRemovals  []string
+	DirsCreated    []string
 	Logger *Logger
 }
 func NewMockedFileService(logger *Logger) *MockedFileService {
@@ -31,7 +32,8 @@ func (f *MockedFileService) RemoveFile(filePath string, ignoreNoSuchFileError bo
 
 func (f *MockedFileService) ReadFile(filePath string) string {
 	f.Logger.Log("debug", fmt.Sprintf("Pretending to read file %s", filePath))
-	return ""
+	// return the contents, if the file was written to before
+	return f.FilesWrittenTo[filePath]
 }
 
 func (f *MockedFileService) FileExists(filePath string) bool {
@@ -39,6 +41,11 @@ func (f *MockedFileService) FileExists(filePath string) bool {
 	return true
 }
 
+func (f *MockedFileService) CreateDir(dirPath string) {
+	f.DirsCreated = append(f.DirsCreated, dirPath)
+	f.Logger.Log("debug", fmt.Sprintf("Pretending to create directory: %s", dirPath))
+}
+
 func (f *MockedFileService) ReadDockerComposeFile(filePath string) string {
 	f.Logger.Log("debug", fmt.Sprintf("Pretending to read file %s, returning a constant string", filePath))
 	fileContents := `version: '2.2'
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The name of the Dockerfile in the build context tar, when the Dockerfile is outside the build context
+const buildContextDockerfileName = ".dojo.Dockerfile"
+
+var imageRepositoryInvalidCharsRegexp = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// Returns the Dockerfile to build the image from: DOJO_DOCKERFILE or the Dockerfile in the build context
+func getDockerfilePath(config Config) string {
+	if config.Dockerfile != "" {
+		return config.Dockerfile
+	}
+	return filepath.Join(config.DockerBuildContext, "Dockerfile")
+}
+
+// Returns the files in the build context, which are not ignored by its .dockerignore, as paths relative
+// to the context, sorted. Like with docker build, the Dockerfile and .dockerignore are never ignored.
+func listBuildContextFiles(contextDir string, dockerfile string) ([]string, error) {
+	dockerIgnore, err := readDockerIgnore(contextDir)
+	if err != nil {
+		return nil, err
+	}
+	dockerfileInContext, _ := filepath.Rel(contextDir, dockerfile)
+	files := make([]string, 0)
+	err = filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		if info.IsDir() {
+			if relPath != "." && dockerIgnore.IsDirIgnored(relPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if dockerIgnore.IsIgnored(relPath) && relPath != ".dockerignore" &&
+			relPath != filepath.ToSlash(dockerfileInContext) {
+			return nil
+		}
+		files = append(files, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Returns the sha256 of the build context: the paths, modes and contents of all its files, which are not ignored
+// (symlinks are not followed, their targets are hashed instead), and of the Dockerfile. The image is rebuilt only
+// when it changes.
+func hashBuildContext(contextDir string, dockerfile string) (string, error) {
+	files, err := listBuildContextFiles(contextDir, dockerfile)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	for _, file := range files {
+		path := filepath.Join(contextDir, file)
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%v\x00", file, info.Mode())
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			fmt.Fprintf(hash, "%s\x00", target)
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		err = hashFile(hash, path)
+		if err != nil {
+			return "", err
+		}
+	}
+	fmt.Fprint(hash, "Dockerfile\x00")
+	err = hashFile(hash, dockerfile)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(writer io.Writer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(writer, file)
+	return err
+}
+
+// Returns the tag of the image built from the build context, e.g. dojo-build-myimage:0123456789ab.
+// The tag is the beginning of the build context hash, so that the image is built again only when the context changes.
+func getBuildImageTag(config Config) (string, error) {
+	dockerfile := getDockerfilePath(config)
+	contextHash, err := hashBuildContext(config.DockerBuildContext, dockerfile)
+	if err != nil {
+		return "", fmt.Errorf("Error when hashing the build context: %s. %s", config.DockerBuildContext, err)
+	}
+	name := strings.ToLower(filepath.Base(config.DockerBuildContext))
+	name = strings.Trim(imageRepositoryInvalidCharsRegexp.ReplaceAllString(name, "-"), "-._")
+	repository := "dojo-build"
+	if name != "" {
+		repository += "-" + name
+	}
+	return fmt.Sprintf("%s:%s", repository, contextHash[:12]), nil
+}
+
+// When the image is built from the build context, sets DockerImage to the tag of the built image
+func resolveBuildImage(config *Config) error {
+	if config.DockerBuildContext == "" {
+		return nil
+	}
+	tag, err := getBuildImageTag(*config)
+	if err != nil {
+		return err
+	}
+	config.DockerImage = tag
+	return nil
+}
+
+// Builds the image from the build context with the CLI (binary is docker or podman), unless
+// an image with that tag already exists. The image is the tag set by resolveBuildImage.
+func buildImageIfMissing(shellService ShellServiceInterface, logger *Logger, binary string, config Config) error {
+	image := config.DockerImage
+	cmd := fmt.Sprintf("%s image inspect --format '{{.Id}}' %s", binary, image)
+	_, _, exitStatus, _ := shellService.RunGetOutput(cmd, true)
+	if exitStatus == 0 {
+		logger.Log("info", fmt.Sprintf("Image: %s is already built, the build context did not change", image))
+		return nil
+	}
+	cmd = fmt.Sprintf("%s build -t %s -f %s %s", binary, image, getDockerfilePath(config), config.DockerBuildContext)
+	logger.Log("info", green(fmt.Sprintf("%s build command will be:\n %v", binary, cmd)))
+	exitStatus, _ = shellService.RunInteractive(cmd, false)
+	if exitStatus != 0 {
+		return fmt.Errorf("Building image: %s failed, exit status: %v, command: %s", image, exitStatus, cmd)
+	}
+	return nil
+}
+
+// Returns the build context without the ignored files as a tar archive, to be sent to the Docker Engine API,
+// and the path of the Dockerfile in it. A Dockerfile from outside of the build context is added as: .dojo.Dockerfile.
+func tarBuildContext(contextDir string, dockerfile string) (*bytes.Buffer, string, error) {
+	files, err := listBuildContextFiles(contextDir, dockerfile)
+	if err != nil {
+		return nil, "", err
+	}
+	var buffer bytes.Buffer
+	tarWriter := tar.NewWriter(&buffer)
+	for _, file := range files {
+		err = addFileToTar(tarWriter, filepath.Join(contextDir, file), file)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	dockerfileInContext, err := filepath.Rel(contextDir, dockerfile)
+	if err != nil || strings.HasPrefix(dockerfileInContext, "..") {
+		dockerfileInContext = buildContextDockerfileName
+		err = addFileToTar(tarWriter, dockerfile, dockerfileInContext)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	err = tarWriter.Close()
+	if err != nil {
+		return nil, "", err
+	}
+	return &buffer, filepath.ToSlash(dockerfileInContext), nil
+}
+
+func addFileToTar(tarWriter *tar.Writer, path string, name string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err = os.Readlink(path)
+		if err != nil {
+			return err
+		}
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	err = tarWriter.WriteHeader(header)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
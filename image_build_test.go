@@ -0,0 +1,158 @@
+package main
+
+import (
+	"archive/tar"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func createTestBuildContext(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "dojo-build-context")
+	if err != nil {
+		t.Fatal(err)
+	}
+	contextDir := filepath.Join(dir, "My_Image")
+	os.MkdirAll(filepath.Join(contextDir, "scripts"), 0755)
+	ioutil.WriteFile(filepath.Join(contextDir, "Dockerfile"), []byte("FROM alpine:3.21\nCOPY scripts /scripts\n"), 0644)
+	ioutil.WriteFile(filepath.Join(contextDir, "scripts", "setup.sh"), []byte("echo setup\n"), 0755)
+	return contextDir
+}
+
+func Test_hashBuildContext(t *testing.T) {
+	contextDir := createTestBuildContext(t)
+	defer os.RemoveAll(filepath.Dir(contextDir))
+	dockerfile := filepath.Join(contextDir, "Dockerfile")
+
+	hash1, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^[0-9a-f]{64}$`), hash1)
+	hash2, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.Equal(t, hash1, hash2)
+
+	ioutil.WriteFile(filepath.Join(contextDir, "scripts", "setup.sh"), []byte("echo changed\n"), 0755)
+	hash3, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash1, hash3)
+
+	os.Rename(filepath.Join(contextDir, "scripts", "setup.sh"), filepath.Join(contextDir, "scripts", "setup2.sh"))
+	hash4, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash3, hash4)
+
+	// the ignored files are not hashed, but .dockerignore is
+	ioutil.WriteFile(filepath.Join(contextDir, ".dockerignore"), []byte("*.log\n"), 0644)
+	hash5, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.NotEqual(t, hash4, hash5)
+	ioutil.WriteFile(filepath.Join(contextDir, "build.log"), []byte("built\n"), 0644)
+	hash6, err := hashBuildContext(contextDir, dockerfile)
+	assert.Nil(t, err)
+	assert.Equal(t, hash5, hash6)
+
+	_, err = hashBuildContext(contextDir, filepath.Join(contextDir, "Dockerfile.missing"))
+	assert.NotNil(t, err)
+}
+
+func Test_getBuildImageTag(t *testing.T) {
+	contextDir := createTestBuildContext(t)
+	defer os.RemoveAll(filepath.Dir(contextDir))
+	config := getTestConfig()
+	config.DockerImage = ""
+	config.DockerBuildContext = contextDir
+
+	tag, err := getBuildImageTag(config)
+	assert.Nil(t, err)
+	assert.Regexp(t, regexp.MustCompile(`^dojo-build-my_image:[0-9a-f]{12}$`), tag)
+
+	err = resolveBuildImage(&config)
+	assert.Nil(t, err)
+	assert.Equal(t, tag, config.DockerImage)
+
+	config.DockerBuildContext = filepath.Join(contextDir, "missing")
+	_, err = getBuildImageTag(config)
+	assert.Contains(t, err.Error(), "Error when hashing the build context: "+config.DockerBuildContext)
+}
+
+func Test_buildImageIfMissing(t *testing.T) {
+	type mytestStruct struct {
+		imageExists  bool
+		expectedCmds []string
+	}
+	inspectCmd := "docker image inspect --format '{{.Id}}' dojo-build-image:0123456789ab"
+	buildCmd := "docker build -t dojo-build-image:0123456789ab -f /tmp/image/Dockerfile.dojo /tmp/image"
+	mytests := []mytestStruct{
+		{imageExists: true, expectedCmds: []string{inspectCmd}},
+		{imageExists: false, expectedCmds: []string{inspectCmd, buildCmd}},
+	}
+	for _, v := range mytests {
+		logger := NewLogger("debug")
+		commandsReactions := make(map[string]interface{}, 0)
+		if !v.imageExists {
+			commandsReactions[inspectCmd] = []string{"", "Error: No such image: dojo-build-image:0123456789ab", "1"}
+		}
+		shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		config := getTestConfig()
+		config.DockerImage = "dojo-build-image:0123456789ab"
+		config.DockerBuildContext = "/tmp/image"
+		config.Dockerfile = "/tmp/image/Dockerfile.dojo"
+		err := buildImageIfMissing(shell, logger, "docker", config)
+		assert.Nil(t, err)
+		expectedCmds := make([]string, 0)
+		for _, cmd := range v.expectedCmds {
+			expectedCmds = append(expectedCmds, "Pretending to run: "+cmd)
+		}
+		assert.Equal(t, expectedCmds, shell.CommandsRun, v.imageExists)
+	}
+}
+
+func readTarFiles(t *testing.T, reader io.Reader) map[string]string {
+	files := make(map[string]string)
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, _ := ioutil.ReadAll(tarReader)
+		files[header.Name] = string(contents)
+	}
+}
+
+func Test_tarBuildContext(t *testing.T) {
+	contextDir := createTestBuildContext(t)
+	defer os.RemoveAll(filepath.Dir(contextDir))
+
+	buildContext, dockerfile, err := tarBuildContext(contextDir, filepath.Join(contextDir, "Dockerfile"))
+	assert.Nil(t, err)
+	assert.Equal(t, "Dockerfile", dockerfile)
+	assert.Equal(t, map[string]string{
+		"Dockerfile":       "FROM alpine:3.21\nCOPY scripts /scripts\n",
+		"scripts/setup.sh": "echo setup\n",
+	}, readTarFiles(t, buildContext))
+
+	// the ignored files are not sent, but the Dockerfile and .dockerignore are
+	ioutil.WriteFile(filepath.Join(contextDir, ".dockerignore"), []byte("scripts\nDockerfile\n.dockerignore\n"), 0644)
+	buildContext, _, err = tarBuildContext(contextDir, filepath.Join(contextDir, "Dockerfile"))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{
+		".dockerignore": "scripts\nDockerfile\n.dockerignore\n",
+		"Dockerfile":    "FROM alpine:3.21\nCOPY scripts /scripts\n",
+	}, readTarFiles(t, buildContext))
+	os.Remove(filepath.Join(contextDir, ".dockerignore"))
+
+	outsideDockerfile := filepath.Join(filepath.Dir(contextDir), "Dockerfile.dev")
+	ioutil.WriteFile(outsideDockerfile, []byte("FROM alpine:3.21\n"), 0644)
+	buildContext, dockerfile, err = tarBuildContext(contextDir, outsideDockerfile)
+	assert.Nil(t, err)
+	assert.Equal(t, ".dojo.Dockerfile", dockerfile)
+	assert.Equal(t, "FROM alpine:3.21\n", readTarFiles(t, buildContext)[".dojo.Dockerfile"])
+}
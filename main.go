@@ -7,13 +7,20 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 )
 
 func handleConfig(logger *Logger) Config {
-	configFromCLI := getCLIConfig()
+	configFromCLI, strict := getCLIConfig()
 	configFile := configFromCLI.ConfigFile
 	if configFile == "" {
 		configFile = "Dojofile"
+		if _, err := os.Stat(configFile); os.IsNotExist(err) {
+			// the structured format is used only if there is no Dojofile
+			if _, err := os.Stat("Dojofile.yaml"); err == nil {
+				configFile = "Dojofile.yaml"
+			}
+		}
 	} else {
 		_, err := os.Lstat(configFile)
 		if err != nil {
@@ -25,17 +32,30 @@ func handleConfig(logger *Logger) Config {
 			panic(fmt.Sprintf("error when running os.Lstat(%q): %s", configFile, err))
 		}
 	}
-	configFromFile := getFileConfig(logger, configFile)
+	configFromFile, configFromProfile, err := getFileConfig(logger, configFile, configFromCLI.Profile, strict)
+	if err != nil {
+		logger.Log("error", err.Error())
+		os.Exit(1)
+	}
 	defaultConfig := getDefaultConfig(configFile)
-	mergedConfig := getMergedConfig(configFromCLI, configFromFile, defaultConfig)
-	err := verifyConfig(logger, &mergedConfig)
+	mergedConfig := getMergedConfig(configFromCLI, configFromProfile, configFromFile, defaultConfig)
+	err = verifyConfig(logger, &mergedConfig)
 	if err != nil {
 		logger.Log("error", err.Error())
 		os.Exit(1)
 	}
+	// the image is built only to be run or pulled, e.g. the build context is not hashed for the action: clean
+	if mergedConfig.Action == "run" || mergedConfig.Action == "pull" {
+		err = resolveBuildImage(&mergedConfig)
+		if err != nil {
+			logger.Log("error", err.Error())
+			os.Exit(1)
+		}
+	}
 	logger.SetLogLevel(mergedConfig.LogLevel)
 	logger.Log("debug", fmt.Sprintf("configFromCLI: %s", configFromCLI))
 	logger.Log("debug", fmt.Sprintf("configFromFile: %s", configFromFile))
+	logger.Log("debug", fmt.Sprintf("configFromProfile: %s", configFromProfile))
 	logger.Log("debug", fmt.Sprintf("mergedConfig: %s", mergedConfig))
 	logger.Log("debug", fmt.Sprint("Config verified successfully"))
 	return mergedConfig
@@ -76,6 +96,9 @@ func verifyBashInstalled(logger Logger) {
 
 func main() {
 	logger := NewLogger("debug")
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "convert" {
+		os.Exit(handleConfigConvert(logger, os.Args[3:]))
+	}
 	// This will either result in exit or return nothing.
 	// In the future, if we support more shells, we can decide here which shell to use.
 	verifyBashInstalled(*logger)
@@ -88,10 +111,27 @@ func main() {
 	var driver DojoDriverInterface
 	if mergedConfig.Driver == "docker" {
 		driver = NewDockerDriver(shellService, fileService, logger)
+	} else if mergedConfig.Driver == "podman" {
+		podmanVersion, rootless := GetPodmanInfo(shellService)
+		logger.Log("debug", fmt.Sprintf("Podman version is: %s, rootless: %v", podmanVersion, rootless))
+		driver = NewPodmanDriver(shellService, fileService, logger, podmanVersion, rootless)
+	} else if mergedConfig.Driver == "docker-api" {
+		client, err := NewDockerAPIClient(os.Getenv("DOCKER_HOST"))
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Error when connecting to Docker Engine: %s", err))
+			os.Exit(1)
+		}
+		driver = NewDockerAPIDriver(client, shellService, fileService, logger)
 	} else {
-		dcVersion := GetDockerComposeVersion(shellService)
-		logger.Log("debug", fmt.Sprintf("Docker-compose version is: %s", dcVersion))
-		driver = NewDockerComposeDriver(shellService, fileService, logger, dcVersion)
+		dcCommand, dcVersion, err := DetectDockerComposeCommand(shellService, mergedConfig.DockerComposeCommand)
+		if err != nil {
+			logger.Log("error", err.Error())
+			os.Exit(1)
+		}
+		logger.Log("debug", fmt.Sprintf("Docker-compose command is: %s, version is: %s", dcCommand, dcVersion))
+		dcDriver := NewDockerComposeDriver(shellService, fileService, logger, dcCommand, dcVersion)
+		logger.Log("debug", fmt.Sprintf("Docker-compose capabilities: %s", dcDriver.Capabilities))
+		driver = dcDriver
 	}
 
 	envService := NewEnvService()
@@ -109,12 +149,28 @@ func main() {
 		os.Exit(exitstatus)
 	}
 
+	if mergedConfig.Action == "clean" {
+		// remove the containers and files kept by a previous run with RemoveContainers set to false
+		runID, err := readRunIDFromDojoRC(fileService)
+		if err != nil {
+			logger.Log("error", err.Error())
+			os.Exit(1)
+		}
+		mergedConfig.RemoveContainers = "true"
+		exitstatus := driver.CleanAfterRun(mergedConfig, runID)
+		if exitstatus == 0 {
+			removeDojoRC(fileService)
+		}
+		os.Exit(exitstatus)
+	}
+
 	// action is run
 
 	// This variable is needed to perform cleanup on any signal.
 	// In order to avoid race conditions, let's write to this variable before
 	// using multiple goroutines. And let's never write to it again.
 	runID := getRunID(mergedConfig.Test)
+	startTime := time.Now()
 	doneChannel := make(chan int, 1)
 	signalChannel := registerSignalChannel()
 
@@ -128,12 +184,14 @@ func main() {
 	}()
 
 	signalsCaughtCount := 0
+	signalsCaught := make([]string, 0)
 	signalExitStatus := 0
 	var wg sync.WaitGroup
 	for {
 		select {
 		case signal := <-signalChannel:
 			signalsCaughtCount++
+			signalsCaught = append(signalsCaught, signal.String())
 			logger.Log("error", fmt.Sprintf("Caught signal %v: %s", signalsCaughtCount, signal.String()))
 			if signalsCaughtCount == 1 {
 				signalExitStatus = signalToExitStatus(signal)
@@ -172,6 +230,19 @@ func main() {
 			if signalExitStatus != 0 {
 				exitStatus = signalExitStatus
 			}
+			if mergedConfig.ReportJSON != "" || mergedConfig.ReportJUnit != "" {
+				report := RunReport{
+					RunID:      runID,
+					Driver:     mergedConfig.Driver,
+					Command:    mergedConfig.RunCommand,
+					StartTime:  startTime,
+					EndTime:    time.Now(),
+					Signals:    signalsCaught,
+					ExitStatus: exitStatus,
+				}
+				driver.FillRunReport(mergedConfig, &report)
+				saveRunReport(fileService, logger, mergedConfig, report)
+			}
 			// we always have to wait for the main work to be finished, so
 			// we exit only in this case
 			os.Exit(exitStatus)
@@ -4,4 +4,5 @@ package main
 
 import "syscall"
 
-const ioctlReadTermios = syscall.TIOCGETA
\ No newline at end of file
+const ioctlReadTermios = syscall.TIOCGETA
+const ioctlWriteTermios = syscall.TIOCSETA
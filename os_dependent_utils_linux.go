@@ -4,4 +4,5 @@ package main
 
 import "syscall"
 
-const ioctlReadTermios = syscall.TCGETS
\ No newline at end of file
+const ioctlReadTermios = syscall.TCGETS
+const ioctlWriteTermios = syscall.TCSETS
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type PodmanDriver struct {
+	ShellService ShellServiceInterface
+	FileService  FileServiceInterface
+	Logger       *Logger
+	// PodmanVersion is the version of the podman client, e.g. 4.9.3
+	PodmanVersion string
+	// Rootless is true when podman runs without root privileges on the host
+	Rootless         bool
+	ReportContainers *RunReportContainers
+}
+
+func NewPodmanDriver(shellService ShellServiceInterface, fs FileServiceInterface, logger *Logger, version string, rootless bool) PodmanDriver {
+	if shellService == nil {
+		panic(errors.New("shellService was nil"))
+	}
+	if fs == nil {
+		panic(errors.New("fs was nil"))
+	}
+	if logger == nil {
+		panic(errors.New("logger was nil"))
+	}
+	return PodmanDriver{
+		ShellService:     shellService,
+		FileService:      fs,
+		Logger:           logger,
+		PodmanVersion:    version,
+		Rootless:         rootless,
+		ReportContainers: NewRunReportContainers(),
+	}
+}
+
+// Returns: podman version and whether podman runs rootless.
+func GetPodmanInfo(shellService ShellServiceInterface) (string, bool) {
+	cmd := "podman info --format '{{.Version.Version}} {{.Host.Security.Rootless}}'"
+	stdout, stderr, es, _ := shellService.RunGetOutput(cmd, true)
+	if es != 0 || stdout == "" {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, es)
+		panic(fmt.Errorf("Unexpected error: %s", cmdInfo))
+	}
+	stdout = strings.TrimSuffix(stdout, "\n")
+	outputArr := strings.Split(stdout, " ")
+	if len(outputArr) != 2 {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, es)
+		panic(fmt.Errorf("Unexpected output: %s", cmdInfo))
+	}
+	return outputArr[0], outputArr[1] == "true"
+}
+
+// Podman counterpart of getContainerInfo. The output of podman inspect differs from docker inspect:
+// the container name is not prefixed with "/" and a missing container is reported as "no such container".
+func getPodmanContainerInfo(shellService ShellServiceInterface, containerNameOrID string) (*ContainerInfo, error) {
+	if containerNameOrID == "" {
+		panic("containerNameOrID was empty")
+	}
+	cmd := fmt.Sprintf("podman inspect --type container --format '{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' %s", containerNameOrID)
+	stdout, stderr, exitStatus, _ := shellService.RunGetOutput(cmd, true)
+	if exitStatus != 0 {
+		output := strings.ToLower(stdout + stderr)
+		if strings.Contains(output, "no such container") || strings.Contains(output, "no such object") {
+			return &ContainerInfo{Exists: false}, nil
+		}
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		return &ContainerInfo{}, fmt.Errorf("Unexpected exit status:\n%s", cmdInfo)
+	}
+	status := strings.TrimSuffix(stdout, "\n")
+	outputArr := strings.Split(status, " ")
+	if len(outputArr) != 4 {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		return &ContainerInfo{}, fmt.Errorf("Unexpected output:\n%s", cmdInfo)
+	}
+	return &ContainerInfo{
+		ID:       outputArr[0],
+		Name:     strings.TrimPrefix(outputArr[1], "/"),
+		Status:   outputArr[2],
+		ExitCode: outputArr[3],
+		Exists:   true,
+	}, nil
+}
+
+// Returns the options needed to make the bind mounted directories usable in a rootless podman container.
+//
+// Rootless podman maps the container root user onto the host user. Thus, the files created in /dojo/work
+// would be owned by a subordinate uid on the host, after the dojo user inside the container was given
+// the uid of /dojo/work owner. With --userns=keep-id, the host user is mapped onto the same uid
+// inside the container. The dojo image entrypoint needs root to fix the uid and gid of the dojo user,
+// thus we also run as root (keep-id would start the container process as the host user).
+func (d PodmanDriver) getUsernsOptions(config Config) string {
+	if !d.Rootless {
+		return ""
+	}
+	if strings.Contains(config.DockerOptions, "--userns") {
+		// user knows better
+		return ""
+	}
+	return " --userns=keep-id --user=root"
+}
+
+func (d PodmanDriver) ConstructPodmanRunCmd(config Config, envFilePath string, envFileMultiLine string, envFileBashFunctions string, containerName string) string {
+	if envFilePath == "" {
+		panic("envFilePath was not set")
+	}
+	if containerName == "" {
+		panic("containerName was not set")
+	}
+	cmd := "podman run"
+	if config.RemoveContainers == "true" {
+		cmd += " --rm"
+	}
+	cmd += d.getUsernsOptions(config)
+	cmd += fmt.Sprintf(" -v %s:%s -v %s:/dojo/identity:ro -v %s:/etc/dojo.d/variables/00-multiline-vars.sh -v %s:/etc/dojo.d/variables/01-bash-functions.sh",
+		config.WorkDirOuter, config.WorkDirInner, config.IdentityDirOuter, envFileMultiLine, envFileBashFunctions)
+	cmd += fmt.Sprintf(" --env-file=%s", envFilePath)
+	if os.Getenv("DISPLAY") != "" {
+		// DISPLAY is set, enable running in graphical mode (opinionated)
+		cmd += " -v /tmp/.X11-unix:/tmp/.X11-unix"
+	}
+	if config.DockerOptions != "" {
+		cmd += fmt.Sprintf(" %s", config.DockerOptions)
+	}
+	shellIsInteractive := d.ShellService.CheckIfInteractive()
+	if config.Interactive == "true" {
+		cmd += " -ti"
+	} else if config.Interactive == "false" {
+		// nothing
+	} else if shellIsInteractive {
+		cmd += " -ti"
+	}
+	cmd += fmt.Sprintf(" --name=%s", containerName)
+	cmd += fmt.Sprintf(" %s", config.DockerImage)
+	if config.RunCommand != "" {
+		cmd += fmt.Sprintf(" %s", config.RunCommand)
+	}
+	return cmd
+}
+
+func (d PodmanDriver) PrintVersion() {
+	printBinaryVersion(d.ShellService, d.Logger, "podman")
+}
+
+func (d PodmanDriver) HandleRun(mergedConfig Config, runID string, envService EnvServiceInterface) int {
+	return runContainer(d.ShellService, d.FileService, d.Logger, d.ReportContainers, "podman", d.ConstructPodmanRunCmd,
+		mergedConfig, runID, envService)
+}
+
+func (d PodmanDriver) HandlePull(mergedConfig Config) int {
+	return pullOrBuildImage(d.ShellService, d.Logger, "podman", mergedConfig)
+}
+
+func (d PodmanDriver) HandleSignal(mergedConfig Config, runID string) int {
+	return stopContainer(d.ShellService, d.Logger, "podman", getPodmanContainerInfo, runID)
+}
+
+func (d PodmanDriver) HandleMultipleSignal(mergedConfig Config, runID string) int {
+	return killContainer(d.ShellService, d.Logger, "podman", getPodmanContainerInfo, runID)
+}
+
+func (d PodmanDriver) FillRunReport(mergedConfig Config, report *RunReport) {
+	report.Image = mergedConfig.DockerImage
+	report.ImageDigest = getImageDigest(d.ShellService, "podman", mergedConfig.DockerImage)
+	report.Containers = d.ReportContainers.Get()
+}
+
+func (d PodmanDriver) CleanAfterRun(mergedConfig Config, runID string) int {
+	return cleanAfterContainerRun(d.FileService, d.Logger, mergedConfig, runID)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPodmanDriver_ConstructPodmanRunCmd_Rootless(t *testing.T) {
+	type mytestStruct struct {
+		rootless      bool
+		dockerOptions string
+		expOutput     string
+	}
+	volumesOutput := "-v /tmp/bla:/dojo/work -v /tmp/myidentity:/dojo/identity:ro " +
+		"-v /tmp/some-env-file-multiline:/etc/dojo.d/variables/00-multiline-vars.sh " +
+		"-v /tmp/some-env-file-bash-functions:/etc/dojo.d/variables/01-bash-functions.sh " +
+		"--env-file=/tmp/some-env-file"
+	mytests := []mytestStruct{
+		mytestStruct{rootless: false, dockerOptions: "",
+			expOutput: "podman run --rm " + volumesOutput + " --name=name1 img:1.2.3"},
+		mytestStruct{rootless: true, dockerOptions: "",
+			expOutput: "podman run --rm --userns=keep-id --user=root " + volumesOutput + " --name=name1 img:1.2.3"},
+		mytestStruct{rootless: true, dockerOptions: "--userns=auto",
+			expOutput: "podman run --rm " + volumesOutput + " --userns=auto --name=name1 img:1.2.3"},
+	}
+	setTestEnv()
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		config := getTestConfig()
+		config.DockerOptions = v.dockerOptions
+		d := NewPodmanDriver(NewMockedShellServiceNotInteractive(logger), NewMockedFileService(logger), logger, "4.9.3", v.rootless)
+		cmd := d.ConstructPodmanRunCmd(config, "/tmp/some-env-file",
+			"/tmp/some-env-file-multiline", "/tmp/some-env-file-bash-functions",
+			"name1")
+		assert.Equal(t, v.expOutput, cmd, fmt.Sprintf("rootless: %v, dockerOptions: %v", v.rootless, v.dockerOptions))
+	}
+}
+
+func TestPodmanDriver_ConstructPodmanRunCmd_Interactive(t *testing.T) {
+	setTestEnv()
+	logger := NewLogger("debug")
+	config := getTestConfig()
+	config.RunCommand = "bash"
+	d := NewPodmanDriver(NewMockedShellServiceInteractive(logger), NewMockedFileService(logger), logger, "4.9.3", false)
+	cmd := d.ConstructPodmanRunCmd(config, "/tmp/some-env-file",
+		"/tmp/some-env-file-multiline", "/tmp/some-env-file-bash-functions",
+		"name1")
+	assert.Equal(t, "podman run --rm -v /tmp/bla:/dojo/work -v /tmp/myidentity:/dojo/identity:ro "+
+		"-v /tmp/some-env-file-multiline:/etc/dojo.d/variables/00-multiline-vars.sh "+
+		"-v /tmp/some-env-file-bash-functions:/etc/dojo.d/variables/01-bash-functions.sh "+
+		"--env-file=/tmp/some-env-file -ti --name=name1 img:1.2.3 bash", cmd)
+}
+
+func TestPodmanDriver_HandleRun_Unit(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	shellS := NewMockedShellServiceNotInteractive(logger)
+	d := NewPodmanDriver(shellS, fs, logger, "4.9.3", true)
+	config := getTestConfig()
+	config.RunCommand = ""
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, 3, len(fs.FilesWrittenTo))
+	assert.Equal(t, "ABC=123\n", fs.FilesWrittenTo["/tmp/dojo-environment-testrunid"])
+	assert.True(t, elem_in_array(shellS.CommandsRun, "podman run --rm --userns=keep-id"))
+
+	es = d.CleanAfterRun(config, "testrunid")
+	assert.Equal(t, 0, es)
+	assert.Equal(t, 6, len(fs.FilesRemovals))
+}
+
+func TestPodmanDriver_HandleRun_NotRemoveContainers(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	d := NewPodmanDriver(NewMockedShellServiceNotInteractive(logger), fs, logger, "4.9.3", false)
+	config := getTestConfig()
+	config.RunCommand = ""
+	config.RemoveContainers = "false"
+	es := d.HandleRun(config, "testrunid", NewMockedEnvService())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, "testrunid", fs.FilesWrittenTo["/tmp//dojorc.txt"])
+	assert.Equal(t, "DOJO_RUN_ID=testrunid", fs.FilesWrittenTo["/tmp//dojorc"])
+}
+
+func TestPodmanDriver_HandlePull_Unit(t *testing.T) {
+	logger := NewLogger("debug")
+	shellS := NewMockedShellServiceNotInteractive(logger)
+	d := NewPodmanDriver(shellS, NewMockedFileService(logger), logger, "4.9.3", false)
+	es := d.HandlePull(getTestConfig())
+	assert.Equal(t, 0, es)
+	assert.Equal(t, []string{"Pretending to run: podman pull img:1.2.3"}, shellS.CommandsRun)
+}
+
+func TestPodmanDriver_HandleSignal(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["podman inspect --type container --format '{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' testrunid"] =
+		[]string{"1234 testrunid running 0", "", "0"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	d := NewPodmanDriver(shellS, NewMockedFileService(logger), logger, "4.9.3", false)
+	es := d.HandleSignal(getTestConfig(), "testrunid")
+	assert.Equal(t, 0, es)
+	assert.True(t, elem_in_array(shellS.CommandsRun, "podman stop testrunid"))
+}
+
+func TestPodmanDriver_HandleMultipleSignal_ContainerRemoved(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["podman inspect --type container --format '{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' testrunid"] =
+		[]string{"", "Error: no such container \"testrunid\"", "125"}
+	shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	d := NewPodmanDriver(shellS, NewMockedFileService(logger), logger, "4.9.3", false)
+	es := d.HandleMultipleSignal(getTestConfig(), "testrunid")
+	assert.Equal(t, 0, es)
+	assert.False(t, elem_in_array(shellS.CommandsRun, "podman kill"))
+}
+
+func Test_getPodmanContainerInfo(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["podman inspect --type container --format '{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' 1234"] =
+		[]string{"1234 name1 exited 3\n", "", "0"}
+	shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	info, err := getPodmanContainerInfo(shell, "1234")
+	assert.Nil(t, err)
+	assert.Equal(t, "1234", info.ID)
+	assert.Equal(t, "name1", info.Name)
+	assert.Equal(t, "exited", info.Status)
+	assert.Equal(t, "3", info.ExitCode)
+	assert.True(t, info.Exists)
+}
+
+func Test_GetPodmanInfo(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	commandsReactions["podman info --format '{{.Version.Version}} {{.Host.Security.Rootless}}'"] =
+		[]string{"4.9.3 true\n", "", "0"}
+	shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	version, rootless := GetPodmanInfo(shell)
+	assert.Equal(t, "4.9.3", version)
+	assert.True(t, rootless)
+}
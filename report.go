@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunReport describes a finished run. It is saved after the run to the ReportJSON file and,
+// as JUnit XML, to the ReportJUnit file.
+type RunReport struct {
+	RunID       string               `json:"runID"`
+	Driver      string               `json:"driver"`
+	Image       string               `json:"image"`
+	ImageDigest string               `json:"imageDigest"`
+	Command     string               `json:"command"`
+	StartTime   time.Time            `json:"startTime"`
+	EndTime     time.Time            `json:"endTime"`
+	Containers  []RunReportContainer `json:"containers"`
+	// Signals caught by dojo, e.g. "interrupt"
+	Signals []string `json:"signals"`
+	// The exit status of dojo
+	ExitStatus int `json:"exitStatus"`
+}
+
+type RunReportContainer struct {
+	Name string `json:"name"`
+	// Service is empty if the container was not created by docker-compose
+	Service  string `json:"service,omitempty"`
+	Default  bool   `json:"default"`
+	Status   string `json:"status"`
+	ExitCode string `json:"exitCode"`
+}
+
+// RunReportContainers keep the information about the containers of a run, collected by a driver before
+// the containers are removed. It is shared between the goroutine handling the run and the main goroutine.
+type RunReportContainers struct {
+	mutex      *sync.Mutex
+	containers []RunReportContainer
+}
+
+func NewRunReportContainers() *RunReportContainers {
+	return &RunReportContainers{
+		mutex:      &sync.Mutex{},
+		containers: make([]RunReportContainer, 0),
+	}
+}
+
+func (r *RunReportContainers) Add(container RunReportContainer) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.containers = append(r.containers, container)
+}
+
+func (r *RunReportContainers) Get() []RunReportContainer {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	containers := make([]RunReportContainer, len(r.containers))
+	copy(containers, r.containers)
+	return containers
+}
+
+// Returns the first repository digest of the image, e.g. alpine@sha256:1234, or an empty string if it cannot be
+// resolved, e.g. when the image was built locally. The binary is: docker or podman.
+func getImageDigest(shellService ShellServiceInterface, binary string, image string) string {
+	cmd := fmt.Sprintf("%s image inspect --format '{{range .RepoDigests}}{{.}} {{end}}' %s", binary, image)
+	stdout, _, exitStatus, _ := shellService.RunGetOutput(cmd, true)
+	if exitStatus != 0 {
+		return ""
+	}
+	digests := strings.Fields(stdout)
+	if len(digests) == 0 {
+		return ""
+	}
+	return digests[0]
+}
+
+func (r RunReport) ToJSON() (string, error) {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(bytes) + "\n", nil
+}
+
+type junitTestSuites struct {
+	XMLName    xml.Name         `xml:"testsuites"`
+	TestSuites []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string          `xml:"name,attr"`
+	Tests      int             `xml:"tests,attr"`
+	Failures   int             `xml:"failures,attr"`
+	Timestamp  string          `xml:"timestamp,attr"`
+	Time       string          `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property"`
+	TestCases  []junitTestCase `xml:"testcase"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+// Converts the report into JUnit XML, so that CI servers can show the failed containers as failed tests.
+// There is one test case for each container and one for the whole run, which fails if the exit status is not 0.
+func (r RunReport) ToJUnit() (string, error) {
+	suite := junitTestSuite{
+		Name:      "dojo",
+		Timestamp: r.StartTime.UTC().Format("2006-01-02T15:04:05"),
+		Time:      fmt.Sprintf("%.3f", r.EndTime.Sub(r.StartTime).Seconds()),
+		Properties: []junitProperty{
+			junitProperty{Name: "runID", Value: r.RunID},
+			junitProperty{Name: "driver", Value: r.Driver},
+			junitProperty{Name: "image", Value: r.Image},
+			junitProperty{Name: "imageDigest", Value: r.ImageDigest},
+			junitProperty{Name: "command", Value: r.Command},
+			junitProperty{Name: "signals", Value: strings.Join(r.Signals, ",")},
+		},
+		TestCases: make([]junitTestCase, 0),
+	}
+	for _, container := range r.Containers {
+		name := container.Name
+		if container.Service != "" {
+			name = fmt.Sprintf("%s (%s)", container.Service, container.Name)
+		}
+		testCase := junitTestCase{ClassName: "dojo.containers", Name: name}
+		if container.ExitCode != "0" && container.ExitCode != "" {
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("Container: %s, status: %s, exit code: %s", container.Name, container.Status, container.ExitCode),
+				Type:    "ContainerFailure",
+			}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	runTestCase := junitTestCase{ClassName: "dojo", Name: "run"}
+	if r.ExitStatus != 0 {
+		runTestCase.Failure = &junitFailure{
+			Message: fmt.Sprintf("Dojo exited with status: %v", r.ExitStatus),
+			Type:    "RunFailure",
+		}
+	}
+	suite.TestCases = append(suite.TestCases, runTestCase)
+	suite.Tests = len(suite.TestCases)
+	for _, testCase := range suite.TestCases {
+		if testCase.Failure != nil {
+			suite.Failures++
+		}
+	}
+
+	bytes, err := xml.MarshalIndent(junitTestSuites{TestSuites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return xml.Header + string(bytes) + "\n", nil
+}
+
+// Saves the report to the ReportJSON and ReportJUnit files, if they are set
+func saveRunReport(fileService FileServiceInterface, logger *Logger, mergedConfig Config, report RunReport) {
+	if mergedConfig.ReportJSON != "" {
+		contents, err := report.ToJSON()
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Error when creating the JSON run report: %s", err))
+		} else {
+			fileService.WriteToFile(mergedConfig.ReportJSON, contents, "debug")
+			logger.Log("info", fmt.Sprintf("The run report was saved to: %s", mergedConfig.ReportJSON))
+		}
+	}
+	if mergedConfig.ReportJUnit != "" {
+		contents, err := report.ToJUnit()
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("Error when creating the JUnit run report: %s", err))
+		} else {
+			fileService.WriteToFile(mergedConfig.ReportJUnit, contents, "debug")
+			logger.Log("info", fmt.Sprintf("The JUnit run report was saved to: %s", mergedConfig.ReportJUnit))
+		}
+	}
+}
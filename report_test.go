@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func getTestRunReport() RunReport {
+	startTime := time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC)
+	return RunReport{
+		RunID:       "dojo-myproject-1234",
+		Driver:      "docker-compose",
+		Image:       "alpine:3.21",
+		ImageDigest: "alpine@sha256:abcd",
+		Command:     "make test",
+		StartTime:   startTime,
+		EndTime:     startTime.Add(90 * time.Second),
+		Containers: []RunReportContainer{
+			RunReportContainer{Name: "dojo-myproject-1234-db-1", Service: "db", Status: "exited", ExitCode: "1"},
+			RunReportContainer{Name: "dojo-myproject-1234-default-run-1", Service: "default", Default: true, Status: "exited", ExitCode: "0"},
+		},
+		Signals:    []string{"interrupt"},
+		ExitStatus: 130,
+	}
+}
+
+func TestRunReport_ToJSON(t *testing.T) {
+	output, err := getTestRunReport().ToJSON()
+	assert.Nil(t, err)
+	assert.Equal(t, `{
+  "runID": "dojo-myproject-1234",
+  "driver": "docker-compose",
+  "image": "alpine:3.21",
+  "imageDigest": "alpine@sha256:abcd",
+  "command": "make test",
+  "startTime": "2025-01-02T10:00:00Z",
+  "endTime": "2025-01-02T10:01:30Z",
+  "containers": [
+    {
+      "name": "dojo-myproject-1234-db-1",
+      "service": "db",
+      "default": false,
+      "status": "exited",
+      "exitCode": "1"
+    },
+    {
+      "name": "dojo-myproject-1234-default-run-1",
+      "service": "default",
+      "default": true,
+      "status": "exited",
+      "exitCode": "0"
+    }
+  ],
+  "signals": [
+    "interrupt"
+  ],
+  "exitStatus": 130
+}
+`, output)
+}
+
+func TestRunReport_ToJUnit(t *testing.T) {
+	output, err := getTestRunReport().ToJUnit()
+	assert.Nil(t, err)
+	assert.Equal(t, `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="dojo" tests="3" failures="2" timestamp="2025-01-02T10:00:00" time="90.000">
+    <properties>
+      <property name="runID" value="dojo-myproject-1234"></property>
+      <property name="driver" value="docker-compose"></property>
+      <property name="image" value="alpine:3.21"></property>
+      <property name="imageDigest" value="alpine@sha256:abcd"></property>
+      <property name="command" value="make test"></property>
+      <property name="signals" value="interrupt"></property>
+    </properties>
+    <testcase classname="dojo.containers" name="db (dojo-myproject-1234-db-1)">
+      <failure message="Container: dojo-myproject-1234-db-1, status: exited, exit code: 1" type="ContainerFailure"></failure>
+    </testcase>
+    <testcase classname="dojo.containers" name="default (dojo-myproject-1234-default-run-1)"></testcase>
+    <testcase classname="dojo" name="run">
+      <failure message="Dojo exited with status: 130" type="RunFailure"></failure>
+    </testcase>
+  </testsuite>
+</testsuites>
+`, output)
+}
+
+func Test_getImageDigest(t *testing.T) {
+	type mytestStruct struct {
+		output     string
+		exitStatus string
+		expDigest  string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{output: "alpine@sha256:abcd \n", exitStatus: "0", expDigest: "alpine@sha256:abcd"},
+		mytestStruct{output: "alpine@sha256:abcd docker.io/library/alpine@sha256:abcd \n", exitStatus: "0", expDigest: "alpine@sha256:abcd"},
+		// built locally
+		mytestStruct{output: "\n", exitStatus: "0", expDigest: ""},
+		mytestStruct{output: "", exitStatus: "1", expDigest: ""},
+	}
+	logger := NewLogger("debug")
+	for _, v := range mytests {
+		commandsReactions := make(map[string]interface{}, 0)
+		commandsReactions["docker image inspect --format '{{range .RepoDigests}}{{.}} {{end}}' alpine:3.21"] =
+			[]string{v.output, "", v.exitStatus}
+		shellS := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+		assert.Equal(t, v.expDigest, getImageDigest(shellS, "docker", "alpine:3.21"), v.output)
+	}
+}
+
+func Test_saveRunReport(t *testing.T) {
+	logger := NewLogger("debug")
+	fs := NewMockedFileService(logger)
+	config := getTestConfig()
+	config.ReportJSON = "/tmp/report.json"
+	config.ReportJUnit = "/tmp/report.xml"
+	saveRunReport(fs, logger, config, getTestRunReport())
+	assert.Contains(t, fs.FilesWrittenTo["/tmp/report.json"], `"exitStatus": 130`)
+	assert.Contains(t, fs.FilesWrittenTo["/tmp/report.xml"], `<testsuite name="dojo" tests="3" failures="2"`)
+
+	fs = NewMockedFileService(logger)
+	saveRunReport(fs, logger, getTestConfig(), getTestRunReport())
+	assert.Equal(t, 0, len(fs.FilesWrittenTo))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"os"
@@ -19,6 +20,10 @@ type ShellServiceInterface interface {
 	// process the signaled return value.
 	RunGetOutput(cmdString string, separatePGroup bool) (string, string, int, bool)
 	CheckIfInteractive() bool
+	// Starts the command and sends each line of its stdout to the returned channel. The channel is closed
+	// when the command exits. Closing the stop channel kills the command.
+	// Returns an error only if the command could not be started.
+	RunStreamOutput(cmdString string, stop <-chan bool) (<-chan string, error)
 	// set environment variables, override any existing variables
 	SetEnvironment(variables []string)
 }
@@ -106,6 +111,45 @@ func (bs BashShellService) RunGetOutput(cmdString string, separatePGroup bool) (
 	return stdout.String(), stderr.String(), exitStatus, signaled
 }
 
+func (bs BashShellService) RunStreamOutput(cmdString string, stop <-chan bool) (<-chan string, error) {
+	cmd := exec.Command("bash", "-c", cmdString)
+	// Run in a separate process group, so that the command and all its children can be killed together
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Env = bs.Environment
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	lines := make(chan string)
+	exited := make(chan bool)
+	go func() {
+		select {
+		case <-stop:
+			bs.Logger.Log("debug", fmt.Sprintf("Killing: %s", cmdString))
+			syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		case <-exited:
+		}
+	}()
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-stop:
+			}
+		}
+		cmd.Wait()
+		close(exited)
+		bs.Logger.Log("debug", fmt.Sprintf("Exited: %s", cmdString))
+	}()
+	return lines, nil
+}
+
 func (bs BashShellService) CheckIfInteractive() bool {
 	// stolen from: https://github.com/mattn/go-isatty/blob/master/isatty_linux.go
 	fd := os.Stdout.Fd()
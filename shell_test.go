@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 
@@ -50,6 +52,16 @@ func (bs *MockedShellServiceNotInteractive) RunInteractive(cmdString string, sep
 	cmd := fmt.Sprintf("Pretending to run: %s", cmdString)
 	bs.Logger.Log("debug", cmd)
 	bs.AppendCommandRun(cmd)
+	if bs.CommandsReactions != nil {
+		// only the exit status of the reaction is used
+		bs.Mutex.Lock()
+		val, ok := bs.CommandsReactions[cmdString].([]string)
+		bs.Mutex.Unlock()
+		if ok {
+			exitStatus, _ := strconv.Atoi(val[2])
+			return exitStatus, false
+		}
+	}
 	return 0, false
 }
 func (bs *MockedShellServiceNotInteractive) RunGetOutput(cmdString string, separePGroup bool) (string, string, int, bool) {
@@ -57,8 +69,20 @@ func (bs *MockedShellServiceNotInteractive) RunGetOutput(cmdString string, separ
 	bs.Logger.Log("debug", cmd)
 	bs.AppendCommandRun(cmd)
 	if bs.CommandsReactions != nil {
-		if val, ok := bs.CommandsReactions[cmdString]; ok {
-			valArr := val.([]string)
+		bs.Mutex.Lock()
+		val, ok := bs.CommandsReactions[cmdString]
+		var valArr []string
+		if reactions, isSequence := val.([][]string); ok && isSequence {
+			// consecutive reactions to the same command, the last one is repeated
+			valArr = reactions[0]
+			if len(reactions) > 1 {
+				bs.CommandsReactions[cmdString] = reactions[1:]
+			}
+		} else if ok {
+			valArr = val.([]string)
+		}
+		bs.Mutex.Unlock()
+		if ok {
 			stdo := valArr[0]
 			stde := valArr[1]
 			es, err := strconv.Atoi(valArr[2])
@@ -75,6 +99,44 @@ func (bs *MockedShellServiceNotInteractive) RunGetOutput(cmdString string, separ
 func (bs MockedShellServiceNotInteractive) CheckIfInteractive() bool {
 	return false
 }
+// The reaction to a streamed command is found by the command prefix, because such commands may contain
+// e.g. timestamps. The lines of the mocked stdout are sent and then the channel is closed. A non zero
+// exit status means that the command could not be started.
+func (bs *MockedShellServiceNotInteractive) RunStreamOutput(cmdString string, stop <-chan bool) (<-chan string, error) {
+	cmd := fmt.Sprintf("Pretending to run: %s", cmdString)
+	bs.Logger.Log("debug", cmd)
+	bs.AppendCommandRun(cmd)
+	lines := make(chan string)
+	var valArr []string
+	bs.Mutex.Lock()
+	for prefix, val := range bs.CommandsReactions {
+		if strings.HasPrefix(cmdString, prefix) {
+			valArr = val.([]string)
+		}
+	}
+	bs.Mutex.Unlock()
+	stdo := ""
+	if valArr != nil {
+		if valArr[2] != "0" {
+			return nil, fmt.Errorf("Pretending to fail: %s, stderr: %s", cmdString, valArr[1])
+		}
+		stdo = valArr[0]
+	}
+	go func() {
+		defer close(lines)
+		for _, line := range strings.Split(strings.TrimSuffix(stdo, "\n"), "\n") {
+			if line == "" {
+				continue
+			}
+			select {
+			case lines <- line:
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return lines, nil
+}
 
 type MockedShellServiceInteractive struct {
 	ShellBinary string
@@ -116,6 +178,14 @@ func (bs *MockedShellServiceInteractive) RunGetOutput(cmdString string, separePG
 func (bs MockedShellServiceInteractive) CheckIfInteractive() bool {
 	return true
 }
+func (bs *MockedShellServiceInteractive) RunStreamOutput(cmdString string, stop <-chan bool) (<-chan string, error) {
+	cmd := fmt.Sprintf("Pretending to run: %s", cmdString)
+	bs.Logger.Log("debug", cmd)
+	bs.AppendCommandRun(cmd)
+	lines := make(chan string)
+	close(lines)
+	return lines, nil
+}
 
 func TestMockedShellService_CheckIfInteractive(t *testing.T){
 	logger := NewLogger("debug")
@@ -145,6 +215,32 @@ func TestBashShellService_RunGetOutput(t *testing.T) {
 	assert.Equal(t, 0, exitstatus)
 	assert.Equal(t, false, signaled)
 }
+func TestBashShellService_RunStreamOutput(t *testing.T) {
+	logger := NewLogger("debug")
+	shell := NewBashShellService(logger)
+	lines, err := shell.RunStreamOutput("echo hello; echo world", make(chan bool))
+	assert.Nil(t, err)
+	received := make([]string, 0)
+	for line := range lines {
+		received = append(received, line)
+	}
+	assert.Equal(t, []string{"hello", "world"}, received)
+}
+func TestBashShellService_RunStreamOutput_Stop(t *testing.T) {
+	logger := NewLogger("debug")
+	shell := NewBashShellService(logger)
+	stop := make(chan bool)
+	lines, err := shell.RunStreamOutput("echo hello; sleep 60; echo world", stop)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", <-lines)
+	close(stop)
+	select {
+	case _, ok := <-lines:
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the command to be killed")
+	}
+}
 func TestBashShellService_SetEnv(t *testing.T) {
 	logger := NewLogger("debug")
 	shell := NewBashShellService(logger)
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// Puts the terminal into raw mode, so that every key press (including Ctrl+C) is passed on
+// to a container with a TTY. Returns the previous terminal state, to be passed to restoreTerminal.
+// Based on: https://github.com/golang/term/blob/master/term_unix.go
+func setRawTerminal(fd uintptr) (*syscall.Termios, error) {
+	var oldState syscall.Termios
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, uintptr(ioctlReadTermios), uintptr(unsafe.Pointer(&oldState)), 0, 0, 0); err != 0 {
+		return nil, err
+	}
+	newState := oldState
+	newState.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	newState.Oflag &^= syscall.OPOST
+	newState.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	newState.Cflag &^= syscall.CSIZE | syscall.PARENB
+	newState.Cflag |= syscall.CS8
+	newState.Cc[syscall.VMIN] = 1
+	newState.Cc[syscall.VTIME] = 0
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, uintptr(ioctlWriteTermios), uintptr(unsafe.Pointer(&newState)), 0, 0, 0); err != 0 {
+		return nil, err
+	}
+	return &oldState, nil
+}
+
+func restoreTerminal(fd uintptr, state *syscall.Termios) error {
+	if _, _, err := syscall.Syscall6(syscall.SYS_IOCTL, fd, uintptr(ioctlWriteTermios), uintptr(unsafe.Pointer(state)), 0, 0, 0); err != 0 {
+		return err
+	}
+	return nil
+}
+
+// Returns: height and width of the terminal
+func getTerminalSize(fd uintptr) (uint16, uint16, error) {
+	var winsize struct {
+		Row    uint16
+		Col    uint16
+		Xpixel uint16
+		Ypixel uint16
+	}
+	if _, _, err := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&winsize))); err != 0 {
+		return 0, 0, err
+	}
+	return winsize.Row, winsize.Col, nil
+}
@@ -172,6 +172,10 @@ type ContainerInfo struct {
 	ExitCode string
 	Exists   bool
 	Logs     string
+	// Project and Service are set from the labels: com.docker.compose.project and com.docker.compose.service.
+	// Both are empty if the container was not created by docker-compose.
+	Project string
+	Service string
 }
 
 // Returns: container ID, status , whether or not a container exists, error
@@ -180,7 +184,9 @@ func getContainerInfo(shellService ShellServiceInterface, containerNameOrID stri
 		panic("containerNameOrID was empty")
 	}
 	// https://docs.docker.com/engine/api/v1.21/
-	cmd := fmt.Sprintf("docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' %s", containerNameOrID)
+	cmd := fmt.Sprintf("docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}"+
+		" {{index .Config.Labels \"com.docker.compose.project\"}} {{index .Config.Labels \"com.docker.compose.service\"}}' %s",
+		containerNameOrID)
 	stdout, stderr, exitStatus, _ := shellService.RunGetOutput(cmd, true)
 	if exitStatus != 0 {
 		if strings.Contains(stdout, "No such object") || strings.Contains(stderr, "No such object") {
@@ -191,11 +197,98 @@ func getContainerInfo(shellService ShellServiceInterface, containerNameOrID stri
 	}
 	status := strings.TrimSuffix(stdout, "\n")
 	outputArr := strings.Split(status, " ")
-	return &ContainerInfo{
+	if len(outputArr) < 4 {
+		cmdInfo := cmdInfoToString(cmd, stdout, stderr, exitStatus)
+		return &ContainerInfo{}, fmt.Errorf("Unexpected output:\n%s", cmdInfo)
+	}
+	containerInfo := &ContainerInfo{
 		ID:       outputArr[0],
 		Name:     strings.TrimPrefix(outputArr[1], "/"),
 		Status:   outputArr[2],
 		ExitCode: outputArr[3],
 		Exists:   true,
-	}, nil
+	}
+	if len(outputArr) == 6 {
+		containerInfo.Project = outputArr[4]
+		containerInfo.Service = outputArr[5]
+	}
+	return containerInfo, nil
+}
+
+// Splits a string into words, the way Bash would do it, without any expansions.
+// Single quotes, double quotes and backslash escapes are supported.
+// E.g. `sh -c "echo hello"` -> ["sh", "-c", "echo hello"]
+func splitShellWords(str string) ([]string, error) {
+	words := make([]string, 0)
+	var current strings.Builder
+	inWord := false
+	runes := []rune(str)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		case r == '\\':
+			inWord = true
+			if i+1 < len(runes) {
+				i++
+				if runes[i] != '\n' {
+					current.WriteRune(runes[i])
+				}
+			}
+		case r == '\'':
+			inWord = true
+			end := i + 1
+			for end < len(runes) && runes[end] != '\'' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated single quote in: %s", str)
+			}
+			current.WriteString(string(runes[i+1 : end]))
+			i = end
+		case r == '"':
+			inWord = true
+			i++
+			for ; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`\n", runes[i+1]) {
+					i++
+					if runes[i] == '\n' {
+						continue
+					}
+				}
+				current.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated double quote in: %s", str)
+			}
+		default:
+			inWord = true
+			current.WriteRune(r)
+		}
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+var shellSafeWordRegexp = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./${}-]+$`)
+
+// Joins the words into a string, which splitShellWords splits back into the same words. A word with
+// any other character is single quoted, and each single quote in it is written as: quote, backslash, quote, quote.
+// E.g. ["-v", "/tmp/m2 dir:/m2"] -> `-v '/tmp/m2 dir:/m2'`
+func joinShellWords(words []string) string {
+	quotedWords := make([]string, 0)
+	for _, word := range words {
+		if !shellSafeWordRegexp.MatchString(word) {
+			word = "'" + strings.Replace(word, "'", `'\''`, -1) + "'"
+		}
+		quotedWords = append(quotedWords, word)
+	}
+	return strings.Join(quotedWords, " ")
 }
@@ -142,11 +142,18 @@ bb
 	assert.Equal(t, "aaabb", actual)
 }
 
+// Returns the command run by getContainerInfo
+func dockerInspectCmd(containerNameOrID string) string {
+	return "docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}" +
+		" {{index .Config.Labels \"com.docker.compose.project\"}} {{index .Config.Labels \"com.docker.compose.service\"}}' " +
+		containerNameOrID
+}
+
 func Test_getContainerInfo(t *testing.T) {
 	logger := NewLogger("debug")
 	commandsReactions := make(map[string]interface{}, 0)
-	fakeOutput := `1234 /name1 running 133`
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' 1234"] =
+	fakeOutput := `1234 /name1 running 133 myproject abc`
+	commandsReactions[dockerInspectCmd("1234")] =
 		[]string{fakeOutput, "", "0"}
 	shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
 	info, err := getContainerInfo(shell, "1234")
@@ -154,14 +161,28 @@ func Test_getContainerInfo(t *testing.T) {
 	assert.Equal(t, "name1", info.Name)
 	assert.Equal(t, "running", info.Status)
 	assert.Equal(t, "133", info.ExitCode)
+	assert.Equal(t, "myproject", info.Project)
+	assert.Equal(t, "abc", info.Service)
 	assert.Equal(t, nil, err)
 	assert.Equal(t, true, info.Exists)
 }
 
+func Test_getContainerInfo_NotDockerCompose(t *testing.T) {
+	logger := NewLogger("debug")
+	commandsReactions := make(map[string]interface{}, 0)
+	// the labels are not set, so their values are empty
+	commandsReactions[dockerInspectCmd("1234")] =
+		[]string{"1234 /name1 exited 0  \n", "", "0"}
+	shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
+	info, err := getContainerInfo(shell, "1234")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &ContainerInfo{ID: "1234", Name: "name1", Status: "exited", ExitCode: "0", Exists: true}, info)
+}
+
 func Test_getContainerInfo_NoSuchObject(t *testing.T) {
 	logger := NewLogger("debug")
 	commandsReactions := make(map[string]interface{}, 0)
-	commandsReactions["docker inspect --format='{{.Id}} {{.Name}} {{.State.Status}} {{.State.ExitCode}}' 1234"] =
+	commandsReactions[dockerInspectCmd("1234")] =
 		[]string{"", "Error: No such object: 1234", "1"}
 	shell := NewMockedShellServiceNotInteractive2(logger, commandsReactions)
 	info, err := getContainerInfo(shell, "1234")
@@ -196,3 +217,52 @@ func Test_toPrintOrNotToPrint(t *testing.T) {
 	assert.Equal(t, true, toPrintOrNotToPrint("error", "error"))
 	assert.Equal(t, false, toPrintOrNotToPrint("error", "silent"))
 }
+
+func Test_splitShellWords(t *testing.T) {
+	type mytestStruct struct {
+		input       string
+		expOutput   []string
+		expErrorMsg string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{input: "", expOutput: []string{}},
+		mytestStruct{input: "  bash   -c  ", expOutput: []string{"bash", "-c"}},
+		mytestStruct{input: "bash -c \"echo hello; exit 3\"", expOutput: []string{"bash", "-c", "echo hello; exit 3"}},
+		mytestStruct{input: "echo 'a \"b\"' c\\ d", expOutput: []string{"echo", "a \"b\"", "c d"}},
+		mytestStruct{input: "echo \"a \\\"b\\\" \\n\"", expOutput: []string{"echo", "a \"b\" \\n"}},
+		mytestStruct{input: "echo ''", expOutput: []string{"echo", ""}},
+		mytestStruct{input: "echo 'abc", expErrorMsg: "unterminated single quote in: echo 'abc"},
+		mytestStruct{input: "echo \"abc", expErrorMsg: "unterminated double quote in: echo \"abc"},
+	}
+	for _, v := range mytests {
+		words, err := splitShellWords(v.input)
+		if v.expErrorMsg != "" {
+			assert.Equal(t, v.expErrorMsg, err.Error(), v.input)
+		} else {
+			assert.Nil(t, err, v.input)
+			assert.Equal(t, v.expOutput, words, v.input)
+		}
+	}
+}
+
+func Test_joinShellWords(t *testing.T) {
+	type mytestStruct struct {
+		words     []string
+		expOutput string
+	}
+	mytests := []mytestStruct{
+		mytestStruct{words: []string{}, expOutput: ""},
+		mytestStruct{words: []string{"--init", "-v", "/tmp/cache:/cache", "-e", "TAG=${TAG}"}, expOutput: "--init -v /tmp/cache:/cache -e TAG=${TAG}"},
+		mytestStruct{words: []string{"-v", "/tmp/m2 dir:/m2"}, expOutput: "-v '/tmp/m2 dir:/m2'"},
+		mytestStruct{words: []string{"-e", "GREETING=it's"}, expOutput: "-e 'GREETING=it'\\''s'"},
+		mytestStruct{words: []string{"-e", "A=\"b\"", "-e", "B=c\\d", "-e", "C="}, expOutput: "-e 'A=\"b\"' -e 'B=c\\d' -e C="},
+		mytestStruct{words: []string{"echo", ""}, expOutput: "echo ''"},
+	}
+	for _, v := range mytests {
+		output := joinShellWords(v.words)
+		assert.Equal(t, v.expOutput, output, v.words)
+		words, err := splitShellWords(output)
+		assert.Nil(t, err, v.words)
+		assert.Equal(t, v.words, words)
+	}
+}